@@ -0,0 +1,100 @@
+package gptbot
+
+import (
+	"os"
+	"unsafe"
+
+	"github.com/go-aie/xslices"
+)
+
+// arenaSegmentSize is the minimum size of each arena segment in bytes.
+const arenaSegmentSize = 64 << 20
+
+// vectorArena keeps embeddings in memory-mapped temporary files, which are
+// backed by disk instead of the Go heap. The slots of the released embeddings
+// are reused by later ones of the same dimension, but the files never shrink
+// until the arena is closed.
+type vectorArena struct {
+	dir    string
+	unmaps []func() error
+
+	// free is the unused part of the current segment.
+	free []float64
+
+	// released are the slots of the released embeddings, keyed by dimension.
+	released map[int][]Embedding
+}
+
+func newVectorArena(dir string) *vectorArena {
+	return &vectorArena{dir: dir, released: make(map[int][]Embedding)}
+}
+
+// Store copies the embedding into the arena, and returns the copy.
+func (a *vectorArena) Store(e Embedding) (Embedding, error) {
+	if len(e) == 0 {
+		return e, nil
+	}
+
+	if slots := a.released[len(e)]; len(slots) > 0 {
+		stored := slots[len(slots)-1]
+		a.released[len(e)] = slots[:len(slots)-1]
+		copy(stored, e)
+		return stored, nil
+	}
+
+	if len(a.free) < len(e) {
+		if err := a.grow(len(e)); err != nil {
+			return nil, err
+		}
+	}
+
+	stored := a.free[:len(e):len(e)]
+	copy(stored, e)
+	a.free = a.free[len(e):]
+	return stored, nil
+}
+
+// Release marks the slot of the stored embedding as reusable. The embedding
+// must not be used afterwards.
+func (a *vectorArena) Release(e Embedding) {
+	if len(e) > 0 {
+		a.released[len(e)] = append(a.released[len(e)], e)
+	}
+}
+
+func (a *vectorArena) grow(n int) error {
+	size := xslices.Max(arenaSegmentSize, n*8)
+
+	f, err := os.CreateTemp(a.dir, "vectors-*.bin")
+	if err != nil {
+		return err
+	}
+	// The file will be deleted once unmapped.
+	defer os.Remove(f.Name())
+	defer f.Close()
+
+	if err := f.Truncate(int64(size)); err != nil {
+		return err
+	}
+	data, unmap, err := mmapFileRW(f, size)
+	if err != nil {
+		return err
+	}
+
+	a.unmaps = append(a.unmaps, unmap)
+	a.free = unsafe.Slice((*float64)(unsafe.Pointer(&data[0])), size/8)
+	return nil
+}
+
+func (a *vectorArena) Close() error {
+	var firstErr error
+	for _, unmap := range a.unmaps {
+		if err := unmap(); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	a.unmaps = nil
+	a.free = nil
+	a.released = make(map[int][]Embedding)
+	return firstErr
+}
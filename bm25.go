@@ -0,0 +1,96 @@
+package gptbot
+
+import (
+	"math"
+)
+
+const (
+	bm25K1 = 1.2
+	bm25B  = 0.75
+)
+
+// bm25Index is an inverted index for scoring chunks by using Okapi BM25.
+//
+// See https://en.wikipedia.org/wiki/Okapi_BM25.
+type bm25Index struct {
+	// postings maps each term to the frequencies of the term in chunks.
+	postings map[string]map[*Chunk]int
+	lengths  map[*Chunk]int
+	totalLen int
+}
+
+func newBM25Index() *bm25Index {
+	return &bm25Index{
+		postings: make(map[string]map[*Chunk]int),
+		lengths:  make(map[*Chunk]int),
+	}
+}
+
+func (idx *bm25Index) Insert(chunks ...*Chunk) {
+	for _, chunk := range chunks {
+		if _, ok := idx.lengths[chunk]; ok {
+			continue
+		}
+
+		ts := terms(chunk.Text)
+		for _, t := range ts {
+			if idx.postings[t] == nil {
+				idx.postings[t] = make(map[*Chunk]int)
+			}
+			idx.postings[t][chunk]++
+		}
+
+		idx.lengths[chunk] = len(ts)
+		idx.totalLen += len(ts)
+	}
+}
+
+func (idx *bm25Index) Delete(chunks ...*Chunk) {
+	for _, chunk := range chunks {
+		length, ok := idx.lengths[chunk]
+		if !ok {
+			continue
+		}
+
+		for _, t := range terms(chunk.Text) {
+			delete(idx.postings[t], chunk)
+			if len(idx.postings[t]) == 0 {
+				delete(idx.postings, t)
+			}
+		}
+
+		delete(idx.lengths, chunk)
+		idx.totalLen -= length
+	}
+}
+
+// Score returns the BM25 scores of the chunks matching any term of the given text.
+func (idx *bm25Index) Score(text string) map[*Chunk]float64 {
+	scores := make(map[*Chunk]float64)
+
+	n := float64(len(idx.lengths))
+	if n == 0 {
+		return scores
+	}
+	avgLen := float64(idx.totalLen) / n
+
+	seen := make(map[string]bool)
+	for _, t := range terms(text) {
+		if seen[t] {
+			continue
+		}
+		seen[t] = true
+
+		posting := idx.postings[t]
+		df := float64(len(posting))
+		idf := math.Log((n-df+0.5)/(df+0.5) + 1)
+
+		for chunk, tf := range posting {
+			f := float64(tf)
+			length := float64(idx.lengths[chunk])
+			scores[chunk] += idf * f * (bm25K1 + 1) / (f + bm25K1*(1-bm25B+bm25B*length/avgLen))
+		}
+	}
+
+	return scores
+}
@@ -3,8 +3,11 @@ package gptbot
 import (
 	"bytes"
 	"context"
+	"fmt"
 	"strings"
 	"text/template"
+
+	"github.com/go-aie/xslices"
 )
 
 // Turn represents a round of dialogue.
@@ -38,6 +41,58 @@ type BotConfig struct {
 	// Defaults to 3.
 	TopK int
 
+	// MMR specifies whether to use Maximal Marginal Relevance (MMR) for selecting
+	// the TopK similarities, which helps avoid near-duplicate sections in the prompt.
+	//
+	// If enabled, MMRFetchK candidates will be retrieved first, and then TopK ones
+	// will be re-selected by balancing relevance and diversity. Note that MMR
+	// requires the embeddings of the candidates. If the Querier does not return
+	// them, they will be re-encoded by Encoder.
+	MMR bool
+
+	// MMRFetchK is the number of candidates to retrieve for MMR.
+	// Defaults to 4*TopK.
+	MMRFetchK int
+
+	// MMRLambda specifies the degree of relevance for MMR, between 0 and 1.
+	// Higher values favor relevance while lower values favor diversity.
+	// Defaults to 0.5.
+	MMRLambda float64
+
+	// Reranker is an optional reranker, which reorders and/or filters the
+	// retrieved similarities before constructing the prompt.
+	//
+	// If specified, RerankFetchK candidates will be retrieved first to give the
+	// reranker room, and then the top TopK ones will be selected after reranking.
+	// If MMR is also enabled, the reranked candidates will be re-selected by MMR.
+	Reranker Reranker
+
+	// RerankFetchK is the number of candidates to retrieve for Reranker.
+	// Defaults to 4*TopK.
+	RerankFetchK int
+
+	// MinScore specifies the minimum score of the similarities to construct the prompt.
+	// Similarities with lower scores are considered irrelevant and will be discarded.
+	// Defaults to nil, which means no filtering.
+	//
+	// Note that the scores depend on the metric of the Querier (see Metric),
+	// e.g. the cosine scores may be negative, thus any value (including 0)
+	// is a valid threshold.
+	MinScore *float64
+
+	// NoContext specifies the behavior when no similarity is relevant (see MinScore),
+	// or all the relevant ones are dropped to fit the prompt within ContextLength.
+	// Defaults to NoContextAsk.
+	NoContext NoContextBehavior
+
+	// NoContextAnswer is the canned answer for NoContextCannedAnswer.
+	// Defaults to "I don't know.".
+	NoContextAnswer string
+
+	// NoContextPromptTmpl is the prompt template for NoContextGeneralKnowledge.
+	// Defaults to DefaultNoContextPromptTmpl.
+	NoContextPromptTmpl string
+
 	// Temperature specifies the sampling temperature to use, between 0 and 1.
 	// Higher values like 0.8 will make the output more random, while lower values
 	// like 0.2 will make it more focused and deterministic. Defaults to 0.7.
@@ -51,10 +106,29 @@ type BotConfig struct {
 	// Defaults to 256.
 	MaxTokens int
 
+	// ContextLength is the maximum number of tokens, including both the prompt and
+	// the answer, supported by the model. Defaults to Model.ContextLength().
+	//
+	// When constructing the prompt, the similarities will be packed in the order
+	// given by the selection stage (i.e. by MMR if enabled, otherwise by Reranker
+	// if specified, or by their scores), as many as fit within ContextLength minus
+	// MaxTokens (i.e. the tokens reserved for the answer). The remaining ones will
+	// be truncated or dropped. A negative value means no limit.
+	ContextLength int
+
+	// SystemPrompt is an optional system message, which will be sent to the
+	// backend system before the prompt, to set the behavior of the model.
+	SystemPrompt string
+
 	// PromptTmpl specifies a custom prompt template for single-turn mode.
-	// Defaults to DefaultPromptTmpl.
+	// Defaults to DefaultPromptTmpl, or DefaultCitationPromptTmpl if Citation is enabled.
 	PromptTmpl string
 
+	// Citation specifies whether to number the sections (e.g. "[1] ...") in the
+	// prompt, so that the model can cite them in the answer. The citations will
+	// be parsed from the answer and mapped back to the corresponding sources.
+	Citation bool
+
 	// MultiTurnPromptTmpl specifies a custom prompt template for multi-turn mode.
 	// Defaults to DefaultMultiTurnPromptTmpl.
 	//
@@ -67,9 +141,33 @@ type BotConfig struct {
 	// extra frontend agent, who can respond directly to the user for casual greetings,
 	// and can refine incomplete questions according to the conversation history
 	// before consulting the backend system (i.e. the single-turn Question Answering Bot).
+	//
+	// If NativeMultiTurn is enabled, MultiTurnPromptTmpl will be used as the system
+	// message of the frontend agent instead, and defaults to DefaultMultiTurnSystemPromptTmpl.
 	MultiTurnPromptTmpl string
+
+	// NativeMultiTurn specifies whether to pass the conversation history to the
+	// frontend agent as native chat messages (i.e. alternate user and assistant
+	// messages), rather than rendering it into MultiTurnPromptTmpl as text.
+	NativeMultiTurn bool
 }
 
+// NoContextBehavior specifies what the bot will do if no relevant context
+// (i.e. similarity) is found for the question.
+type NoContextBehavior string
+
+const (
+	// NoContextAsk asks the LLM with the prompt anyway, even though there is no context.
+	NoContextAsk NoContextBehavior = "ask"
+
+	// NoContextCannedAnswer skips the LLM call and returns BotConfig.NoContextAnswer directly.
+	NoContextCannedAnswer NoContextBehavior = "canned_answer"
+
+	// NoContextGeneralKnowledge asks the LLM to answer the question with its general
+	// knowledge, by using BotConfig.NoContextPromptTmpl.
+	NoContextGeneralKnowledge NoContextBehavior = "general_knowledge"
+)
+
 func (cfg *BotConfig) init() {
 	if cfg.Model == "" {
 		cfg.Model = GPT3Dot5Turbo
@@ -77,21 +175,51 @@ func (cfg *BotConfig) init() {
 	if cfg.TopK == 0 {
 		cfg.TopK = 3
 	}
+	if cfg.MMRFetchK == 0 {
+		cfg.MMRFetchK = 4 * cfg.TopK
+	}
+	if cfg.RerankFetchK == 0 {
+		cfg.RerankFetchK = 4 * cfg.TopK
+	}
+	if cfg.MMRLambda == 0 {
+		cfg.MMRLambda = 0.5
+	}
 	if cfg.Temperature == 0 {
 		cfg.Temperature = 0.7
 	}
 	if cfg.MaxTokens == 0 {
 		cfg.MaxTokens = 256
 	}
+	if cfg.ContextLength == 0 {
+		cfg.ContextLength = cfg.Model.ContextLength()
+	}
+	if cfg.NoContext == "" {
+		cfg.NoContext = NoContextAsk
+	}
+	if cfg.NoContextAnswer == "" {
+		cfg.NoContextAnswer = "I don't know."
+	}
+	if cfg.NoContextPromptTmpl == "" {
+		cfg.NoContextPromptTmpl = DefaultNoContextPromptTmpl
+	}
 	if cfg.PromptTmpl == "" {
 		cfg.PromptTmpl = DefaultPromptTmpl
+		if cfg.Citation {
+			cfg.PromptTmpl = DefaultCitationPromptTmpl
+		}
 	}
 	if cfg.MultiTurnPromptTmpl == "" {
 		cfg.MultiTurnPromptTmpl = DefaultMultiTurnPromptTmpl
+		if cfg.NativeMultiTurn {
+			cfg.MultiTurnPromptTmpl = DefaultMultiTurnSystemPromptTmpl
+		}
 	}
 	if cfg.Engine == nil {
 		cfg.Engine = NewOpenAIChatEngine(cfg.APIKey, cfg.Model)
 	}
+	if r, ok := cfg.Reranker.(*LLMReranker); ok && r.Engine == nil {
+		r.Engine = cfg.Engine
+	}
 }
 
 type EngineMessage struct {
@@ -113,23 +241,48 @@ type Engine interface {
 	Infer(context.Context, *EngineRequest) (*EngineResponse, error)
 }
 
+// StreamingEngine is an Engine, which is also capable of streaming the answer
+// as it is generated.
+type StreamingEngine interface {
+	Engine
+
+	// InferStream calls fn with each text delta of the answer in order. If fn
+	// returns an error, the streaming will be stopped and the error will be returned.
+	InferStream(ctx context.Context, req *EngineRequest, fn func(delta string) error) error
+}
+
 type Encoder interface {
 	Encode(cxt context.Context, text string) (Embedding, error)
 	EncodeBatch(cxt context.Context, texts []string) ([]Embedding, error)
 }
 
 type Querier interface {
-	Query(ctx context.Context, embedding Embedding, corpusID string, topK int) ([]*Similarity, error)
+	// Query returns the topK chunks most similar to the given embedding.
+	// The chunks are restricted to the given corpus if corpusID is not empty,
+	// and to the ones whose attributes match the filter if filter is not nil.
+	Query(ctx context.Context, embedding Embedding, corpusID string, topK int, filter *Filter) ([]*Similarity, error)
+}
+
+// TextQuerier is a Querier, which also makes use of the question text for
+// searching (e.g. keyword search). If the Querier of Bot implements TextQuerier,
+// QueryText will be used instead of Query.
+type TextQuerier interface {
+	Querier
+	QueryText(ctx context.Context, text string, embedding Embedding, corpusID string, topK int, filter *Filter) ([]*Similarity, error)
 }
 
 type Bot struct {
-	cfg *BotConfig
+	cfg       *BotConfig
+	tokenizer *dummyTokenizer
 }
 
 // NewBot support single and multi-turn chat request
 func NewBot(cfg *BotConfig) *Bot {
 	cfg.init()
-	bot := &Bot{cfg: cfg}
+	bot := &Bot{
+		cfg:       cfg,
+		tokenizer: newDummyTokenizer(),
+	}
 
 	return bot
 }
@@ -137,80 +290,345 @@ func NewBot(cfg *BotConfig) *Bot {
 // Chat answers the given question in single-turn mode by default. If ChatHistory with non-empty history
 // is specified, multi-turn mode will be enabled. See BotConfig.MultiTurnPromptTmpl for more details.
 func (b *Bot) Chat(ctx context.Context, question string, options ...ChatOption) (answer string, debug *Debug, err error) {
-	opts := new(chatOptions)
-	for _, option := range options {
-		option(opts)
+	answer, _, debug, err = b.ChatWithSources(ctx, question, options...)
+	return
+}
+
+// ChatWithSources is like Chat, but also returns the sources (i.e. the document
+// chunks) used to generate the answer. See BotConfig.Citation for how to know
+// which sources are actually cited in the answer.
+func (b *Bot) ChatWithSources(ctx context.Context, question string, options ...ChatOption) (answer string, sources []*Source, debug *Debug, err error) {
+	opts := newChatOptions(options...)
+	if opts.Debug {
+		debug = new(Debug)
+		ctx = newContext(ctx, debug)
+	}
+
+	p, err := b.prompt(ctx, question, opts)
+	if err != nil {
+		return "", nil, debug, err
+	}
+	if p.Text == "" {
+		return p.Reply, nil, debug, nil
+	}
+
+	answer, err = b.chat(ctx, b.backendMessages(p.Text), b.cfg.Temperature)
+	if err != nil {
+		return "", nil, debug, err
 	}
 
+	return answer, b.sources(p, answer), debug, nil
+}
+
+// ChatStream is like Chat, but streams the answer as it is generated. If the
+// engine is not a StreamingEngine, the whole answer will be sent as one delta.
+//
+// Note that in multi-turn mode, the reply of the frontend agent is buffered
+// internally, and only the answer of the backend system will be streamed.
+//
+// The caller should close the returned stream once it's no longer used (e.g.
+// by deferring ChatStream.Close), which stops the generation if the deltas
+// are not drained.
+func (b *Bot) ChatStream(ctx context.Context, question string, options ...ChatOption) (*ChatStream, error) {
+	opts := newChatOptions(options...)
+
+	var debug *Debug
 	if opts.Debug {
 		debug = new(Debug)
 		ctx = newContext(ctx, debug)
 	}
 
-	if len(opts.History) > 0 {
-		answer, err = b.multiTurnChat(ctx, question, opts)
-		return
+	p, err := b.prompt(ctx, question, opts)
+	if err != nil {
+		return nil, err
 	}
 
-	answer, err = b.singleTurnChat(ctx, question, opts)
-	return
+	// The generation will be stopped once the stream is closed.
+	ctx, cancel := context.WithCancel(ctx)
+
+	deltas := make(chan string)
+	s := &ChatStream{
+		deltas: deltas,
+		debug:  debug,
+		cancel: cancel,
+		done:   make(chan struct{}),
+	}
+
+	go func() {
+		defer close(s.done)
+		defer cancel()
+		defer close(deltas)
+
+		send := func(delta string) error {
+			select {
+			case deltas <- delta:
+				return nil
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+		}
+
+		if p.Text == "" {
+			s.err = send(p.Reply)
+			return
+		}
+
+		// Also collect the answer for parsing citations.
+		var answer strings.Builder
+		s.err = b.chatStream(ctx, b.backendMessages(p.Text), b.cfg.Temperature, func(delta string) error {
+			answer.WriteString(delta)
+			return send(delta)
+		})
+		if s.err == nil {
+			s.sources = b.sources(p, answer.String())
+		}
+	}()
+
+	return s, nil
+}
+
+// chatPrompt is the prompt for the backend system.
+type chatPrompt struct {
+	// Text is the prompt text, which is empty if the frontend agent
+	// responds directly to the user in multi-turn mode.
+	Text string
+
+	// Reply is the direct reply of the frontend agent, if any.
+	Reply string
+
+	// Similarities are the similarities used to construct the prompt.
+	Similarities []*Similarity
+}
+
+func (b *Bot) prompt(ctx context.Context, question string, opts *chatOptions) (*chatPrompt, error) {
+	if len(opts.History) > 0 {
+		return b.multiTurnPrompt(ctx, question, opts)
+	}
+	return b.singleTurnPrompt(ctx, question, opts)
 }
 
-func (b *Bot) multiTurnChat(ctx context.Context, question string, opts *chatOptions) (string, error) {
+func (b *Bot) multiTurnPrompt(ctx context.Context, question string, opts *chatOptions) (*chatPrompt, error) {
 	prefix := "QUERY:"
 
+	messages, err := b.frontendMessages(question, opts.History, prefix)
+	if err != nil {
+		return nil, err
+	}
+
+	// Here we set temperature to 0 since we want the output to be focused and deterministic.
+	refinedQuestionOrReply, err := b.chat(ctx, messages, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	// Save the reply of the frontend agent for debugging purposes.
+	if debug, ok := fromContext(ctx); ok {
+		debug.FrontendReply = refinedQuestionOrReply
+	}
+
+	if strings.HasPrefix(refinedQuestionOrReply, prefix) {
+		return b.singleTurnPrompt(ctx, refinedQuestionOrReply[len(prefix):], opts)
+	} else {
+		return &chatPrompt{Reply: refinedQuestionOrReply}, nil
+	}
+}
+
+// frontendMessages returns the messages sent to the frontend agent in multi-turn mode.
+func (b *Bot) frontendMessages(question string, history []*Turn, prefix string) ([]*EngineMessage, error) {
 	t := PromptTemplate(b.cfg.MultiTurnPromptTmpl)
 	prompt, err := t.Render(struct {
 		Turns    []*Turn
 		Question string
 		Prefix   string
 	}{
-		Turns:    opts.History,
+		Turns:    history,
 		Question: question,
 		Prefix:   prefix,
 	})
 	if err != nil {
-		return "", err
+		return nil, err
 	}
 
-	// Here we set temperature to 0 since we want the output to be focused and deterministic.
-	refinedQuestionOrReply, err := b.chat(ctx, prompt, 0)
+	if !b.cfg.NativeMultiTurn {
+		return []*EngineMessage{{Role: "user", Content: prompt}}, nil
+	}
+
+	messages := []*EngineMessage{{Role: "system", Content: prompt}}
+	for _, turn := range history {
+		messages = append(messages,
+			&EngineMessage{Role: "user", Content: turn.Question},
+			&EngineMessage{Role: "assistant", Content: turn.Answer},
+		)
+	}
+	return append(messages, &EngineMessage{Role: "user", Content: question}), nil
+}
+
+func (b *Bot) singleTurnPrompt(ctx context.Context, question string, opts *chatOptions) (*chatPrompt, error) {
+	similarities, err := b.cfg.retrieve(ctx, question, opts)
 	if err != nil {
-		return "", err
+		return nil, err
 	}
 
-	// Save the reply of the frontend agent for debugging purposes.
-	if debug, ok := fromContext(ctx); ok {
-		debug.FrontendReply = refinedQuestionOrReply
+	debug, _ := fromContext(ctx)
+
+	var prompt string
+	var tokens int
+	if len(similarities) > 0 {
+		prompt, similarities, tokens, err = b.packPrompt(b.cfg.PromptTmpl, question, similarities)
+		if err != nil {
+			return nil, err
+		}
 	}
 
-	if strings.HasPrefix(refinedQuestionOrReply, prefix) {
-		return b.singleTurnChat(ctx, refinedQuestionOrReply[len(prefix):], opts)
-	} else {
-		return refinedQuestionOrReply, nil
+	// There is no context if no similarity is relevant, or all the relevant
+	// ones are dropped due to the token budget.
+	if len(similarities) == 0 {
+		if debug != nil {
+			debug.NoContext = true
+		}
+
+		tmpl := b.cfg.PromptTmpl
+		switch b.cfg.NoContext {
+		case NoContextCannedAnswer:
+			return &chatPrompt{Reply: b.cfg.NoContextAnswer}, nil
+		case NoContextGeneralKnowledge:
+			tmpl = b.cfg.NoContextPromptTmpl
+		}
+
+		prompt, similarities, tokens, err = b.packPrompt(tmpl, question, nil)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	// Save the prompt of the backend system for debugging purposes.
+	if debug != nil {
+		debug.BackendPrompt = prompt
+		debug.SectionCount = len(similarities)
+		debug.PromptTokens = tokens
 	}
+
+	return &chatPrompt{
+		Text:         prompt,
+		Similarities: similarities,
+	}, nil
 }
 
-func (b *Bot) singleTurnChat(ctx context.Context, question string, opts *chatOptions) (string, error) {
-	prompt, err := b.cfg.constructPrompt(ctx, question, opts)
+// messageTokenOverhead is the estimated number of extra tokens consumed by
+// each message, besides its content, in a chat request.
+const messageTokenOverhead = 8
+
+// minTruncatedSectionTokens is the minimum number of tokens of a truncated section.
+// Sections shorter than this are considered not informative and will be dropped.
+const minTruncatedSectionTokens = 32
+
+// packPrompt constructs the prompt with as many similarities as fit within the
+// token budget, and returns the prompt along with the similarities included
+// and the number of tokens in the prompt.
+//
+// Note that similarities are assumed to be in the order of preference given
+// by the selection stage (see BotConfig.retrieve), which is not necessarily the
+// order of their scores (e.g. after MMR or reranking). The least preferred
+// ones, i.e. the last ones, will be truncated or dropped first.
+func (b *Bot) packPrompt(tmpl, question string, similarities []*Similarity) (string, []*Similarity, int, error) {
+	render := func(similarities []*Similarity) (string, int, error) {
+		prompt, err := b.cfg.constructPrompt(tmpl, question, similarities)
+		if err != nil {
+			return "", 0, err
+		}
+		tokens, err := b.tokenizer.Count(prompt)
+		if err != nil {
+			return "", 0, err
+		}
+		return prompt, tokens, nil
+	}
+
+	budget, err := b.promptBudget()
 	if err != nil {
-		return "", err
+		return "", nil, 0, err
 	}
 
-	// Save the prompt of the backend system for debugging purposes.
-	if debug, ok := fromContext(ctx); ok {
-		debug.BackendPrompt = prompt
+	// Drop the least preferred similarities until the prompt fits.
+	n := len(similarities)
+	prompt, tokens, err := render(similarities)
+	if err != nil {
+		return "", nil, 0, err
+	}
+	for budget > 0 && tokens > budget && n > 0 {
+		n--
+		if prompt, tokens, err = render(similarities[:n]); err != nil {
+			return "", nil, 0, err
+		}
+	}
+
+	if budget <= 0 || n == len(similarities) {
+		return prompt, similarities, tokens, nil
 	}
 
-	return b.chat(ctx, prompt, b.cfg.Temperature)
+	// Try to make use of the remaining tokens by truncating the
+	// most preferred similarity among the dropped ones.
+	for remaining := budget - tokens; remaining >= minTruncatedSectionTokens; {
+		truncated, err := b.truncate(similarities[n], remaining)
+		if err != nil {
+			return "", nil, 0, err
+		}
+
+		candidates := append(similarities[:n:n], truncated)
+		p, t, err := render(candidates)
+		if err != nil {
+			return "", nil, 0, err
+		}
+		if t <= budget {
+			return p, candidates, t, nil
+		}
+
+		// Take the overhead of the section (e.g. its bullet or number) into account.
+		remaining -= t - budget
+	}
+
+	return prompt, similarities[:n], tokens, nil
 }
 
-func (b *Bot) chat(ctx context.Context, prompt string, temperature float64) (string, error) {
-	req := &EngineRequest{
-		Messages:    []*EngineMessage{{Role: "user", Content: prompt}},
-		Temperature: temperature,
-		MaxTokens:   b.cfg.MaxTokens,
+// promptBudget returns the maximum number of tokens of the backend prompt.
+// Zero means no limit.
+func (b *Bot) promptBudget() (int, error) {
+	if b.cfg.ContextLength <= 0 {
+		return 0, nil
+	}
+
+	budget := b.cfg.ContextLength - b.cfg.MaxTokens - messageTokenOverhead
+	if b.cfg.SystemPrompt != "" {
+		tokens, err := b.tokenizer.Count(b.cfg.SystemPrompt)
+		if err != nil {
+			return 0, err
+		}
+		budget -= tokens + messageTokenOverhead
+	}
+
+	// There is no room for the prompt, so keep it as small as possible.
+	if budget <= 0 {
+		budget = 1
 	}
+	return budget, nil
+}
+
+// truncate returns a copy of the similarity, whose text consumes at most tokenNum tokens.
+func (b *Bot) truncate(s *Similarity, tokenNum int) (*Similarity, error) {
+	runes, err := b.tokenizer.Encode([]rune(s.Text), tokenNum)
+	if err != nil {
+		return nil, err
+	}
+
+	chunk := *s.Chunk
+	chunk.Text = string(runes)
+	return &Similarity{
+		Chunk: &chunk,
+		Score: s.Score,
+	}, nil
+}
+
+func (b *Bot) chat(ctx context.Context, messages []*EngineMessage, temperature float64) (string, error) {
+	req := b.engineRequest(messages, temperature)
 	resp, err := b.cfg.Engine.Infer(ctx, req)
 	if err != nil {
 		return "", err
@@ -219,23 +637,141 @@ func (b *Bot) chat(ctx context.Context, prompt string, temperature float64) (str
 	return resp.Text, nil
 }
 
-func (b *BotConfig) constructPrompt(ctx context.Context, question string, opts *chatOptions) (string, error) {
+func (b *Bot) chatStream(ctx context.Context, messages []*EngineMessage, temperature float64, fn func(delta string) error) error {
+	req := b.engineRequest(messages, temperature)
+
+	engine, ok := b.cfg.Engine.(StreamingEngine)
+	if !ok {
+		// Fall back to the non-streaming engine.
+		resp, err := b.cfg.Engine.Infer(ctx, req)
+		if err != nil {
+			return err
+		}
+		return fn(resp.Text)
+	}
+
+	return engine.InferStream(ctx, req, fn)
+}
+
+// backendMessages returns the messages sent to the backend system.
+func (b *Bot) backendMessages(prompt string) []*EngineMessage {
+	var messages []*EngineMessage
+	if b.cfg.SystemPrompt != "" {
+		messages = append(messages, &EngineMessage{Role: "system", Content: b.cfg.SystemPrompt})
+	}
+	return append(messages, &EngineMessage{Role: "user", Content: prompt})
+}
+
+func (b *Bot) engineRequest(messages []*EngineMessage, temperature float64) *EngineRequest {
+	return &EngineRequest{
+		Messages:    messages,
+		Temperature: temperature,
+		MaxTokens:   b.cfg.MaxTokens,
+	}
+}
+
+// retrieve returns the similarities relevant to the question, in the order of
+// preference: the order of selection if MMR is enabled, otherwise the order
+// given by the Reranker if specified, or by the Querier.
+func (b *BotConfig) retrieve(ctx context.Context, question string, opts *chatOptions) ([]*Similarity, error) {
 	emb, err := b.Encoder.Encode(ctx, question)
 	if err != nil {
-		return "", err
+		return nil, err
 	}
 
-	similarities, err := b.Querier.Query(ctx, emb, opts.CorpusID, b.TopK)
+	topK := b.TopK
+	if b.MMR {
+		topK = xslices.Max(topK, b.MMRFetchK)
+	}
+	if b.Reranker != nil {
+		topK = xslices.Max(topK, b.RerankFetchK)
+	}
+
+	var similarities []*Similarity
+	if q, ok := b.Querier.(TextQuerier); ok {
+		similarities, err = q.QueryText(ctx, question, emb, opts.CorpusID, topK, opts.Filter)
+	} else {
+		similarities, err = b.Querier.Query(ctx, emb, opts.CorpusID, topK, opts.Filter)
+	}
 	if err != nil {
-		return "", err
+		return nil, err
+	}
+	similarities = b.filter(similarities)
+
+	if b.Reranker != nil {
+		similarities, err = b.Reranker.Rerank(ctx, question, similarities)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if b.MMR {
+		if err := b.fillEmbeddings(ctx, similarities); err != nil {
+			return nil, err
+		}
+		return mmr(emb, similarities, b.TopK, b.MMRLambda), nil
 	}
 
+	if len(similarities) > b.TopK {
+		similarities = similarities[:b.TopK]
+	}
+	return similarities, nil
+}
+
+// fillEmbeddings encodes the texts of the similarities without embeddings.
+func (b *BotConfig) fillEmbeddings(ctx context.Context, similarities []*Similarity) error {
 	var texts []string
+	var missing []*Similarity
+	for _, s := range similarities {
+		if len(s.Embedding) == 0 {
+			texts = append(texts, s.Text)
+			missing = append(missing, s)
+		}
+	}
+	if len(missing) == 0 {
+		return nil
+	}
+
+	embeddings, err := b.Encoder.EncodeBatch(ctx, texts)
+	if err != nil {
+		return err
+	}
+
+	for i, s := range missing {
+		// Do not modify the chunk, which may be shared with the Querier.
+		chunk := *s.Chunk
+		chunk.Embedding = embeddings[i]
+		s.Chunk = &chunk
+	}
+	return nil
+}
+
+// filter discards the similarities whose scores do not reach MinScore.
+func (b *BotConfig) filter(similarities []*Similarity) []*Similarity {
+	if b.MinScore == nil {
+		return similarities
+	}
+
+	var result []*Similarity
 	for _, s := range similarities {
-		texts = append(texts, s.Text)
+		if s.Score >= *b.MinScore {
+			result = append(result, s)
+		}
+	}
+	return result
+}
+
+func (b *BotConfig) constructPrompt(tmpl, question string, similarities []*Similarity) (string, error) {
+	var texts []string
+	for i, s := range similarities {
+		text := s.Text
+		if b.Citation {
+			text = fmt.Sprintf("[%d] %s", i+1, text)
+		}
+		texts = append(texts, text)
 	}
 
-	p := PromptTemplate(b.PromptTmpl)
+	p := PromptTemplate(tmpl)
 	return p.Render(PromptData{
 		Question: question,
 		Sections: texts,
@@ -245,9 +781,18 @@ func (b *BotConfig) constructPrompt(ctx context.Context, question string, opts *
 type chatOptions struct {
 	Debug    bool
 	CorpusID string
+	Filter   *Filter
 	History  []*Turn
 }
 
+func newChatOptions(options ...ChatOption) *chatOptions {
+	opts := new(chatOptions)
+	for _, option := range options {
+		option(opts)
+	}
+	return opts
+}
+
 type ChatOption func(opts *chatOptions)
 
 func ChatDebug(debug bool) ChatOption {
@@ -262,6 +807,12 @@ func ChatCorpusID(corpusID string) ChatOption {
 	return func(opts *chatOptions) { opts.CorpusID = corpusID }
 }
 
+// ChatFilter restricts the retrieved chunks to the ones whose metadata
+// attributes match the given filter.
+func ChatFilter(filter *Filter) ChatOption {
+	return func(opts *chatOptions) { opts.Filter = filter }
+}
+
 type PromptData struct {
 	Question string
 	Sections []string
@@ -293,6 +844,26 @@ Context:
 * {{.}}
 {{- end}}
 
+Q: {{.Question}}
+A:
+`
+
+	DefaultCitationPromptTmpl = `
+Answer the question as truthfully as possible using the provided context, and if the answer is not contained within the text below, say "I don't know."
+Cite the numbers of the sections you use at the end of the corresponding sentences, e.g. [1] or [1][2].
+
+Context:
+
+{{range .Sections -}}
+{{.}}
+{{end}}
+Q: {{.Question}}
+A:
+`
+
+	DefaultNoContextPromptTmpl = `
+Answer the question as truthfully as possible using your general knowledge, and if you don't know the answer, say "I don't know."
+
 Q: {{.Question}}
 A:
 `
@@ -322,12 +893,87 @@ Agent: {{.Answer}}
 {{- end}}
 User: {{$.Question}}
 Agent:
+`
+
+	DefaultMultiTurnSystemPromptTmpl = `You are an Agent who communicates with the User, with a System available for answering queries. Your responsibilities include:
+1. For greetings and pleasantries, respond directly to the User;
+2. For other questions, if you cannot understand them, ask the User directly; otherwise, be sure to begin with "{{$.Prefix}}" when querying the System.
+
+Example 1:
+User: What is GPT-3?
+Agent: {{$.Prefix}} What is GPT-3?
+
+Example 2:
+User: How many parameters does it use?
+Agent: Sorry, I don't quite understand what you mean.
+
+Example 3:
+User: What is GPT-3?
+Agent: GPT-3 is an AI model.
+User: How many parameters does it use?
+Agent: {{$.Prefix}} How many parameters does GPT-3 use?
 `
 )
 
+// ChatStream is a streaming answer returned by Bot.ChatStream.
+type ChatStream struct {
+	deltas  <-chan string
+	sources []*Source
+	debug   *Debug
+	err     error
+	cancel  context.CancelFunc
+	done    chan struct{}
+}
+
+// Deltas returns a channel, from which the text deltas of the answer can be
+// received in order. The channel will be closed once the answer is complete
+// or an error occurs.
+func (s *ChatStream) Deltas() <-chan string {
+	return s.deltas
+}
+
+// Sources waits until the stream is finished and then returns the sources
+// used to generate the answer, if any.
+func (s *ChatStream) Sources() []*Source {
+	<-s.done
+	return s.sources
+}
+
+// Err waits until the stream is finished and then returns the error, if any.
+func (s *ChatStream) Err() error {
+	<-s.done
+	return s.err
+}
+
+// Debug waits until the stream is finished and then returns the debugging
+// information, which is non-nil only if ChatDebug is enabled.
+func (s *ChatStream) Debug() *Debug {
+	<-s.done
+	return s.debug
+}
+
+// Close stops the stream if it's not finished yet, in which case Err will
+// return context.Canceled, and then waits until the stream is finished.
+// It's safe to call Close multiple times, or after the stream is drained.
+func (s *ChatStream) Close() error {
+	s.cancel()
+	<-s.done
+	return nil
+}
+
 type Debug struct {
 	FrontendReply string `json:"frontend_reply,omitempty"`
 	BackendPrompt string `json:"backend_prompt,omitempty"`
+
+	// NoContext reports whether no relevant context is found for the question,
+	// or none of it fits in the prompt.
+	NoContext bool `json:"no_context,omitempty"`
+
+	// SectionCount is the number of sections included in the backend prompt.
+	SectionCount int `json:"section_count,omitempty"`
+
+	// PromptTokens is the number of tokens in the backend prompt.
+	PromptTokens int `json:"prompt_tokens,omitempty"`
 }
 
 type contextKeyT string
@@ -2,11 +2,14 @@ package gptbot_test
 
 import (
 	"context"
+	"errors"
+	"fmt"
 	"os"
 	"strings"
 	"testing"
 
 	"github.com/go-aie/gptbot"
+	"github.com/google/go-cmp/cmp"
 )
 
 func TestBot_Chat(t *testing.T) {
@@ -75,3 +78,696 @@ func TestBot_ChatWithHistory(t *testing.T) {
 		t.Errorf("unexpected answer: %s\n", answer)
 	}
 }
+
+func TestBot_ChatStream(t *testing.T) {
+	ctx := context.Background()
+
+	store := gptbot.NewLocalVectorStore()
+	_ = store.Insert(ctx, map[string][]*gptbot.Chunk{
+		"1": {
+			{
+				ID:         "1_0",
+				Text:       "GPT-3 was released in 2020.",
+				DocumentID: "1",
+				Embedding:  gptbot.Embedding{1, 0},
+			},
+		},
+	})
+
+	tests := []struct {
+		name           string
+		engine         *fakeEngine
+		history        []*gptbot.Turn
+		wantDeltas     []string
+		wantPromptSent bool
+	}{
+		{
+			name:           "single-turn",
+			engine:         &fakeEngine{answers: []string{"GPT-3 was released in 2020."}},
+			wantDeltas:     []string{"GPT-3 ", "was ", "released ", "in ", "2020."},
+			wantPromptSent: true,
+		},
+		{
+			name:   "multi-turn with query",
+			engine: &fakeEngine{answers: []string{"QUERY: When was GPT-3 released?", "In 2020."}},
+			history: []*gptbot.Turn{
+				{Question: "What is GPT-3?", Answer: "GPT-3 is an AI model."},
+			},
+			wantDeltas:     []string{"In ", "2020."},
+			wantPromptSent: true,
+		},
+		{
+			name:   "multi-turn with direct reply",
+			engine: &fakeEngine{answers: []string{"Hello! How can I help you?"}},
+			history: []*gptbot.Turn{
+				{Question: "What is GPT-3?", Answer: "GPT-3 is an AI model."},
+			},
+			wantDeltas: []string{"Hello! How can I help you?"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			bot := gptbot.NewBot(&gptbot.BotConfig{
+				Engine:  tt.engine,
+				Encoder: fakeEncoder{},
+				Querier: store,
+			})
+
+			stream, err := bot.ChatStream(ctx, "When was it released?", gptbot.ChatHistory(tt.history...), gptbot.ChatDebug(true))
+			if err != nil {
+				t.Fatalf("err: %v\n", err)
+			}
+
+			var got []string
+			for delta := range stream.Deltas() {
+				got = append(got, delta)
+			}
+			if err := stream.Err(); err != nil {
+				t.Fatalf("err: %v\n", err)
+			}
+
+			if !cmp.Equal(got, tt.wantDeltas) {
+				diff := cmp.Diff(got, tt.wantDeltas)
+				t.Errorf("Want - Got: %s", diff)
+			}
+
+			gotPromptSent := stream.Debug().BackendPrompt != ""
+			if gotPromptSent != tt.wantPromptSent {
+				t.Errorf("BackendPrompt: got sent %v, want %v", gotPromptSent, tt.wantPromptSent)
+			}
+		})
+	}
+}
+
+func TestBot_ChatStreamClose(t *testing.T) {
+	ctx := context.Background()
+
+	store := gptbot.NewLocalVectorStore()
+	_ = store.Insert(ctx, map[string][]*gptbot.Chunk{
+		"1": {
+			{
+				ID:         "1_0",
+				Text:       "GPT-3 was released in 2020.",
+				DocumentID: "1",
+				Embedding:  gptbot.Embedding{1, 0},
+			},
+		},
+	})
+
+	bot := gptbot.NewBot(&gptbot.BotConfig{
+		Engine:  &fakeEngine{answers: []string{"GPT-3 was released in 2020."}},
+		Encoder: fakeEncoder{},
+		Querier: store,
+	})
+
+	stream, err := bot.ChatStream(ctx, "When was GPT-3 released?")
+	if err != nil {
+		t.Fatalf("err: %v\n", err)
+	}
+
+	// Read only the first delta, and then stop the stream.
+	if got, want := <-stream.Deltas(), "GPT-3 "; got != want {
+		t.Errorf("Delta: got %q, want %q", got, want)
+	}
+	if err := stream.Close(); err != nil {
+		t.Fatalf("err: %v\n", err)
+	}
+	if err := stream.Err(); !errors.Is(err, context.Canceled) {
+		t.Errorf("Err: got %v, want %v", err, context.Canceled)
+	}
+
+	// Closing again is a no-op.
+	if err := stream.Close(); err != nil {
+		t.Fatalf("err: %v\n", err)
+	}
+}
+
+func TestBot_ChatCitation(t *testing.T) {
+	ctx := context.Background()
+
+	store := gptbot.NewLocalVectorStore()
+	_ = store.Insert(ctx, map[string][]*gptbot.Chunk{
+		"1": {
+			{
+				ID:         "1_0",
+				Text:       "GPT-3 was released in 2020.",
+				DocumentID: "1",
+				Metadata:   gptbot.Metadata{CorpusID: "gpt"},
+				Embedding:  gptbot.Embedding{1, 0},
+			},
+		},
+		"2": {
+			{
+				ID:         "2_0",
+				Text:       "GPT-3 uses 175 billion parameters.",
+				DocumentID: "2",
+				Metadata:   gptbot.Metadata{CorpusID: "gpt"},
+				Embedding:  gptbot.Embedding{0.5, 0},
+			},
+		},
+	})
+
+	engine := &fakeEngine{answers: []string{"GPT-3 uses 175 billion parameters [2]."}}
+	bot := gptbot.NewBot(&gptbot.BotConfig{
+		Engine:   engine,
+		Encoder:  fakeEncoder{},
+		Querier:  store,
+		Citation: true,
+	})
+
+	answer, sources, _, err := bot.ChatWithSources(ctx, "How many parameters does GPT-3 use?")
+	if err != nil {
+		t.Fatalf("err: %v\n", err)
+	}
+	if answer != "GPT-3 uses 175 billion parameters [2]." {
+		t.Errorf("unexpected answer: %s\n", answer)
+	}
+
+	prompt := engine.requests[0].Messages[0].Content
+	if !strings.Contains(prompt, "[1] GPT-3 was released in 2020.") || !strings.Contains(prompt, "[2] GPT-3 uses 175 billion parameters.") {
+		t.Errorf("unexpected prompt: %s\n", prompt)
+	}
+
+	want := []*gptbot.Source{
+		{
+			Index:      1,
+			ChunkID:    "1_0",
+			DocumentID: "1",
+			CorpusID:   "gpt",
+			Score:      1,
+		},
+		{
+			Index:      2,
+			ChunkID:    "2_0",
+			DocumentID: "2",
+			CorpusID:   "gpt",
+			Score:      0.5,
+			Cited:      true,
+		},
+	}
+	if !cmp.Equal(sources, want) {
+		diff := cmp.Diff(sources, want)
+		t.Errorf("Want - Got: %s", diff)
+	}
+}
+
+func TestBot_ChatNativeMultiTurn(t *testing.T) {
+	ctx := context.Background()
+
+	store := gptbot.NewLocalVectorStore()
+	_ = store.Insert(ctx, map[string][]*gptbot.Chunk{
+		"1": {
+			{
+				ID:         "1_0",
+				Text:       "GPT-3 uses 175 billion parameters.",
+				DocumentID: "1",
+				Embedding:  gptbot.Embedding{1, 0},
+			},
+		},
+	})
+
+	engine := &fakeEngine{answers: []string{"QUERY: How many parameters does GPT-3 use?", "175 billion."}}
+	bot := gptbot.NewBot(&gptbot.BotConfig{
+		Engine:          engine,
+		Encoder:         fakeEncoder{},
+		Querier:         store,
+		SystemPrompt:    "You are a helpful assistant.",
+		NativeMultiTurn: true,
+	})
+
+	history := []*gptbot.Turn{
+		{Question: "What is GPT-3?", Answer: "GPT-3 is an AI model."},
+	}
+	answer, _, err := bot.Chat(ctx, "How many parameters does it use?", gptbot.ChatHistory(history...))
+	if err != nil {
+		t.Fatalf("err: %v\n", err)
+	}
+	if answer != "175 billion." {
+		t.Errorf("unexpected answer: %s\n", answer)
+	}
+
+	// Clear the contents of the system messages, which are long prompts.
+	var got [][]*gptbot.EngineMessage
+	for _, req := range engine.requests {
+		for _, m := range req.Messages {
+			if m.Role == "system" && m.Content != "You are a helpful assistant." {
+				m.Content = ""
+			}
+			if m.Role == "user" && strings.Contains(m.Content, "Context:") {
+				m.Content = ""
+			}
+		}
+		got = append(got, req.Messages)
+	}
+
+	want := [][]*gptbot.EngineMessage{
+		{
+			{Role: "system"},
+			{Role: "user", Content: "What is GPT-3?"},
+			{Role: "assistant", Content: "GPT-3 is an AI model."},
+			{Role: "user", Content: "How many parameters does it use?"},
+		},
+		{
+			{Role: "system", Content: "You are a helpful assistant."},
+			{Role: "user"},
+		},
+	}
+	if !cmp.Equal(got, want) {
+		diff := cmp.Diff(got, want)
+		t.Errorf("Want - Got: %s", diff)
+	}
+}
+
+func TestBot_ChatNoContext(t *testing.T) {
+	ctx := context.Background()
+
+	store := gptbot.NewLocalVectorStore()
+	_ = store.Insert(ctx, map[string][]*gptbot.Chunk{
+		"1": {
+			{
+				ID:         "1_0",
+				Text:       "GPT-3 was released in 2020.",
+				DocumentID: "1",
+				Embedding:  gptbot.Embedding{0.5, 0},
+			},
+		},
+	})
+
+	tests := []struct {
+		name         string
+		minScore     float64
+		noContext    gptbot.NoContextBehavior
+		wantAnswer   string
+		wantPrompt   string
+		wantRequests int
+	}{
+		{
+			name:         "relevant",
+			minScore:     0.5,
+			noContext:    gptbot.NoContextCannedAnswer,
+			wantAnswer:   "In 2020.",
+			wantPrompt:   "* GPT-3 was released in 2020.",
+			wantRequests: 1,
+		},
+		{
+			name:         "ask",
+			minScore:     0.8,
+			wantAnswer:   "In 2020.",
+			wantPrompt:   "using the provided context",
+			wantRequests: 1,
+		},
+		{
+			name:       "canned answer",
+			minScore:   0.8,
+			noContext:  gptbot.NoContextCannedAnswer,
+			wantAnswer: "I don't know.",
+		},
+		{
+			name:         "general knowledge",
+			minScore:     0.8,
+			noContext:    gptbot.NoContextGeneralKnowledge,
+			wantAnswer:   "In 2020.",
+			wantPrompt:   "using your general knowledge",
+			wantRequests: 1,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			engine := &fakeEngine{answers: []string{"In 2020."}}
+			minScore := tt.minScore
+			bot := gptbot.NewBot(&gptbot.BotConfig{
+				Engine:    engine,
+				Encoder:   fakeEncoder{},
+				Querier:   store,
+				MinScore:  &minScore,
+				NoContext: tt.noContext,
+			})
+
+			answer, debug, err := bot.Chat(ctx, "When was GPT-3 released?", gptbot.ChatDebug(true))
+			if err != nil {
+				t.Fatalf("err: %v\n", err)
+			}
+
+			if answer != tt.wantAnswer {
+				t.Errorf("answer: got %q, want %q", answer, tt.wantAnswer)
+			}
+			if len(engine.requests) != tt.wantRequests {
+				t.Fatalf("requests: got %d, want %d", len(engine.requests), tt.wantRequests)
+			}
+			if tt.wantRequests > 0 && !strings.Contains(debug.BackendPrompt, tt.wantPrompt) {
+				t.Errorf("unexpected prompt: %s\n", debug.BackendPrompt)
+			}
+			if wantNoContext := tt.minScore > 0.5; debug.NoContext != wantNoContext {
+				t.Errorf("NoContext: got %v, want %v", debug.NoContext, wantNoContext)
+			}
+		})
+	}
+}
+
+func TestBot_ChatMinScoreZero(t *testing.T) {
+	ctx := context.Background()
+
+	store := gptbot.NewLocalVectorStoreWithConfig(&gptbot.LocalVectorStoreConfig{Metric: gptbot.MetricCosine})
+	_ = store.Insert(ctx, map[string][]*gptbot.Chunk{
+		"1": {
+			{
+				ID:         "1_0",
+				Text:       "GPT-3 was released in 2020.",
+				DocumentID: "1",
+				// The cosine score is -1.
+				Embedding: gptbot.Embedding{-1, 0},
+			},
+		},
+	})
+
+	// A zero MinScore discards the similarities with negative scores,
+	// while a nil one disables the filtering.
+	zero := 0.0
+	for _, minScore := range []*float64{nil, &zero} {
+		bot := gptbot.NewBot(&gptbot.BotConfig{
+			Engine:    &fakeEngine{answers: []string{"In 2020."}},
+			Encoder:   fakeEncoder{},
+			Querier:   store,
+			MinScore:  minScore,
+			NoContext: gptbot.NoContextCannedAnswer,
+		})
+
+		_, debug, err := bot.Chat(ctx, "When was GPT-3 released?", gptbot.ChatDebug(true))
+		if err != nil {
+			t.Fatalf("err: %v\n", err)
+		}
+		if want := minScore != nil; debug.NoContext != want {
+			t.Errorf("MinScore set %v: NoContext: got %v, want %v", want, debug.NoContext, want)
+		}
+	}
+}
+
+func TestBot_ChatContextLength(t *testing.T) {
+	ctx := context.Background()
+
+	texts := []string{
+		strings.Repeat("Apple ", 100),
+		strings.Repeat("Banana ", 100),
+		strings.Repeat("Cherry ", 100),
+	}
+	store := gptbot.NewLocalVectorStore()
+	for i, text := range texts {
+		id := fmt.Sprint(i)
+		_ = store.Insert(ctx, map[string][]*gptbot.Chunk{
+			id: {
+				{
+					ID:         id + "_0",
+					Text:       strings.TrimSpace(text),
+					DocumentID: id,
+					Embedding:  gptbot.Embedding{float64(3 - i), 0},
+				},
+			},
+		})
+	}
+
+	// The prompt consumes about 47 tokens without sections, and each section
+	// consumes about 101 tokens.
+	tests := []struct {
+		name             string
+		contextLength    int
+		noContext        gptbot.NoContextBehavior
+		wantSectionCount int
+		wantTruncated    bool
+	}{
+		{
+			name:             "no limit",
+			contextLength:    -1,
+			wantSectionCount: 3,
+		},
+		{
+			name:             "all fit",
+			contextLength:    4096,
+			wantSectionCount: 3,
+		},
+		{
+			name:             "one dropped",
+			contextLength:    100 + 8 + 50 + 200,
+			wantSectionCount: 2,
+		},
+		{
+			name:             "one truncated",
+			contextLength:    100 + 8 + 50 + 160,
+			wantSectionCount: 2,
+			wantTruncated:    true,
+		},
+		{
+			name:             "all dropped",
+			contextLength:    100 + 8 + 50,
+			wantSectionCount: 0,
+		},
+		{
+			name:             "all dropped with canned answer",
+			contextLength:    100 + 8 + 50,
+			noContext:        gptbot.NoContextCannedAnswer,
+			wantSectionCount: 0,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			engine := &fakeEngine{answers: []string{"Fruits."}}
+			bot := gptbot.NewBot(&gptbot.BotConfig{
+				Engine:        engine,
+				Encoder:       fakeEncoder{},
+				Querier:       store,
+				MaxTokens:     100,
+				ContextLength: tt.contextLength,
+				NoContext:     tt.noContext,
+			})
+
+			answer, sources, debug, err := bot.ChatWithSources(ctx, "What fruits are there?", gptbot.ChatDebug(true))
+			if err != nil {
+				t.Fatalf("err: %v\n", err)
+			}
+
+			if wantNoContext := tt.wantSectionCount == 0; debug.NoContext != wantNoContext {
+				t.Errorf("NoContext: got %v, want %v", debug.NoContext, wantNoContext)
+			}
+			if tt.noContext == gptbot.NoContextCannedAnswer {
+				if answer != "I don't know." || len(engine.requests) != 0 {
+					t.Errorf("want the canned answer without requests, got %q with %d requests", answer, len(engine.requests))
+				}
+				return
+			}
+
+			if debug.SectionCount != tt.wantSectionCount {
+				t.Errorf("SectionCount: got %d, want %d", debug.SectionCount, tt.wantSectionCount)
+			}
+			if len(sources) != tt.wantSectionCount {
+				t.Errorf("len(sources): got %d, want %d", len(sources), tt.wantSectionCount)
+			}
+			if tt.wantSectionCount >= 2 {
+				gotTruncated := !strings.Contains(debug.BackendPrompt, strings.TrimSpace(texts[1]))
+				if gotTruncated != tt.wantTruncated {
+					t.Errorf("truncated: got %v, want %v", gotTruncated, tt.wantTruncated)
+				}
+			}
+			if budget := tt.contextLength - 100 - 8; tt.contextLength > 0 && debug.PromptTokens > budget {
+				t.Errorf("PromptTokens: got %d, want <= %d", debug.PromptTokens, budget)
+			}
+		})
+	}
+}
+
+func TestBot_ChatContextLengthSelectionOrder(t *testing.T) {
+	ctx := context.Background()
+
+	store := gptbot.NewLocalVectorStore()
+	_ = store.Insert(ctx, map[string][]*gptbot.Chunk{
+		"1": {{ID: "1_0", Text: strings.TrimSpace(strings.Repeat("Apple ", 100)), DocumentID: "1", Embedding: gptbot.Embedding{0.9, 0.1}}},
+		"2": {{ID: "2_0", Text: strings.TrimSpace(strings.Repeat("Banana ", 100)), DocumentID: "2", Embedding: gptbot.Embedding{0.89, 0.11}}},
+		"3": {{ID: "3_0", Text: strings.TrimSpace(strings.Repeat("Cherry ", 100)), DocumentID: "3", Embedding: gptbot.Embedding{0.7, -0.5}}},
+	})
+
+	// Only two sections fit (see TestBot_ChatContextLength), and the one
+	// dropped is the least preferred by the selection stage, which is not
+	// necessarily the lowest-scoring one.
+	tests := []struct {
+		name     string
+		mmr      bool
+		reranker gptbot.Reranker
+		want     []string
+	}{
+		{
+			name: "by score",
+			want: []string{"1_0", "2_0"},
+		},
+		{
+			name: "after mmr",
+			mmr:  true,
+			want: []string{"1_0", "3_0"},
+		},
+		{
+			name:     "after reranking",
+			reranker: reverseReranker{},
+			want:     []string{"3_0", "2_0"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			bot := gptbot.NewBot(&gptbot.BotConfig{
+				Engine:        &fakeEngine{answers: []string{"Fruits."}},
+				Encoder:       fakeEncoder{},
+				Querier:       store,
+				MMR:           tt.mmr,
+				Reranker:      tt.reranker,
+				MaxTokens:     100,
+				ContextLength: 100 + 8 + 50 + 200,
+			})
+
+			_, sources, _, err := bot.ChatWithSources(ctx, "What fruits are there?")
+			if err != nil {
+				t.Fatalf("err: %v\n", err)
+			}
+
+			var got []string
+			for _, s := range sources {
+				got = append(got, s.ChunkID)
+			}
+			if !cmp.Equal(got, tt.want) {
+				diff := cmp.Diff(got, tt.want)
+				t.Errorf("Want - Got: %s", diff)
+			}
+		})
+	}
+}
+
+func TestBot_ChatMMR(t *testing.T) {
+	ctx := context.Background()
+
+	store := gptbot.NewLocalVectorStore()
+	_ = store.Insert(ctx, map[string][]*gptbot.Chunk{
+		"1": {
+			{
+				ID:         "1_0",
+				Text:       "GPT-3 was released in 2020.",
+				DocumentID: "1",
+				Embedding:  gptbot.Embedding{0.9, 0.1},
+			},
+		},
+		"2": {
+			{
+				ID:         "2_0",
+				Text:       "GPT-3 was released in 2020!",
+				DocumentID: "2",
+				Embedding:  gptbot.Embedding{0.89, 0.11},
+			},
+		},
+		"3": {
+			{
+				ID:         "3_0",
+				Text:       "GPT-3 uses 175 billion parameters.",
+				DocumentID: "3",
+				Embedding:  gptbot.Embedding{0.7, -0.5},
+			},
+		},
+	})
+
+	tests := []struct {
+		name string
+		mmr  bool
+		want []string
+	}{
+		{
+			name: "without mmr",
+			want: []string{"1_0", "2_0"},
+		},
+		{
+			name: "with mmr",
+			mmr:  true,
+			want: []string{"1_0", "3_0"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			bot := gptbot.NewBot(&gptbot.BotConfig{
+				Engine:  &fakeEngine{answers: []string{"In 2020."}},
+				Encoder: fakeEncoder{},
+				Querier: store,
+				TopK:    2,
+				MMR:     tt.mmr,
+			})
+
+			_, sources, _, err := bot.ChatWithSources(ctx, "Tell me about GPT-3.")
+			if err != nil {
+				t.Fatalf("err: %v\n", err)
+			}
+
+			var got []string
+			for _, s := range sources {
+				got = append(got, s.ChunkID)
+			}
+			if !cmp.Equal(got, tt.want) {
+				diff := cmp.Diff(got, tt.want)
+				t.Errorf("Want - Got: %s", diff)
+			}
+		})
+	}
+}
+
+// fakeEncoder encodes every text into the same embedding.
+type fakeEncoder struct{}
+
+func (e fakeEncoder) Encode(ctx context.Context, text string) (gptbot.Embedding, error) {
+	return gptbot.Embedding{1, 0}, nil
+}
+
+func (e fakeEncoder) EncodeBatch(ctx context.Context, texts []string) ([]gptbot.Embedding, error) {
+	var embeddings []gptbot.Embedding
+	for range texts {
+		embeddings = append(embeddings, gptbot.Embedding{1, 0})
+	}
+	return embeddings, nil
+}
+
+// reverseReranker reverses the order of the similarities.
+type reverseReranker struct{}
+
+func (r reverseReranker) Rerank(ctx context.Context, question string, similarities []*gptbot.Similarity) ([]*gptbot.Similarity, error) {
+	reversed := make([]*gptbot.Similarity, len(similarities))
+	for i, s := range similarities {
+		reversed[len(similarities)-1-i] = s
+	}
+	return reversed, nil
+}
+
+// fakeEngine returns the given answers in order, one for each request.
+type fakeEngine struct {
+	answers  []string
+	requests []*gptbot.EngineRequest
+}
+
+func (e *fakeEngine) Infer(ctx context.Context, req *gptbot.EngineRequest) (*gptbot.EngineResponse, error) {
+	e.requests = append(e.requests, req)
+	if len(e.answers) == 0 {
+		return nil, fmt.Errorf("unexpected request")
+	}
+
+	answer := e.answers[0]
+	e.answers = e.answers[1:]
+	return &gptbot.EngineResponse{Text: answer}, nil
+}
+
+// InferStream sends the answer word by word.
+func (e *fakeEngine) InferStream(ctx context.Context, req *gptbot.EngineRequest, fn func(delta string) error) error {
+	resp, err := e.Infer(ctx, req)
+	if err != nil {
+		return err
+	}
+
+	for _, word := range strings.SplitAfter(resp.Text, " ") {
+		if err := fn(word); err != nil {
+			return err
+		}
+	}
+	return nil
+}
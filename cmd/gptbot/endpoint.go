@@ -29,9 +29,10 @@ func ValidateChatRequest(newSchema func(*ChatRequest) validating.Schema) httpopt
 }
 
 type ChatResponse struct {
-	Answer string        `json:"answer"`
-	Debug  *gptbot.Debug `json:"debug"`
-	Err    error         `json:"-"`
+	Answer  string           `json:"answer"`
+	Sources []*gptbot.Source `json:"sources"`
+	Debug   *gptbot.Debug    `json:"debug"`
+	Err     error            `json:"-"`
 }
 
 func (r *ChatResponse) Body() interface{} { return r }
@@ -43,7 +44,7 @@ func (r *ChatResponse) Failed() error { return r.Err }
 func MakeEndpointOfChat(s Service) endpoint.Endpoint {
 	return func(ctx context.Context, request interface{}) (interface{}, error) {
 		req := request.(*ChatRequest)
-		answer, debug, err := s.Chat(
+		answer, sources, debug, err := s.Chat(
 			ctx,
 			req.CorpusID,
 			req.Question,
@@ -51,9 +52,10 @@ func MakeEndpointOfChat(s Service) endpoint.Endpoint {
 			req.History,
 		)
 		return &ChatResponse{
-			Answer: answer,
-			Debug:  debug,
-			Err:    err,
+			Answer:  answer,
+			Sources: sources,
+			Debug:   debug,
+			Err:     err,
 		}, nil
 	}
 }
@@ -132,6 +134,8 @@ func MakeEndpointOfDebugSplitDocument(s Service) endpoint.Endpoint {
 
 type DeleteDocumentsRequest struct {
 	DocumentIds []string `json:"document_ids"`
+	CorpusID    string   `json:"corpus_id"`
+	All         bool     `json:"all"`
 }
 
 // ValidateDeleteDocumentsRequest creates a validator for DeleteDocumentsRequest.
@@ -158,6 +162,8 @@ func MakeEndpointOfDeleteDocuments(s Service) endpoint.Endpoint {
 		err := s.DeleteDocuments(
 			ctx,
 			req.DocumentIds,
+			req.CorpusID,
+			req.All,
 		)
 		return &DeleteDocumentsResponse{
 			Err: err,
@@ -165,6 +171,86 @@ func MakeEndpointOfDeleteDocuments(s Service) endpoint.Endpoint {
 	}
 }
 
+type GetDocumentRequest struct {
+	Id string `json:"-"`
+}
+
+// ValidateGetDocumentRequest creates a validator for GetDocumentRequest.
+func ValidateGetDocumentRequest(newSchema func(*GetDocumentRequest) validating.Schema) httpoption.Validator {
+	return httpoption.FuncValidator(func(value interface{}) error {
+		req := value.(*GetDocumentRequest)
+		return httpoption.Validate(newSchema(req))
+	})
+}
+
+type GetDocumentResponse struct {
+	Chunks []*gptbot.Chunk `json:"chunks"`
+	Err    error           `json:"-"`
+}
+
+func (r *GetDocumentResponse) Body() interface{} { return r }
+
+// Failed implements endpoint.Failer.
+func (r *GetDocumentResponse) Failed() error { return r.Err }
+
+// MakeEndpointOfGetDocument creates the endpoint for s.GetDocument.
+func MakeEndpointOfGetDocument(s Service) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (interface{}, error) {
+		req := request.(*GetDocumentRequest)
+		chunks, err := s.GetDocument(
+			ctx,
+			req.Id,
+		)
+		return &GetDocumentResponse{
+			Chunks: chunks,
+			Err:    err,
+		}, nil
+	}
+}
+
+type ListDocumentsRequest struct {
+	CorpusID string `json:"-"`
+	Offset   int    `json:"-"`
+	Limit    int    `json:"-"`
+}
+
+// ValidateListDocumentsRequest creates a validator for ListDocumentsRequest.
+func ValidateListDocumentsRequest(newSchema func(*ListDocumentsRequest) validating.Schema) httpoption.Validator {
+	return httpoption.FuncValidator(func(value interface{}) error {
+		req := value.(*ListDocumentsRequest)
+		return httpoption.Validate(newSchema(req))
+	})
+}
+
+type ListDocumentsResponse struct {
+	Documents []*gptbot.DocumentInfo `json:"documents"`
+	Total     int                    `json:"total"`
+	Err       error                  `json:"-"`
+}
+
+func (r *ListDocumentsResponse) Body() interface{} { return r }
+
+// Failed implements endpoint.Failer.
+func (r *ListDocumentsResponse) Failed() error { return r.Err }
+
+// MakeEndpointOfListDocuments creates the endpoint for s.ListDocuments.
+func MakeEndpointOfListDocuments(s Service) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (interface{}, error) {
+		req := request.(*ListDocumentsRequest)
+		documents, total, err := s.ListDocuments(
+			ctx,
+			req.CorpusID,
+			req.Offset,
+			req.Limit,
+		)
+		return &ListDocumentsResponse{
+			Documents: documents,
+			Total:     total,
+			Err:       err,
+		}, nil
+	}
+}
+
 type UploadFileRequest struct {
 	CorpusID string              `json:"corpus_id"`
 	File     *httpcodec.FormFile `json:"file"`
@@ -38,7 +38,7 @@ def upload(file):
 
 
 def clear():
-    resp = requests.post(URL+'/delete', json=dict(document_ids=[]))
+    resp = requests.post(URL+'/delete', json=dict(corpus_id=CORPUS_ID, all=not CORPUS_ID))
     handle_error(resp)
     return 'Cleared successfully!'
 
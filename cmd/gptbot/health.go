@@ -0,0 +1,29 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"time"
+)
+
+// Pinger is a vector store, which can check whether its server is healthy.
+type Pinger interface {
+	Ping(ctx context.Context) error
+}
+
+// NewReadinessHandler returns a handler for readiness probes, which responds
+// 503 if the vector store is not ready to serve requests.
+func NewReadinessHandler(store Store) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// Vector stores without servers (e.g. the local one) are always ready.
+		if p, ok := store.(Pinger); ok {
+			ctx, cancel := context.WithTimeout(r.Context(), 3*time.Second)
+			defer cancel()
+			if err := p.Ping(ctx); err != nil {
+				http.Error(w, err.Error(), http.StatusServiceUnavailable)
+				return
+			}
+		}
+		_, _ = w.Write([]byte("ok\n"))
+	})
+}
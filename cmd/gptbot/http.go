@@ -80,6 +80,34 @@ func NewHTTPRouter(svc Service, codecs httpcodec.Codecs, opts ...httpoption.Opti
 		),
 	)
 
+	codec = codecs.EncodeDecoder("GetDocument")
+	validator = options.RequestValidator("GetDocument")
+	r.Method(
+		"GET", "/documents/{id}",
+		kithttp.NewServer(
+			MakeEndpointOfGetDocument(svc),
+			decodeGetDocumentRequest(codec, validator),
+			httpcodec.MakeResponseEncoder(codec, 200),
+			append(kitOptions,
+				kithttp.ServerErrorEncoder(httpcodec.MakeErrorEncoder(codec)),
+			)...,
+		),
+	)
+
+	codec = codecs.EncodeDecoder("ListDocuments")
+	validator = options.RequestValidator("ListDocuments")
+	r.Method(
+		"GET", "/documents",
+		kithttp.NewServer(
+			MakeEndpointOfListDocuments(svc),
+			decodeListDocumentsRequest(codec, validator),
+			httpcodec.MakeResponseEncoder(codec, 200),
+			append(kitOptions,
+				kithttp.ServerErrorEncoder(httpcodec.MakeErrorEncoder(codec)),
+			)...,
+		),
+	)
+
 	codec = codecs.EncodeDecoder("UploadFile")
 	validator = options.RequestValidator("UploadFile")
 	r.Method(
@@ -161,6 +189,56 @@ func decodeDeleteDocumentsRequest(codec httpcodec.Codec, validator httpoption.Va
 	}
 }
 
+func decodeGetDocumentRequest(codec httpcodec.Codec, validator httpoption.Validator) kithttp.DecodeRequestFunc {
+	return func(_ context.Context, r *http.Request) (interface{}, error) {
+		var _req GetDocumentRequest
+
+		id := []string{chi.URLParam(r, "id")}
+		if err := codec.DecodeRequestParam("id", id, &_req.Id); err != nil {
+			return nil, err
+		}
+
+		if err := validator.Validate(&_req); err != nil {
+			return nil, err
+		}
+
+		return &_req, nil
+	}
+}
+
+func decodeListDocumentsRequest(codec httpcodec.Codec, validator httpoption.Validator) kithttp.DecodeRequestFunc {
+	return func(_ context.Context, r *http.Request) (interface{}, error) {
+		var _req ListDocumentsRequest
+
+		corpusID := r.URL.Query()["corpus_id"]
+		if len(corpusID) > 0 {
+			if err := codec.DecodeRequestParam("corpusID", corpusID, &_req.CorpusID); err != nil {
+				return nil, err
+			}
+		}
+
+		offset := r.URL.Query()["offset"]
+		if len(offset) > 0 {
+			if err := codec.DecodeRequestParam("offset", offset, &_req.Offset); err != nil {
+				return nil, err
+			}
+		}
+
+		limit := r.URL.Query()["limit"]
+		if len(limit) > 0 {
+			if err := codec.DecodeRequestParam("limit", limit, &_req.Limit); err != nil {
+				return nil, err
+			}
+		}
+
+		if err := validator.Validate(&_req); err != nil {
+			return nil, err
+		}
+
+		return &_req, nil
+	}
+}
+
 func decodeUploadFileRequest(codec httpcodec.Codec, validator httpoption.Validator) kithttp.DecodeRequestFunc {
 	return func(_ context.Context, r *http.Request) (interface{}, error) {
 		var _req UploadFileRequest
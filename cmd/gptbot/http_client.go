@@ -5,6 +5,7 @@ package main
 
 import (
 	"context"
+	"fmt"
 	"net/http"
 	"net/url"
 	"strings"
@@ -35,7 +36,7 @@ func NewHTTPClient(codecs httpcodec.Codecs, httpClient *http.Client, baseURL str
 	}, nil
 }
 
-func (c *HTTPClient) Chat(ctx context.Context, corpusID string, question string, inDebug bool, history []*gptbot.Turn) (answer string, debug *gptbot.Debug, err error) {
+func (c *HTTPClient) Chat(ctx context.Context, corpusID string, question string, inDebug bool, history []*gptbot.Turn) (answer string, sources []*gptbot.Source, debug *gptbot.Debug, err error) {
 	codec := c.codecs.EncodeDecoder("Chat")
 
 	path := "/chat"
@@ -58,12 +59,12 @@ func (c *HTTPClient) Chat(ctx context.Context, corpusID string, question string,
 	}
 	reqBodyReader, headers, err := codec.EncodeRequestBody(&reqBody)
 	if err != nil {
-		return "", nil, err
+		return "", nil, nil, err
 	}
 
 	_req, err := http.NewRequestWithContext(ctx, "POST", u.String(), reqBodyReader)
 	if err != nil {
-		return "", nil, err
+		return "", nil, nil, err
 	}
 
 	for k, v := range headers {
@@ -72,7 +73,7 @@ func (c *HTTPClient) Chat(ctx context.Context, corpusID string, question string,
 
 	_resp, err := c.httpClient.Do(_req)
 	if err != nil {
-		return "", nil, err
+		return "", nil, nil, err
 	}
 	defer _resp.Body.Close()
 
@@ -82,15 +83,15 @@ func (c *HTTPClient) Chat(ctx context.Context, corpusID string, question string,
 		if err == nil {
 			err = respErr
 		}
-		return "", nil, err
+		return "", nil, nil, err
 	}
 
 	respBody := &ChatResponse{}
 	err = codec.DecodeSuccessResponse(_resp.Body, respBody.Body())
 	if err != nil {
-		return "", nil, err
+		return "", nil, nil, err
 	}
-	return respBody.Answer, respBody.Debug, nil
+	return respBody.Answer, respBody.Sources, respBody.Debug, nil
 }
 
 func (c *HTTPClient) CreateDocuments(ctx context.Context, documents []*gptbot.Document) (err error) {
@@ -192,7 +193,7 @@ func (c *HTTPClient) DebugSplitDocument(ctx context.Context, doc *gptbot.Documen
 	return respBody.Texts, nil
 }
 
-func (c *HTTPClient) DeleteDocuments(ctx context.Context, documentIds []string) (err error) {
+func (c *HTTPClient) DeleteDocuments(ctx context.Context, documentIds []string, corpusID string, all bool) (err error) {
 	codec := c.codecs.EncodeDecoder("DeleteDocuments")
 
 	path := "/delete"
@@ -204,8 +205,12 @@ func (c *HTTPClient) DeleteDocuments(ctx context.Context, documentIds []string)
 
 	reqBody := struct {
 		DocumentIds []string `json:"document_ids"`
+		CorpusID    string   `json:"corpus_id"`
+		All         bool     `json:"all"`
 	}{
 		DocumentIds: documentIds,
+		CorpusID:    corpusID,
+		All:         all,
 	}
 	reqBodyReader, headers, err := codec.EncodeRequestBody(&reqBody)
 	if err != nil {
@@ -239,6 +244,96 @@ func (c *HTTPClient) DeleteDocuments(ctx context.Context, documentIds []string)
 	return nil
 }
 
+func (c *HTTPClient) GetDocument(ctx context.Context, id string) (chunks []*gptbot.Chunk, err error) {
+	codec := c.codecs.EncodeDecoder("GetDocument")
+
+	path := fmt.Sprintf("/documents/%s",
+		codec.EncodeRequestParam("id", id)[0],
+	)
+	u := &url.URL{
+		Scheme: c.scheme,
+		Host:   c.host,
+		Path:   c.pathPrefix + path,
+	}
+
+	_req, err := http.NewRequestWithContext(ctx, "GET", u.String(), nil)
+	if err != nil {
+		return nil, err
+	}
+
+	_resp, err := c.httpClient.Do(_req)
+	if err != nil {
+		return nil, err
+	}
+	defer _resp.Body.Close()
+
+	if _resp.StatusCode < http.StatusOK || _resp.StatusCode > http.StatusNoContent {
+		var respErr error
+		err := codec.DecodeFailureResponse(_resp.Body, &respErr)
+		if err == nil {
+			err = respErr
+		}
+		return nil, err
+	}
+
+	respBody := &GetDocumentResponse{}
+	err = codec.DecodeSuccessResponse(_resp.Body, respBody.Body())
+	if err != nil {
+		return nil, err
+	}
+	return respBody.Chunks, nil
+}
+
+func (c *HTTPClient) ListDocuments(ctx context.Context, corpusID string, offset int, limit int) (documents []*gptbot.DocumentInfo, total int, err error) {
+	codec := c.codecs.EncodeDecoder("ListDocuments")
+
+	path := "/documents"
+	u := &url.URL{
+		Scheme: c.scheme,
+		Host:   c.host,
+		Path:   c.pathPrefix + path,
+	}
+
+	q := u.Query()
+	for _, v := range codec.EncodeRequestParam("corpusID", corpusID) {
+		q.Add("corpus_id", v)
+	}
+	for _, v := range codec.EncodeRequestParam("offset", offset) {
+		q.Add("offset", v)
+	}
+	for _, v := range codec.EncodeRequestParam("limit", limit) {
+		q.Add("limit", v)
+	}
+	u.RawQuery = q.Encode()
+
+	_req, err := http.NewRequestWithContext(ctx, "GET", u.String(), nil)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	_resp, err := c.httpClient.Do(_req)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer _resp.Body.Close()
+
+	if _resp.StatusCode < http.StatusOK || _resp.StatusCode > http.StatusNoContent {
+		var respErr error
+		err := codec.DecodeFailureResponse(_resp.Body, &respErr)
+		if err == nil {
+			err = respErr
+		}
+		return nil, 0, err
+	}
+
+	respBody := &ListDocumentsResponse{}
+	err = codec.DecodeSuccessResponse(_resp.Body, respBody.Body())
+	if err != nil {
+		return nil, 0, err
+	}
+	return respBody.Documents, respBody.Total, nil
+}
+
 func (c *HTTPClient) UploadFile(ctx context.Context, corpusID string, file *httpcodec.FormFile) (err error) {
 	codec := c.codecs.EncodeDecoder("UploadFile")
 
@@ -1,6 +1,7 @@
 package main
 
 import (
+	"crypto/tls"
 	"fmt"
 	"log"
 	"net/http"
@@ -9,19 +10,59 @@ import (
 	"syscall"
 
 	"github.com/RussellLuo/kun/pkg/httpcodec"
+	"github.com/RussellLuo/kun/pkg/httpoption"
 	"github.com/go-aie/gptbot"
 	"github.com/go-aie/gptbot/milvus"
 )
 
+// Store is the vector store used by GPTBot.
+type Store interface {
+	gptbot.Querier
+	gptbot.Updater
+	gptbot.Lister
+	gptbot.CorpusManager
+}
+
+// newStore creates a durable local vector store in the directory specified
+// by GPTBOT_DATA_DIR if set, which is suitable for small deployments.
+// Otherwise, it connects to the Milvus server specified by the MILVUS_*
+// environment variables.
+func newStore() (Store, func() error, error) {
+	if dir := os.Getenv("GPTBOT_DATA_DIR"); dir != "" {
+		store, err := gptbot.OpenLocalVectorStore(&gptbot.LocalVectorStoreConfig{
+			WAL: &gptbot.WALConfig{Dir: dir},
+		})
+		if err != nil {
+			return nil, nil, err
+		}
+		return store, store.Close, nil
+	}
+
+	cfg := &milvus.Config{
+		CollectionName: "gptbot",
+		Addr:           os.Getenv("MILVUS_ADDR"),
+		Username:       os.Getenv("MILVUS_USERNAME"),
+		Password:       os.Getenv("MILVUS_PASSWORD"),
+		APIKey:         os.Getenv("MILVUS_API_KEY"),
+	}
+	if os.Getenv("MILVUS_TLS") == "true" {
+		cfg.TLS = &tls.Config{}
+	}
+	store, err := milvus.NewMilvus(cfg)
+	if err != nil {
+		return nil, nil, err
+	}
+	return store, store.Close, nil
+}
+
 func main() {
 	apiKey := os.Getenv("OPENAI_API_KEY")
 	encoder := gptbot.NewOpenAIEncoder(apiKey, "")
-	store, err := milvus.NewMilvus(&milvus.Config{
-		CollectionName: "gptbot",
-	})
+	store, closeStore, err := newStore()
 	if err != nil {
 		log.Fatalf("err: %v", err)
 	}
+	defer closeStore()
 
 	feeder := gptbot.NewFeeder(&gptbot.FeederConfig{
 		Encoder: encoder,
@@ -42,7 +83,9 @@ func main() {
 	svc := NewGPTBot(feeder, store, bot)
 	r := NewHTTPRouter(svc, httpcodec.NewDefaultCodecs(nil,
 		httpcodec.Op("UploadFile", httpcodec.NewMultipartForm(0)),
-	))
+	), httpoption.RequestValidators(newRequestValidators()...))
+	r.Method("POST", "/chat/stream", NewChatStreamHandler(svc, ValidateChatRequest(chatRequestSchema)))
+	r.Method("GET", "/readyz", NewReadinessHandler(store))
 
 	errs := make(chan error, 2)
 	go func() {
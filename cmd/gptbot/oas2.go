@@ -33,8 +33,8 @@ produces:
 paths:
   /chat:
     post:
-      description: "Chat sends question to the bot for an answer. If inDebug (i.e. the debug mode)\nis enabled, non-nil debug (i.e. the debugging information) will be returned."
-      summary: "Chat sends question to the bot for an answer. If inDebug (i.e. the debug mode)\nis enabled, non-nil debug (i.e. the debugging information) will be returned."
+      description: "Chat sends question to the bot for an answer, along with the sources used to\ngenerate the answer. If inDebug (i.e. the debug mode) is enabled, non-nil debug\n(i.e. the debugging information) will be returned."
+      summary: "Chat sends question to the bot for an answer, along with the sources used to\ngenerate the answer. If inDebug (i.e. the debug mode) is enabled, non-nil debug\n(i.e. the debugging information) will be returned."
       operationId: "Chat"
       parameters:
         - name: body
@@ -66,8 +66,8 @@ paths:
       %s
   /delete:
     post:
-      description: "DeleteDocuments deletes the specified documents from the vector store."
-      summary: "DeleteDocuments deletes the specified documents from the vector store."
+      description: "DeleteDocuments deletes the specified documents, or all the documents of the\nspecified corpus, from the vector store. To delete all the documents, all must\nbe true instead."
+      summary: "DeleteDocuments deletes the specified documents, or all the documents of the\nspecified corpus, from the vector store. To delete all the documents, all must\nbe true instead."
       operationId: "DeleteDocuments"
       parameters:
         - name: body
@@ -75,6 +75,40 @@ paths:
           schema:
             $ref: "#/definitions/DeleteDocumentsRequestBody"
       %s
+  /documents/{id}:
+    get:
+      description: "GetDocument returns the chunks (without embeddings) of the specified document."
+      summary: "GetDocument returns the chunks (without embeddings) of the specified document."
+      operationId: "GetDocument"
+      parameters:
+        - name: id
+          in: path
+          required: true
+          type: string
+          description: ""
+      %s
+  /documents:
+    get:
+      description: "ListDocuments lists the documents in the vector store, which are restricted to\nthe given corpus if corpusID is not empty. At most limit documents (or all if\nlimit is 0) are returned after skipping the first offset ones, along with the\ntotal number of the documents."
+      summary: "ListDocuments lists the documents in the vector store, which are restricted to\nthe given corpus if corpusID is not empty. At most limit documents (or all if\nlimit is 0) are returned after skipping the first offset ones, along with the\ntotal number of the documents."
+      operationId: "ListDocuments"
+      parameters:
+        - name: corpus_id
+          in: query
+          required: false
+          type: string
+          description: ""
+        - name: offset
+          in: query
+          required: false
+          type: integer
+          description: ""
+        - name: limit
+          in: query
+          required: false
+          type: integer
+          description: ""
+      %s
   /upload:
     post:
       description: "UploadFile uploads a file and then feeds the text into the vector store."
@@ -95,6 +129,8 @@ func getResponses(schema oas2.Schema) []oas2.OASResponses {
 		oas2.GetOASResponses(schema, "CreateDocuments", 200, &CreateDocumentsResponse{}),
 		oas2.GetOASResponses(schema, "DebugSplitDocument", 200, &DebugSplitDocumentResponse{}),
 		oas2.GetOASResponses(schema, "DeleteDocuments", 200, &DeleteDocumentsResponse{}),
+		oas2.GetOASResponses(schema, "GetDocument", 200, &GetDocumentResponse{}),
+		oas2.GetOASResponses(schema, "ListDocuments", 200, &ListDocumentsResponse{}),
 		oas2.GetOASResponses(schema, "UploadFile", 200, &UploadFileResponse{}),
 	}
 }
@@ -122,9 +158,15 @@ func getDefinitions(schema oas2.Schema) map[string]oas2.Definition {
 
 	oas2.AddDefinition(defs, "DeleteDocumentsRequestBody", reflect.ValueOf(&struct {
 		DocumentIds []string `json:"document_ids"`
+		CorpusID    string   `json:"corpus_id"`
+		All         bool     `json:"all"`
 	}{}))
 	oas2.AddResponseDefinitions(defs, schema, "DeleteDocuments", 200, (&DeleteDocumentsResponse{}).Body())
 
+	oas2.AddResponseDefinitions(defs, schema, "GetDocument", 200, (&GetDocumentResponse{}).Body())
+
+	oas2.AddResponseDefinitions(defs, schema, "ListDocuments", 200, (&ListDocumentsResponse{}).Body())
+
 	oas2.AddDefinition(defs, "UploadFileRequestBody", reflect.ValueOf(&struct {
 		CorpusID string              `json:"corpus_id"`
 		File     *httpcodec.FormFile `json:"file"`
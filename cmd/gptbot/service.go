@@ -2,11 +2,13 @@ package main
 
 import (
 	"context"
+	"errors"
 	"io"
 
 	"github.com/RussellLuo/kun/pkg/httpcodec"
+	"github.com/RussellLuo/kun/pkg/werror"
+	"github.com/RussellLuo/kun/pkg/werror/gcode"
 	"github.com/go-aie/gptbot"
-	"github.com/go-aie/gptbot/milvus"
 	"github.com/google/uuid"
 )
 
@@ -25,14 +27,29 @@ type Service interface {
 	//kun:op POST /upload
 	UploadFile(ctx context.Context, corpusID string, file *httpcodec.FormFile) (err error)
 
-	// DeleteDocuments deletes the specified documents from the vector store.
+	// DeleteDocuments deletes the specified documents, or all the documents of the
+	// specified corpus, from the vector store. To delete all the documents, all must
+	// be true instead.
 	//kun:op POST /delete
-	DeleteDocuments(ctx context.Context, documentIds []string) error
-
-	// Chat sends question to the bot for an answer. If inDebug (i.e. the debug mode)
-	// is enabled, non-nil debug (i.e. the debugging information) will be returned.
+	DeleteDocuments(ctx context.Context, documentIds []string, corpusID string, all bool) error
+
+	// ListDocuments lists the documents in the vector store, which are restricted to
+	// the given corpus if corpusID is not empty. At most limit documents (or all if
+	// limit is 0) are returned after skipping the first offset ones, along with the
+	// total number of the documents.
+	//kun:op GET /documents
+	//kun:body -
+	ListDocuments(ctx context.Context, corpusID string, offset, limit int) (documents []*gptbot.DocumentInfo, total int, err error)
+
+	// GetDocument returns the chunks (without embeddings) of the specified document.
+	//kun:op GET /documents/{id}
+	GetDocument(ctx context.Context, id string) (chunks []*gptbot.Chunk, err error)
+
+	// Chat sends question to the bot for an answer, along with the sources used to
+	// generate the answer. If inDebug (i.e. the debug mode) is enabled, non-nil debug
+	// (i.e. the debugging information) will be returned.
 	//kun:op POST /chat
-	Chat(ctx context.Context, corpusID, question string, inDebug bool, history []*gptbot.Turn) (answer string, debug *gptbot.Debug, err error)
+	Chat(ctx context.Context, corpusID, question string, inDebug bool, history []*gptbot.Turn) (answer string, sources []*gptbot.Source, debug *gptbot.Debug, err error)
 
 	// DebugSplitDocument splits a document into texts. It's mainly used for debugging purposes.
 	//kun:op POST /debug/split
@@ -41,11 +58,11 @@ type Service interface {
 
 type GPTBot struct {
 	feeder *gptbot.Feeder
-	store  *milvus.Milvus
+	store  Store
 	bot    *gptbot.Bot
 }
 
-func NewGPTBot(feeder *gptbot.Feeder, store *milvus.Milvus, bot *gptbot.Bot) *GPTBot {
+func NewGPTBot(feeder *gptbot.Feeder, store Store, bot *gptbot.Bot) *GPTBot {
 	return &GPTBot{
 		feeder: feeder,
 		store:  store,
@@ -74,12 +91,49 @@ func (b *GPTBot) UploadFile(ctx context.Context, corpusID string, file *httpcode
 	return b.feeder.Feed(ctx, doc)
 }
 
-func (b *GPTBot) DeleteDocuments(ctx context.Context, docIDs []string) error {
-	return b.store.Delete(ctx, docIDs...)
+func (b *GPTBot) DeleteDocuments(ctx context.Context, docIDs []string, corpusID string, all bool) error {
+	switch {
+	case len(docIDs) > 0 && corpusID == "" && !all:
+		return b.store.Delete(ctx, docIDs...)
+	case len(docIDs) == 0 && corpusID != "" && !all:
+		return b.store.DeleteCorpus(ctx, corpusID)
+	case len(docIDs) == 0 && corpusID == "" && all:
+		return b.store.Delete(ctx)
+	default:
+		return werror.Wrapf(gcode.ErrInvalidArgument, "exactly one of document_ids, corpus_id and all must be specified")
+	}
+}
+
+func (b *GPTBot) ListDocuments(ctx context.Context, corpusID string, offset, limit int) (documents []*gptbot.DocumentInfo, total int, err error) {
+	return b.store.ListDocuments(ctx, corpusID, offset, limit)
+}
+
+func (b *GPTBot) GetDocument(ctx context.Context, id string) (chunks []*gptbot.Chunk, err error) {
+	chunks, err = b.store.GetDocument(ctx, id)
+	if errors.Is(err, gptbot.ErrDocumentNotFound) {
+		return nil, werror.Wrap(gcode.ErrNotFound, err)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	// Omit the embeddings, which are too large to be useful.
+	for i, c := range chunks {
+		chunk := *c
+		chunk.Embedding = nil
+		chunks[i] = &chunk
+	}
+	return chunks, nil
+}
+
+func (b *GPTBot) Chat(ctx context.Context, corpusID, question string, inDebug bool, history []*gptbot.Turn) (answer string, sources []*gptbot.Source, debug *gptbot.Debug, err error) {
+	return b.bot.ChatWithSources(ctx, question, gptbot.ChatCorpusID(corpusID), gptbot.ChatDebug(inDebug), gptbot.ChatHistory(history...))
 }
 
-func (b *GPTBot) Chat(ctx context.Context, corpusID, question string, inDebug bool, history []*gptbot.Turn) (answer string, debug *gptbot.Debug, err error) {
-	return b.bot.Chat(ctx, question, gptbot.ChatCorpusID(corpusID), gptbot.ChatDebug(inDebug), gptbot.ChatHistory(history...))
+// ChatStream is like Chat, but streams the answer as it is generated.
+// It's served by the SSE endpoint (see NewChatStreamHandler) instead.
+func (b *GPTBot) ChatStream(ctx context.Context, corpusID, question string, inDebug bool, history []*gptbot.Turn) (*gptbot.ChatStream, error) {
+	return b.bot.ChatStream(ctx, question, gptbot.ChatCorpusID(corpusID), gptbot.ChatDebug(inDebug), gptbot.ChatHistory(history...))
 }
 
 func (b *GPTBot) DebugSplitDocument(ctx context.Context, doc *gptbot.Document) (texts []string, err error) {
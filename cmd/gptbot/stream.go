@@ -0,0 +1,86 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.com/RussellLuo/kun/pkg/httpoption"
+	"github.com/RussellLuo/kun/pkg/werror/gcode"
+	"github.com/go-aie/gptbot"
+)
+
+// ChatStreamEvent is the data of an event sent by the SSE chat endpoint.
+//
+// For each delta of the answer, an event "delta" with Text will be sent.
+// Once the answer is complete, an event "done" with Sources and Debug will be
+// sent. If any error occurs, an event "error" with Error will be sent.
+type ChatStreamEvent struct {
+	Text    string           `json:"text,omitempty"`
+	Sources []*gptbot.Source `json:"sources,omitempty"`
+	Debug   *gptbot.Debug    `json:"debug,omitempty"`
+	Error   string           `json:"error,omitempty"`
+}
+
+// NewChatStreamHandler creates a handler for the SSE chat endpoint, which
+// accepts the same request body as the endpoint of Chat, and validates it
+// by using validator.
+//
+// Since the request context will be canceled once the client disconnects,
+// the upstream LLM call will also be canceled accordingly.
+func NewChatStreamHandler(b *GPTBot, validator httpoption.Validator) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		flusher, ok := w.(http.Flusher)
+		if !ok {
+			http.Error(w, "streaming unsupported", http.StatusInternalServerError)
+			return
+		}
+
+		var req ChatRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if err := validator.Validate(&req); err != nil {
+			http.Error(w, err.Error(), gcode.HTTPStatusCode(err))
+			return
+		}
+
+		ctx := r.Context()
+		stream, err := b.ChatStream(ctx, req.CorpusID, req.Question, req.InDebug, req.History)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		defer stream.Close()
+
+		w.Header().Set("Content-Type", "text/event-stream")
+		w.Header().Set("Cache-Control", "no-cache")
+		w.Header().Set("Connection", "keep-alive")
+		w.WriteHeader(http.StatusOK)
+
+		send := func(event string, e *ChatStreamEvent) {
+			data, _ := json.Marshal(e)
+			_, _ = fmt.Fprintf(w, "event: %s\ndata: %s\n\n", event, data)
+			flusher.Flush()
+		}
+
+		for delta := range stream.Deltas() {
+			send("delta", &ChatStreamEvent{Text: delta})
+		}
+
+		if err := stream.Err(); err != nil {
+			if ctx.Err() != nil {
+				// The client has gone away.
+				return
+			}
+			send("error", &ChatStreamEvent{Error: err.Error()})
+			return
+		}
+
+		send("done", &ChatStreamEvent{
+			Sources: stream.Sources(),
+			Debug:   stream.Debug(),
+		})
+	})
+}
@@ -0,0 +1,214 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/RussellLuo/kun/pkg/httpcodec"
+	"github.com/RussellLuo/kun/pkg/httpoption"
+	"github.com/go-aie/gptbot"
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestNewChatStreamHandler(t *testing.T) {
+	tests := []struct {
+		name       string
+		engine     gptbot.Engine
+		body       string
+		wantStatus int
+		wantEvents []string
+	}{
+		{
+			name:       "streamed",
+			engine:     &fakeEngine{deltas: []string{"GPT-3 ", "was released in 2020."}},
+			body:       `{"question": "When was GPT-3 released?"}`,
+			wantStatus: http.StatusOK,
+			wantEvents: []string{
+				`delta {"text":"GPT-3 "}`,
+				`delta {"text":"was released in 2020."}`,
+				`done {"sources":[{"index":1,"chunk_id":"id_1","document_id":"doc_1","score":1}]}`,
+			},
+		},
+		{
+			name:       "failed",
+			engine:     &fakeEngine{err: fmt.Errorf("rate limited")},
+			body:       `{"question": "When was GPT-3 released?"}`,
+			wantStatus: http.StatusOK,
+			wantEvents: []string{
+				`error {"error":"rate limited"}`,
+			},
+		},
+		{
+			name:       "empty question",
+			engine:     &fakeEngine{},
+			body:       `{"question": ""}`,
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name:       "invalid corpus ID",
+			engine:     &fakeEngine{},
+			body:       `{"question": "When was GPT-3 released?", "corpus_id": "a\nb"}`,
+			wantStatus: http.StatusBadRequest,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := httptest.NewServer(NewChatStreamHandler(newTestGPTBot(t, tt.engine), ValidateChatRequest(chatRequestSchema)))
+			defer srv.Close()
+
+			resp, err := http.Post(srv.URL, "application/json", strings.NewReader(tt.body))
+			if err != nil {
+				t.Fatalf("err: %v\n", err)
+			}
+			defer resp.Body.Close()
+
+			if resp.StatusCode != tt.wantStatus {
+				t.Fatalf("Status: Got (%d) != Want (%d)", resp.StatusCode, tt.wantStatus)
+			}
+			if resp.StatusCode != http.StatusOK {
+				return
+			}
+
+			var got []string
+			scanner := bufio.NewScanner(resp.Body)
+			for {
+				event, data, ok := readEvent(scanner)
+				if !ok {
+					break
+				}
+				got = append(got, event+" "+data)
+			}
+			if !cmp.Equal(got, tt.wantEvents) {
+				diff := cmp.Diff(got, tt.wantEvents)
+				t.Errorf("Want - Got: %s", diff)
+			}
+		})
+	}
+}
+
+func TestNewChatStreamHandler_Disconnect(t *testing.T) {
+	engine := &fakeEngine{deltas: []string{"GPT-3 "}, block: true, canceled: make(chan struct{})}
+	srv := httptest.NewServer(NewChatStreamHandler(newTestGPTBot(t, engine), ValidateChatRequest(chatRequestSchema)))
+	defer srv.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	req, _ := http.NewRequestWithContext(ctx, http.MethodPost, srv.URL, strings.NewReader(`{"question": "When was GPT-3 released?"}`))
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("err: %v\n", err)
+	}
+	defer resp.Body.Close()
+
+	// Disconnect after receiving the first delta.
+	if event, _, ok := readEvent(bufio.NewScanner(resp.Body)); !ok || event != "delta" {
+		t.Fatalf("want a delta event, got %q", event)
+	}
+	cancel()
+
+	select {
+	case <-engine.canceled:
+	case <-time.After(5 * time.Second):
+		t.Fatalf("want the engine call to be canceled")
+	}
+}
+
+func TestNewHTTPRouter_ChatValidation(t *testing.T) {
+	r := NewHTTPRouter(newTestGPTBot(t, &fakeEngine{}), httpcodec.NewDefaultCodecs(nil),
+		httpoption.RequestValidators(newRequestValidators()...))
+
+	for _, body := range []string{
+		`{"question": ""}`,
+		`{"question": "When was GPT-3 released?", "corpus_id": "a\nb"}`,
+	} {
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/chat", strings.NewReader(body)))
+		if w.Code != http.StatusBadRequest {
+			t.Errorf("Status: Got (%d) != Want (%d) for %s", w.Code, http.StatusBadRequest, body)
+		}
+	}
+}
+
+// readEvent reads the next SSE event.
+func readEvent(scanner *bufio.Scanner) (event, data string, ok bool) {
+	for scanner.Scan() {
+		line := scanner.Text()
+		switch {
+		case strings.HasPrefix(line, "event: "):
+			event = strings.TrimPrefix(line, "event: ")
+		case strings.HasPrefix(line, "data: "):
+			data = strings.TrimPrefix(line, "data: ")
+		case line == "" && event != "":
+			return event, data, true
+		}
+	}
+	return "", "", false
+}
+
+func newTestGPTBot(t *testing.T, engine gptbot.Engine) *GPTBot {
+	store := gptbot.NewLocalVectorStore()
+	err := store.Insert(context.Background(), map[string][]*gptbot.Chunk{
+		"doc_1": {{ID: "id_1", Text: "GPT-3 was released in 2020.", DocumentID: "doc_1", Embedding: gptbot.Embedding{1, 0}}},
+	})
+	if err != nil {
+		t.Fatalf("err: %v\n", err)
+	}
+
+	bot := gptbot.NewBot(&gptbot.BotConfig{
+		Engine:  engine,
+		Encoder: fakeEncoder{},
+		Querier: store,
+	})
+	return NewGPTBot(nil, store, bot)
+}
+
+type fakeEncoder struct{}
+
+func (e fakeEncoder) Encode(ctx context.Context, text string) (gptbot.Embedding, error) {
+	return gptbot.Embedding{1, 0}, nil
+}
+
+func (e fakeEncoder) EncodeBatch(ctx context.Context, texts []string) ([]gptbot.Embedding, error) {
+	embeddings := make([]gptbot.Embedding, len(texts))
+	for i := range texts {
+		embeddings[i] = gptbot.Embedding{1, 0}
+	}
+	return embeddings, nil
+}
+
+// fakeEngine streams the given deltas, or fails with err. If block is true,
+// it blocks after the deltas until the call is canceled, which is then
+// signaled by closing canceled.
+type fakeEngine struct {
+	deltas   []string
+	err      error
+	block    bool
+	canceled chan struct{}
+}
+
+func (e *fakeEngine) Infer(ctx context.Context, req *gptbot.EngineRequest) (*gptbot.EngineResponse, error) {
+	return nil, fmt.Errorf("unexpected request")
+}
+
+func (e *fakeEngine) InferStream(ctx context.Context, req *gptbot.EngineRequest, fn func(delta string) error) error {
+	if e.err != nil {
+		return e.err
+	}
+	for _, delta := range e.deltas {
+		if err := fn(delta); err != nil {
+			return err
+		}
+	}
+	if e.block {
+		<-ctx.Done()
+		close(e.canceled)
+		return ctx.Err()
+	}
+	return nil
+}
@@ -0,0 +1,28 @@
+package main
+
+import (
+	"regexp"
+
+	"github.com/RussellLuo/kun/pkg/httpoption"
+	"github.com/RussellLuo/validating/v3"
+)
+
+// corpusIDRegexp matches the valid corpus IDs, which consist of at most 255
+// characters without control characters.
+var corpusIDRegexp = regexp.MustCompile(`^[^\p{Cc}]{1,255}$`)
+
+// chatRequestSchema is the validation schema of ChatRequest, which is shared
+// by the chat endpoint and the SSE chat endpoint.
+func chatRequestSchema(req *ChatRequest) validating.Schema {
+	return validating.Schema{
+		validating.F("question", req.Question):  validating.Nonzero[string]().Msg("empty question"),
+		validating.F("corpus_id", req.CorpusID): validating.ZeroOr[string](validating.Match(corpusIDRegexp).Msg("invalid corpus ID")),
+	}
+}
+
+// newRequestValidators returns the request validators of the endpoints.
+func newRequestValidators() []httpoption.NamedValidator {
+	return []httpoption.NamedValidator{
+		httpoption.Op("Chat", ValidateChatRequest(chatRequestSchema)),
+	}
+}
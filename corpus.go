@@ -0,0 +1,43 @@
+package gptbot
+
+import (
+	"context"
+	"errors"
+
+	"golang.org/x/exp/slices"
+)
+
+var (
+	ErrEmptyCorpusID = errors.New("empty corpus ID")
+)
+
+// CorpusManager is a vector store, which supports corpus-scoped operations.
+//
+// A document belongs to the corpus specified by its metadata, which is shared
+// by all its chunks.
+type CorpusManager interface {
+	// ListCorpora returns the IDs of all the (non-empty) corpora in order.
+	ListCorpora(ctx context.Context) ([]string, error)
+
+	// DeleteCorpus deletes all the documents belonging to the given corpus.
+	// Unlike Updater.Delete, it never deletes all the documents, and returns
+	// ErrEmptyCorpusID if corpusID is empty.
+	DeleteCorpus(ctx context.Context, corpusID string) error
+
+	// CorpusStats returns the statistics of the given corpus. The statistics
+	// are all zeros if the corpus does not exist.
+	CorpusStats(ctx context.Context, corpusID string) (*Stats, error)
+}
+
+// CorpusIDs returns the IDs of the (non-empty) corpora that the documents
+// belong to in order, which is a helper for implementing CorpusManager.
+func CorpusIDs(documents []*DocumentInfo) []string {
+	var corpusIDs []string
+	for _, doc := range documents {
+		if doc.CorpusID != "" {
+			corpusIDs = append(corpusIDs, doc.CorpusID)
+		}
+	}
+	slices.Sort(corpusIDs)
+	return slices.Compact(corpusIDs)
+}
@@ -0,0 +1,56 @@
+package gptbot_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/go-aie/gptbot"
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestLocalVectorStore_CorpusManager(t *testing.T) {
+	ctx := context.Background()
+	store := newPersistTestStore()
+	_ = store.Insert(ctx, map[string][]*gptbot.Chunk{
+		"doc_id_3": {{ID: "id_4", DocumentID: "doc_id_3", Metadata: gptbot.Metadata{CorpusID: "c2"}}},
+		"doc_id_4": {{ID: "id_5", DocumentID: "doc_id_4", Metadata: gptbot.Metadata{CorpusID: "c2"}}},
+	})
+
+	corpusIDs, err := store.ListCorpora(ctx)
+	if err != nil {
+		t.Fatalf("err: %v\n", err)
+	}
+	if want := []string{"c1", "c2"}; !cmp.Equal(corpusIDs, want) {
+		diff := cmp.Diff(corpusIDs, want)
+		t.Errorf("Want - Got: %s", diff)
+	}
+
+	stats, err := store.CorpusStats(ctx, "c2")
+	if err != nil {
+		t.Fatalf("err: %v\n", err)
+	}
+	if want := (&gptbot.Stats{DocumentNum: 2, ChunkNum: 2, CorpusNum: 1}); !cmp.Equal(stats, want) {
+		diff := cmp.Diff(stats, want)
+		t.Errorf("Want - Got: %s", diff)
+	}
+
+	if err := store.DeleteCorpus(ctx, ""); !errors.Is(err, gptbot.ErrEmptyCorpusID) {
+		t.Errorf("Got (%v) != Want (%v)", err, gptbot.ErrEmptyCorpusID)
+	}
+
+	// Deleting a non-existent corpus must not delete anything.
+	if err := store.DeleteCorpus(ctx, "c3"); err != nil {
+		t.Fatalf("err: %v\n", err)
+	}
+	if err := store.DeleteCorpus(ctx, "c2"); err != nil {
+		t.Fatalf("err: %v\n", err)
+	}
+
+	got := store.GetAllData(ctx)
+	want := newPersistTestStore().GetAllData(ctx)
+	if !cmp.Equal(got, want) {
+		diff := cmp.Diff(got, want)
+		t.Errorf("Want - Got: %s", diff)
+	}
+}
@@ -2,6 +2,8 @@ package gptbot
 
 import (
 	"context"
+	"fmt"
+	"strings"
 
 	"github.com/rakyll/openai-go"
 	"github.com/rakyll/openai-go/chat"
@@ -33,28 +35,47 @@ const (
 	TextBabbage001 ModelType = "text-babbage-001"
 )
 
+// ContextLength returns the maximum number of tokens, including both the prompt
+// and the completion, supported by the model. It returns 0 for unknown models.
+func (m ModelType) ContextLength() int {
+	switch m {
+	case GPT4, GPT40613, GPT40314:
+		return 8192
+	case GPT432K, GPT432K0613, GPT432K0314:
+		return 32768
+	case GPT3Dot5Turbo, GPT3Dot5Turbo0613:
+		return 4096
+	case GPT3Dot5Turbo16K, GPT3Dot5Turbo16K0613:
+		return 16384
+	case TextDavinci003, TextDavinci002:
+		return 4097
+	case TextAda001, TextCurie001, TextBabbage001:
+		return 2049
+	default:
+		return 0
+	}
+}
+
 // OpenAIChatEngine is an engine powered by OpenAI's Chat API /v1/chat/completions.
 //
 // See https://platform.openai.com/docs/models/model-endpoint-compatibility for
 // the supported models.
 type OpenAIChatEngine struct {
-	Client *chat.Client
+	Client          *chat.Client
+	StreamingClient *chat.StreamingClient
 }
 
 func NewOpenAIChatEngine(apiKey string, model ModelType) *OpenAIChatEngine {
+	s := openai.NewSession(apiKey)
 	return &OpenAIChatEngine{
-		Client: chat.NewClient(openai.NewSession(apiKey), string(model)),
+		Client:          chat.NewClient(s, string(model)),
+		StreamingClient: chat.NewStreamingClient(s, string(model)),
 	}
 }
 
 func (e *OpenAIChatEngine) Infer(ctx context.Context, req *EngineRequest) (*EngineResponse, error) {
 	resp, err := e.Client.CreateCompletion(ctx, &chat.CreateCompletionParams{
-		Messages: []*chat.Message{
-			{
-				Role:    req.Messages[0].Role,
-				Content: req.Messages[0].Content,
-			},
-		},
+		Messages:    chatMessages(req.Messages),
 		Temperature: req.Temperature,
 		MaxTokens:   req.MaxTokens,
 	})
@@ -67,23 +88,70 @@ func (e *OpenAIChatEngine) Infer(ctx context.Context, req *EngineRequest) (*Engi
 	}, nil
 }
 
+func (e *OpenAIChatEngine) InferStream(ctx context.Context, req *EngineRequest, fn func(delta string) error) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var fnErr error
+	err := e.StreamingClient.CreateCompletion(ctx, &chat.CreateCompletionParams{
+		Messages:    chatMessages(req.Messages),
+		Temperature: req.Temperature,
+		MaxTokens:   req.MaxTokens,
+	}, func(r *chat.CreateCompletionStreamingResponse) {
+		if fnErr != nil || len(r.Choices) == 0 {
+			return
+		}
+
+		// The response object is reused across chunks, so reset the delta
+		// to avoid receiving stale content in the subsequent chunks.
+		delta := r.Choices[0].Delta
+		r.Choices[0].Delta = nil
+
+		if delta == nil || delta.Content == "" {
+			return
+		}
+		if fnErr = fn(delta.Content); fnErr != nil {
+			cancel()
+		}
+	})
+
+	if fnErr != nil {
+		return fnErr
+	}
+	return err
+}
+
+func chatMessages(messages []*EngineMessage) []*chat.Message {
+	var msgs []*chat.Message
+	for _, m := range messages {
+		msgs = append(msgs, &chat.Message{
+			Role:    m.Role,
+			Content: m.Content,
+		})
+	}
+	return msgs
+}
+
 // OpenAICompletionEngine is an engine powered by OpenAI's Completion API /v1/completions.
 //
 // See https://platform.openai.com/docs/models/model-endpoint-compatibility for
 // the supported models.
 type OpenAICompletionEngine struct {
-	Client *completion.Client
+	Client          *completion.Client
+	StreamingClient *completion.StreamingClient
 }
 
 func NewOpenAICompletionEngine(apiKey string, model ModelType) *OpenAICompletionEngine {
+	s := openai.NewSession(apiKey)
 	return &OpenAICompletionEngine{
-		Client: completion.NewClient(openai.NewSession(apiKey), string(model)),
+		Client:          completion.NewClient(s, string(model)),
+		StreamingClient: completion.NewStreamingClient(s, string(model)),
 	}
 }
 
 func (e *OpenAICompletionEngine) Infer(ctx context.Context, req *EngineRequest) (*EngineResponse, error) {
 	resp, err := e.Client.Create(ctx, &completion.CreateParams{
-		Prompt:      []string{req.Messages[0].Content},
+		Prompt:      []string{completionPrompt(req.Messages)},
 		Temperature: req.Temperature,
 		MaxTokens:   req.MaxTokens,
 	})
@@ -95,3 +163,55 @@ func (e *OpenAICompletionEngine) Infer(ctx context.Context, req *EngineRequest)
 		Text: resp.Choices[0].Text,
 	}, nil
 }
+
+func (e *OpenAICompletionEngine) InferStream(ctx context.Context, req *EngineRequest, fn func(delta string) error) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var fnErr error
+	err := e.StreamingClient.Create(ctx, &completion.CreateParams{
+		Prompt:      []string{completionPrompt(req.Messages)},
+		Temperature: req.Temperature,
+		MaxTokens:   req.MaxTokens,
+	}, func(r *completion.CreateResponse) {
+		if fnErr != nil || len(r.Choices) == 0 {
+			return
+		}
+
+		// The response object is reused across chunks, so reset the text
+		// to avoid receiving stale content in the subsequent chunks.
+		text := r.Choices[0].Text
+		r.Choices[0].Text = ""
+
+		if text == "" {
+			return
+		}
+		if fnErr = fn(text); fnErr != nil {
+			cancel()
+		}
+	})
+
+	if fnErr != nil {
+		return fnErr
+	}
+	return err
+}
+
+// completionPrompt converts the messages into a single prompt, since the
+// Completion API does not support messages natively.
+func completionPrompt(messages []*EngineMessage) string {
+	if len(messages) == 1 {
+		return messages[0].Content
+	}
+
+	var b strings.Builder
+	for _, m := range messages {
+		role := m.Role
+		if role != "" {
+			role = strings.ToUpper(role[:1]) + role[1:]
+		}
+		fmt.Fprintf(&b, "%s: %s\n", role, m.Content)
+	}
+	b.WriteString("Assistant:")
+	return b.String()
+}
@@ -0,0 +1,120 @@
+package gptbot_test
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/go-aie/gptbot"
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestOpenAIChatEngine_Infer(t *testing.T) {
+	var got struct {
+		Messages []*gptbot.EngineMessage `json:"messages"`
+	}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err := json.NewDecoder(r.Body).Decode(&got); err != nil {
+			t.Errorf("err: %v\n", err)
+		}
+		fmt.Fprint(w, `{"choices":[{"message":{"role":"assistant","content":"In 2020."}}]}`)
+	}))
+	defer server.Close()
+
+	engine := gptbot.NewOpenAIChatEngine("", gptbot.GPT3Dot5Turbo)
+	engine.Client.CreateCompletionEndpoint = server.URL
+
+	messages := []*gptbot.EngineMessage{
+		{Role: "system", Content: "You are a helpful assistant."},
+		{Role: "user", Content: "What is GPT-3?"},
+		{Role: "assistant", Content: "GPT-3 is an AI model."},
+		{Role: "user", Content: "When was it released?"},
+	}
+	resp, err := engine.Infer(context.Background(), &gptbot.EngineRequest{
+		Messages: messages,
+	})
+	if err != nil {
+		t.Fatalf("err: %v\n", err)
+	}
+
+	if resp.Text != "In 2020." {
+		t.Errorf("unexpected answer: %s\n", resp.Text)
+	}
+	if !cmp.Equal(got.Messages, messages) {
+		diff := cmp.Diff(got.Messages, messages)
+		t.Errorf("Want - Got: %s", diff)
+	}
+}
+
+func TestOpenAIChatEngine_InferStream(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		chunks := []string{
+			`{"choices":[{"delta":{"role":"assistant"}}]}`,
+			`{"choices":[{"delta":{"content":"GPT-3 was "}}]}`,
+			`{"choices":[{"delta":{"content":"released in 2020."}}]}`,
+			`{"choices":[{"delta":{},"finish_reason":"stop"}]}`,
+		}
+		for _, c := range chunks {
+			fmt.Fprintf(w, "data: %s\n\n", c)
+		}
+		fmt.Fprint(w, "data: [DONE]\n\n")
+	}))
+	defer server.Close()
+
+	engine := gptbot.NewOpenAIChatEngine("", gptbot.GPT3Dot5Turbo)
+	engine.StreamingClient.CreateCompletionEndpoint = server.URL
+
+	var got []string
+	err := engine.InferStream(context.Background(), &gptbot.EngineRequest{
+		Messages: []*gptbot.EngineMessage{{Role: "user", Content: "When was GPT-3 released?"}},
+	}, func(delta string) error {
+		got = append(got, delta)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("err: %v\n", err)
+	}
+
+	want := []string{"GPT-3 was ", "released in 2020."}
+	if !cmp.Equal(got, want) {
+		diff := cmp.Diff(got, want)
+		t.Errorf("Want - Got: %s", diff)
+	}
+}
+
+func TestOpenAICompletionEngine_InferStream(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		chunks := []string{
+			`{"choices":[{"text":"GPT-3 was "}]}`,
+			`{"choices":[{"text":"released in 2020."}]}`,
+			`{"choices":[{"text":"","finish_reason":"stop"}]}`,
+		}
+		for _, c := range chunks {
+			fmt.Fprintf(w, "data: %s\n\n", c)
+		}
+		fmt.Fprint(w, "data: [DONE]\n\n")
+	}))
+	defer server.Close()
+
+	engine := gptbot.NewOpenAICompletionEngine("", gptbot.TextDavinci003)
+	engine.StreamingClient.CreateEndpoint = server.URL
+
+	var got strings.Builder
+	err := engine.InferStream(context.Background(), &gptbot.EngineRequest{
+		Messages: []*gptbot.EngineMessage{{Role: "user", Content: "When was GPT-3 released?"}},
+	}, func(delta string) error {
+		got.WriteString(delta)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("err: %v\n", err)
+	}
+
+	if want := "GPT-3 was released in 2020."; got.String() != want {
+		t.Errorf("got %q, want %q", got.String(), want)
+	}
+}
@@ -2,6 +2,10 @@ package gptbot
 
 type Metadata struct {
 	CorpusID string `json:"corpus_id,omitempty"`
+
+	// Attributes are arbitrary key-value pairs (e.g. product, version, date),
+	// which can be used to filter chunks at query time. See Filter.
+	Attributes map[string]any `json:"attributes,omitempty"`
 }
 
 type Document struct {
@@ -18,6 +22,13 @@ type Chunk struct {
 	DocumentID string    `json:"document_id,omitempty"`
 	Metadata   Metadata  `json:"metadata,omitempty"`
 	Embedding  Embedding `json:"embedding,omitempty"`
+
+	// Position is the 0-based position of the chunk in its document.
+	Position int `json:"position,omitempty"`
+
+	// CreatedAt is the time (in Unix seconds) when the chunk was stored,
+	// which is only set by the vector stores that support it (e.g. Milvus).
+	CreatedAt int64 `json:"created_at,omitempty"`
 }
 
 type Similarity struct {
@@ -0,0 +1,261 @@
+package gptbot
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+)
+
+// FilterOp is the operator of a filter.
+type FilterOp string
+
+const (
+	// Comparison operators.
+	OpEq FilterOp = "eq"
+	OpNe FilterOp = "ne"
+	OpGt FilterOp = "gt"
+	OpGe FilterOp = "ge"
+	OpLt FilterOp = "lt"
+	OpLe FilterOp = "le"
+	OpIn FilterOp = "in"
+
+	// Logical operators.
+	OpAnd FilterOp = "and"
+	OpOr  FilterOp = "or"
+	OpNot FilterOp = "not"
+)
+
+// Filter is a boolean expression over the metadata attributes of chunks
+// (see Metadata.Attributes). A nil filter matches all chunks.
+//
+// For example, the expression `product = "X" AND version >= 3` can be built as:
+//
+//	And(Eq("product", "X"), Ge("version", 3))
+//
+// Numbers are compared numerically, strings are compared lexicographically
+// (which also works for dates in RFC 3339 format), and booleans can only be
+// compared for equality. Comparisons between values of different types
+// (or against a missing attribute) always evaluate to false.
+type Filter struct {
+	Op FilterOp `json:"op"`
+
+	// Key and Value are the operands of a comparison operator.
+	// For OpIn, Value must be a list of values.
+	Key   string `json:"key,omitempty"`
+	Value any    `json:"value,omitempty"`
+
+	// Filters are the operands of a logical operator.
+	// For OpNot, there must be exactly one operand.
+	Filters []*Filter `json:"filters,omitempty"`
+}
+
+func Eq(key string, value any) *Filter { return &Filter{Op: OpEq, Key: key, Value: value} }
+func Ne(key string, value any) *Filter { return &Filter{Op: OpNe, Key: key, Value: value} }
+func Gt(key string, value any) *Filter { return &Filter{Op: OpGt, Key: key, Value: value} }
+func Ge(key string, value any) *Filter { return &Filter{Op: OpGe, Key: key, Value: value} }
+func Lt(key string, value any) *Filter { return &Filter{Op: OpLt, Key: key, Value: value} }
+func Le(key string, value any) *Filter { return &Filter{Op: OpLe, Key: key, Value: value} }
+
+func In(key string, values ...any) *Filter { return &Filter{Op: OpIn, Key: key, Value: values} }
+
+func And(filters ...*Filter) *Filter { return &Filter{Op: OpAnd, Filters: filters} }
+func Or(filters ...*Filter) *Filter  { return &Filter{Op: OpOr, Filters: filters} }
+func Not(filter *Filter) *Filter     { return &Filter{Op: OpNot, Filters: []*Filter{filter}} }
+
+// Validate checks whether the filter is well-formed.
+func (f *Filter) Validate() error {
+	if f == nil {
+		return nil
+	}
+
+	switch f.Op {
+	case OpEq, OpNe, OpGt, OpGe, OpLt, OpLe:
+		if f.Key == "" {
+			return fmt.Errorf("filter %q: empty key", f.Op)
+		}
+	case OpIn:
+		if f.Key == "" {
+			return fmt.Errorf("filter %q: empty key", f.Op)
+		}
+		if _, ok := FilterValues(f.Value); !ok {
+			return fmt.Errorf("filter %q: value is not a list", f.Op)
+		}
+	case OpAnd, OpOr, OpNot:
+		if f.Op == OpNot && len(f.Filters) != 1 {
+			return fmt.Errorf("filter %q: want 1 operand, got %d", f.Op, len(f.Filters))
+		}
+		for _, sub := range f.Filters {
+			if err := sub.Validate(); err != nil {
+				return err
+			}
+		}
+	default:
+		return fmt.Errorf("unknown filter operator %q", f.Op)
+	}
+
+	return nil
+}
+
+// Match reports whether the given attributes satisfy the filter.
+func (f *Filter) Match(attrs map[string]any) bool {
+	if f == nil {
+		return true
+	}
+
+	switch f.Op {
+	case OpAnd:
+		for _, sub := range f.Filters {
+			if !sub.Match(attrs) {
+				return false
+			}
+		}
+		return true
+	case OpOr:
+		for _, sub := range f.Filters {
+			if sub.Match(attrs) {
+				return true
+			}
+		}
+		return false
+	case OpNot:
+		return len(f.Filters) == 1 && !f.Filters[0].Match(attrs)
+	}
+
+	value, ok := attrs[f.Key]
+	if !ok {
+		return false
+	}
+
+	if f.Op == OpIn {
+		values, _ := FilterValues(f.Value)
+		for _, v := range values {
+			if c, ok := compare(value, v); ok && c == 0 {
+				return true
+			}
+		}
+		return false
+	}
+
+	c, ok := compare(value, f.Value)
+	if !ok {
+		// Booleans can still be compared for inequality.
+		return f.Op == OpNe && isComparable(value, f.Value)
+	}
+
+	switch f.Op {
+	case OpEq:
+		return c == 0
+	case OpNe:
+		return c != 0
+	case OpGt:
+		return c > 0
+	case OpGe:
+		return c >= 0
+	case OpLt:
+		return c < 0
+	case OpLe:
+		return c <= 0
+	default:
+		return false
+	}
+}
+
+// FilterValues converts the value of OpIn into a list of values.
+func FilterValues(value any) ([]any, bool) {
+	if values, ok := value.([]any); ok {
+		return values, true
+	}
+
+	v := reflect.ValueOf(value)
+	if v.Kind() != reflect.Slice && v.Kind() != reflect.Array {
+		return nil, false
+	}
+
+	values := make([]any, v.Len())
+	for i := range values {
+		values[i] = v.Index(i).Interface()
+	}
+	return values, true
+}
+
+// compare compares a and b, and reports whether they are ordered.
+// Booleans are only ordered if they are equal.
+func compare(a, b any) (int, bool) {
+	if x, ok := toFloat64(a); ok {
+		y, ok := toFloat64(b)
+		if !ok {
+			return 0, false
+		}
+		switch {
+		case x < y:
+			return -1, true
+		case x > y:
+			return 1, true
+		default:
+			return 0, true
+		}
+	}
+
+	if x, ok := a.(string); ok {
+		y, ok := b.(string)
+		if !ok {
+			return 0, false
+		}
+		switch {
+		case x < y:
+			return -1, true
+		case x > y:
+			return 1, true
+		default:
+			return 0, true
+		}
+	}
+
+	if x, ok := a.(bool); ok {
+		if y, ok := b.(bool); ok && x == y {
+			return 0, true
+		}
+	}
+
+	return 0, false
+}
+
+func isComparable(a, b any) bool {
+	_, ok1 := a.(bool)
+	_, ok2 := b.(bool)
+	return ok1 && ok2
+}
+
+func toFloat64(v any) (float64, bool) {
+	switch n := v.(type) {
+	case int:
+		return float64(n), true
+	case int8:
+		return float64(n), true
+	case int16:
+		return float64(n), true
+	case int32:
+		return float64(n), true
+	case int64:
+		return float64(n), true
+	case uint:
+		return float64(n), true
+	case uint8:
+		return float64(n), true
+	case uint16:
+		return float64(n), true
+	case uint32:
+		return float64(n), true
+	case uint64:
+		return float64(n), true
+	case float32:
+		return float64(n), true
+	case float64:
+		return n, true
+	case json.Number:
+		f, err := n.Float64()
+		return f, err == nil
+	default:
+		return 0, false
+	}
+}
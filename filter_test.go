@@ -0,0 +1,63 @@
+package gptbot_test
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/go-aie/gptbot"
+)
+
+func TestFilter_Match(t *testing.T) {
+	attrs := map[string]any{
+		"product":  "X",
+		"version":  3,
+		"date":     "2023-05-01",
+		"internal": false,
+	}
+
+	tests := []struct {
+		name   string
+		filter *gptbot.Filter
+		want   bool
+	}{
+		{name: "nil", filter: nil, want: true},
+		{name: "eq string", filter: gptbot.Eq("product", "X"), want: true},
+		{name: "eq number across types", filter: gptbot.Eq("version", 3.0), want: true},
+		{name: "ne", filter: gptbot.Ne("product", "X"), want: false},
+		{name: "gt", filter: gptbot.Gt("version", 2), want: true},
+		{name: "le", filter: gptbot.Le("version", 2), want: false},
+		{name: "date range", filter: gptbot.And(gptbot.Ge("date", "2023-01-01"), gptbot.Lt("date", "2024-01-01")), want: true},
+		{name: "eq bool", filter: gptbot.Eq("internal", false), want: true},
+		{name: "ne bool", filter: gptbot.Ne("internal", true), want: true},
+		{name: "gt bool", filter: gptbot.Gt("internal", false), want: false},
+		{name: "type mismatch", filter: gptbot.Eq("version", "3"), want: false},
+		{name: "missing key", filter: gptbot.Ne("color", "red"), want: false},
+		{name: "in", filter: gptbot.In("product", "Y", "X"), want: true},
+		{name: "not in", filter: gptbot.In("product", "Y", "Z"), want: false},
+		{name: "or", filter: gptbot.Or(gptbot.Eq("product", "Y"), gptbot.Eq("version", 3)), want: true},
+		{name: "not", filter: gptbot.Not(gptbot.Eq("product", "X")), want: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.filter.Match(attrs); got != tt.want {
+				t.Errorf("Got (%v) != Want (%v)", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFilter_JSON(t *testing.T) {
+	var filter gptbot.Filter
+	in := `{"op":"and","filters":[{"op":"eq","key":"product","value":"X"},{"op":"in","key":"version","value":[2,3]}]}`
+	if err := json.Unmarshal([]byte(in), &filter); err != nil {
+		t.Fatalf("err: %v\n", err)
+	}
+	if err := filter.Validate(); err != nil {
+		t.Fatalf("err: %v\n", err)
+	}
+
+	attrs := map[string]any{"product": "X", "version": 3}
+	if !filter.Match(attrs) {
+		t.Errorf("want %v to match %s", attrs, in)
+	}
+}
@@ -0,0 +1,403 @@
+package gptbot
+
+import (
+	"container/heap"
+	"math"
+	"math/rand"
+
+	"github.com/go-aie/xslices"
+	"golang.org/x/exp/slices"
+)
+
+type HNSWConfig struct {
+	// M is the maximum number of connections per node on each layer
+	// (except the bottom layer, which allows 2*M connections).
+	// Defaults to 16.
+	M int
+
+	// EfConstruction is the size of the dynamic candidate list when
+	// building the index. Higher values give better recall at the cost
+	// of slower insertion.
+	// Defaults to 200.
+	EfConstruction int
+
+	// EfSearch is the size of the dynamic candidate list when searching.
+	// Higher values give better recall at the cost of slower queries.
+	// Note that the topK of a query is used instead if it's larger.
+	// Defaults to 64.
+	EfSearch int
+
+	// Seed is the seed for generating random node levels.
+	// Defaults to 1, which makes the index deterministic.
+	Seed int64
+}
+
+func (cfg *HNSWConfig) init() {
+	if cfg.M == 0 {
+		cfg.M = 16
+	}
+	if cfg.EfConstruction == 0 {
+		cfg.EfConstruction = 200
+	}
+	if cfg.EfSearch == 0 {
+		cfg.EfSearch = 64
+	}
+	if cfg.Seed == 0 {
+		cfg.Seed = 1
+	}
+}
+
+type hnswNode struct {
+	chunk *Chunk
+	// neighbors[l] are the neighbors of the node on layer l.
+	neighbors [][]*hnswNode
+	// inbound[l] are the nodes having the node as a neighbor on layer l.
+	// Since the connections are directed, they are kept to find the nodes
+	// to repair on deletion without scanning the whole graph.
+	inbound []map[*hnswNode]bool
+}
+
+func newHNSWNode(chunk *Chunk, level int) *hnswNode {
+	n := &hnswNode{
+		chunk:     chunk,
+		neighbors: make([][]*hnswNode, level+1),
+		inbound:   make([]map[*hnswNode]bool, level+1),
+	}
+	for l := range n.inbound {
+		n.inbound[l] = make(map[*hnswNode]bool)
+	}
+	return n
+}
+
+func (n *hnswNode) level() int {
+	return len(n.neighbors) - 1
+}
+
+// setNeighbors replaces the neighbors of the node on layer l, and keeps the
+// inbound connections of both the old and the new neighbors up to date.
+func (n *hnswNode) setNeighbors(l int, neighbors []*hnswNode) {
+	for _, nb := range n.neighbors[l] {
+		delete(nb.inbound[l], n)
+	}
+	n.neighbors[l] = neighbors
+	for _, nb := range neighbors {
+		nb.inbound[l][n] = true
+	}
+}
+
+// hnswIndex is an in-memory Hierarchical Navigable Small World graph for
+// approximate nearest neighbor search.
+//
+// See https://arxiv.org/abs/1603.09320.
+type hnswIndex struct {
+	cfg    *HNSWConfig
+	metric Metric
+	rand   *rand.Rand
+	nodes  map[*Chunk]*hnswNode
+	entry  *hnswNode
+
+	// levelMult is the normalization factor for level generation.
+	levelMult float64
+}
+
+func newHNSWIndex(cfg *HNSWConfig, metric Metric) *hnswIndex {
+	cfg.init()
+	return &hnswIndex{
+		cfg:       cfg,
+		metric:    metric,
+		rand:      rand.New(rand.NewSource(cfg.Seed)),
+		nodes:     make(map[*Chunk]*hnswNode),
+		levelMult: 1 / math.Log(float64(cfg.M)),
+	}
+}
+
+func (idx *hnswIndex) Len() int {
+	return len(idx.nodes)
+}
+
+func (idx *hnswIndex) maxConns(level int) int {
+	if level == 0 {
+		return 2 * idx.cfg.M
+	}
+	return idx.cfg.M
+}
+
+func (idx *hnswIndex) randomLevel() int {
+	return int(math.Floor(-math.Log(1-idx.rand.Float64()) * idx.levelMult))
+}
+
+// Insert adds the chunks to the index. Chunks without embeddings are skipped.
+func (idx *hnswIndex) Insert(chunks ...*Chunk) {
+	for _, chunk := range chunks {
+		if _, ok := idx.nodes[chunk]; ok || len(chunk.Embedding) == 0 {
+			continue
+		}
+		idx.insert(chunk)
+	}
+}
+
+func (idx *hnswIndex) insert(chunk *Chunk) {
+	level := idx.randomLevel()
+	node := newHNSWNode(chunk, level)
+	idx.nodes[chunk] = node
+
+	if idx.entry == nil {
+		idx.entry = node
+		return
+	}
+
+	q := chunk.Embedding
+	eps := []hnswCandidate{{node: idx.entry, score: idx.score(q, idx.entry)}}
+	for l := idx.entry.level(); l > level; l-- {
+		eps = idx.searchLayer(q, eps, 1, l)
+	}
+
+	for l := xslices.Min(level, idx.entry.level()); l >= 0; l-- {
+		eps = idx.searchLayer(q, eps, idx.cfg.EfConstruction, l)
+		node.setNeighbors(l, idx.selectNeighbors(eps, idx.cfg.M))
+
+		for _, n := range node.neighbors[l] {
+			neighbors := append(n.neighbors[l], node)
+			if len(neighbors) > idx.maxConns(l) {
+				neighbors = idx.selectNeighbors(idx.candidatesOf(n.chunk.Embedding, neighbors), idx.maxConns(l))
+			}
+			n.setNeighbors(l, neighbors)
+		}
+	}
+
+	if level > idx.entry.level() {
+		idx.entry = node
+	}
+}
+
+// Delete removes the chunks from the index, and then repairs the connections
+// of the nodes that were connected to the removed ones, which are found by
+// the inbound connections of the removed nodes.
+func (idx *hnswIndex) Delete(chunks ...*Chunk) {
+	removed := make(map[*hnswNode]bool)
+	for _, chunk := range chunks {
+		if node, ok := idx.nodes[chunk]; ok {
+			removed[node] = true
+			delete(idx.nodes, chunk)
+		}
+	}
+	if len(removed) == 0 {
+		return
+	}
+
+	// affected[l] are the remaining nodes pointing to the removed ones on layer l.
+	var affected []map[*hnswNode]bool
+	for r := range removed {
+		for l, inbound := range r.inbound {
+			if l == len(affected) {
+				affected = append(affected, make(map[*hnswNode]bool))
+			}
+			for n := range inbound {
+				if !removed[n] {
+					affected[l][n] = true
+				}
+			}
+		}
+		// The removed node no longer points to its neighbors.
+		for l, neighbors := range r.neighbors {
+			for _, nb := range neighbors {
+				delete(nb.inbound[l], r)
+			}
+		}
+	}
+
+	for l, nodes := range affected {
+		for n := range nodes {
+			var kept, orphans []*hnswNode
+			for _, nb := range n.neighbors[l] {
+				if removed[nb] {
+					orphans = append(orphans, nb)
+				} else {
+					kept = append(kept, nb)
+				}
+			}
+
+			// Reconnect the node to the neighbors of the removed nodes.
+			seen := make(map[*hnswNode]bool)
+			for _, nb := range kept {
+				seen[nb] = true
+			}
+			for _, o := range orphans {
+				for _, nb := range o.neighbors[l] {
+					if nb != n && !removed[nb] && !seen[nb] {
+						seen[nb] = true
+						kept = append(kept, nb)
+					}
+				}
+			}
+			n.setNeighbors(l, idx.selectNeighbors(idx.candidatesOf(n.chunk.Embedding, kept), idx.maxConns(l)))
+		}
+	}
+
+	if removed[idx.entry] {
+		idx.entry = nil
+		for _, n := range idx.nodes {
+			if idx.entry == nil || n.level() > idx.entry.level() {
+				idx.entry = n
+			}
+		}
+	}
+}
+
+// Search returns the approximate k nearest chunks of the given embedding,
+// sorted by score in descending order.
+func (idx *hnswIndex) Search(q Embedding, k int) []*Similarity {
+	if idx.entry == nil || k <= 0 {
+		return nil
+	}
+
+	eps := []hnswCandidate{{node: idx.entry, score: idx.score(q, idx.entry)}}
+	for l := idx.entry.level(); l > 0; l-- {
+		eps = idx.searchLayer(q, eps, 1, l)
+	}
+	eps = idx.searchLayer(q, eps, xslices.Max(idx.cfg.EfSearch, k), 0)
+
+	if len(eps) > k {
+		eps = eps[:k]
+	}
+	similarities := make([]*Similarity, len(eps))
+	for i, c := range eps {
+		similarities[i] = &Similarity{
+			Chunk: c.node.chunk,
+			Score: c.score,
+		}
+	}
+	return similarities
+}
+
+// searchLayer finds the ef nearest nodes of q on the given layer, starting
+// from the entry points. The result is sorted by score in descending order.
+func (idx *hnswIndex) searchLayer(q Embedding, eps []hnswCandidate, ef, level int) []hnswCandidate {
+	visited := make(map[*hnswNode]bool, ef*idx.maxConns(level))
+	candidates := &hnswHeap{max: true}
+	results := &hnswHeap{}
+
+	for _, ep := range eps {
+		visited[ep.node] = true
+		heap.Push(candidates, ep)
+		heap.Push(results, ep)
+		if results.Len() > ef {
+			heap.Pop(results)
+		}
+	}
+
+	for candidates.Len() > 0 {
+		c := heap.Pop(candidates).(hnswCandidate)
+		if results.Len() >= ef && c.score < results.items[0].score {
+			// All the remaining candidates are worse than the results.
+			break
+		}
+
+		for _, nb := range c.node.neighbors[level] {
+			if visited[nb] {
+				continue
+			}
+			visited[nb] = true
+
+			s := idx.score(q, nb)
+			if results.Len() < ef || s > results.items[0].score {
+				heap.Push(candidates, hnswCandidate{node: nb, score: s})
+				heap.Push(results, hnswCandidate{node: nb, score: s})
+				if results.Len() > ef {
+					heap.Pop(results)
+				}
+			}
+		}
+	}
+
+	sorted := results.items
+	slices.SortFunc(sorted, func(a, b hnswCandidate) bool {
+		return a.score > b.score
+	})
+	return sorted
+}
+
+// selectNeighbors selects at most m neighbors from the candidates (of some
+// node), which are sorted by score in descending order, by using the heuristic that prefers
+// diverse directions. The discarded candidates are used to fill the
+// remaining slots, if any.
+func (idx *hnswIndex) selectNeighbors(candidates []hnswCandidate, m int) []*hnswNode {
+	var selected, discarded []*hnswNode
+	for _, c := range candidates {
+		if len(selected) >= m {
+			break
+		}
+
+		good := true
+		for _, s := range selected {
+			// The candidate is closer to a selected neighbor than to the node.
+			if idx.score(c.node.chunk.Embedding, s) > c.score {
+				good = false
+				break
+			}
+		}
+		if good {
+			selected = append(selected, c.node)
+		} else {
+			discarded = append(discarded, c.node)
+		}
+	}
+
+	for _, n := range discarded {
+		if len(selected) >= m {
+			break
+		}
+		selected = append(selected, n)
+	}
+	return selected
+}
+
+// candidatesOf scores the nodes against q and sorts them by score in
+// descending order.
+func (idx *hnswIndex) candidatesOf(q Embedding, nodes []*hnswNode) []hnswCandidate {
+	candidates := make([]hnswCandidate, len(nodes))
+	for i, n := range nodes {
+		candidates[i] = hnswCandidate{node: n, score: idx.score(q, n)}
+	}
+	slices.SortFunc(candidates, func(a, b hnswCandidate) bool {
+		return a.score > b.score
+	})
+	return candidates
+}
+
+func (idx *hnswIndex) score(q Embedding, n *hnswNode) float64 {
+	return idx.metric.Score(q, n.chunk.Embedding)
+}
+
+type hnswCandidate struct {
+	node  *hnswNode
+	score float64
+}
+
+// hnswHeap is a heap of candidates, which is a min-heap by default,
+// or a max-heap if max is true.
+type hnswHeap struct {
+	items []hnswCandidate
+	max   bool
+}
+
+func (h *hnswHeap) Len() int { return len(h.items) }
+
+func (h *hnswHeap) Less(i, j int) bool {
+	if h.max {
+		return h.items[i].score > h.items[j].score
+	}
+	return h.items[i].score < h.items[j].score
+}
+
+func (h *hnswHeap) Swap(i, j int) { h.items[i], h.items[j] = h.items[j], h.items[i] }
+
+func (h *hnswHeap) Push(x any) { h.items = append(h.items, x.(hnswCandidate)) }
+
+func (h *hnswHeap) Pop() any {
+	old := h.items
+	n := len(old)
+	x := old[n-1]
+	h.items = old[:n-1]
+	return x
+}
@@ -0,0 +1,204 @@
+package gptbot_test
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"math/rand"
+	"testing"
+
+	"github.com/go-aie/gptbot"
+)
+
+// randomChunks generates n chunks with random normalized embeddings, which
+// are evenly distributed among the given number of documents.
+func randomChunks(r *rand.Rand, n, dim, docs int) map[string][]*gptbot.Chunk {
+	chunks := make(map[string][]*gptbot.Chunk)
+	for i := 0; i < n; i++ {
+		docID := fmt.Sprintf("doc_%d", i%docs)
+		chunks[docID] = append(chunks[docID], &gptbot.Chunk{
+			ID:         fmt.Sprintf("chunk_%d", i),
+			DocumentID: docID,
+			Embedding:  randomEmbedding(r, dim),
+		})
+	}
+	return chunks
+}
+
+func randomEmbedding(r *rand.Rand, dim int) gptbot.Embedding {
+	emb := make(gptbot.Embedding, dim)
+	var norm float64
+	for i := range emb {
+		emb[i] = r.NormFloat64()
+		norm += emb[i] * emb[i]
+	}
+	norm = math.Sqrt(norm)
+	for i := range emb {
+		emb[i] /= norm
+	}
+	return emb
+}
+
+// recall returns the fraction of the exact results found in the approximate ones.
+func recall(exact, approx []*gptbot.Similarity) float64 {
+	if len(exact) == 0 {
+		return 1
+	}
+
+	found := make(map[*gptbot.Chunk]bool)
+	for _, s := range approx {
+		found[s.Chunk] = true
+	}
+	var hits int
+	for _, s := range exact {
+		if found[s.Chunk] {
+			hits++
+		}
+	}
+	return float64(hits) / float64(len(exact))
+}
+
+func TestLocalVectorStore_HNSW(t *testing.T) {
+	const (
+		n, dim, docs = 2000, 32, 100
+		queries      = 50
+		topK         = 10
+		minRecall    = 0.9
+	)
+
+	r := rand.New(rand.NewSource(1))
+	chunks := randomChunks(r, n, dim, docs)
+
+	exact := gptbot.NewLocalVectorStore()
+	approx := gptbot.NewLocalVectorStoreWithConfig(&gptbot.LocalVectorStoreConfig{
+		HNSW: &gptbot.HNSWConfig{},
+	})
+	// Insert incrementally.
+	for docID, chunkList := range chunks {
+		m := map[string][]*gptbot.Chunk{docID: chunkList}
+		_ = exact.Insert(context.Background(), m)
+		_ = approx.Insert(context.Background(), m)
+	}
+
+	checkRecall := func(deleted map[string]bool) {
+		var total float64
+		for i := 0; i < queries; i++ {
+			q := randomEmbedding(r, dim)
+			want, _ := exact.Query(context.Background(), q, "", topK, nil)
+			got, err := approx.Query(context.Background(), q, "", topK, nil)
+			if err != nil {
+				t.Fatalf("err: %v\n", err)
+			}
+			if len(got) != topK {
+				t.Fatalf("Got (%d) != Want (%d) similarities", len(got), topK)
+			}
+			for _, s := range got {
+				if deleted[s.DocumentID] {
+					t.Fatalf("unexpected chunk %s of deleted document %s", s.ID, s.DocumentID)
+				}
+			}
+			total += recall(want, got)
+		}
+		if got := total / queries; got < minRecall {
+			t.Errorf("Got recall (%v) < Want (%v)", got, minRecall)
+		}
+	}
+
+	checkRecall(nil)
+
+	// Delete half of the documents.
+	deleted := make(map[string]bool)
+	var docIDs []string
+	for i := 0; i < docs; i += 2 {
+		docID := fmt.Sprintf("doc_%d", i)
+		deleted[docID] = true
+		docIDs = append(docIDs, docID)
+	}
+	_ = exact.Delete(context.Background(), docIDs...)
+	_ = approx.Delete(context.Background(), docIDs...)
+
+	checkRecall(deleted)
+
+	// Delete half of the remaining documents incrementally.
+	for i := 1; i < docs; i += 4 {
+		docID := fmt.Sprintf("doc_%d", i)
+		deleted[docID] = true
+		_ = exact.Delete(context.Background(), docID)
+		_ = approx.Delete(context.Background(), docID)
+	}
+
+	checkRecall(deleted)
+}
+
+func BenchmarkLocalVectorStore_Delete(b *testing.B) {
+	const n, dim = 10000, 64
+
+	r := rand.New(rand.NewSource(1))
+	store := gptbot.NewLocalVectorStoreWithConfig(&gptbot.LocalVectorStoreConfig{
+		HNSW: &gptbot.HNSWConfig{},
+	})
+	_ = store.Insert(context.Background(), randomChunks(r, n, dim, n))
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		docID := fmt.Sprintf("doc_%d", i%n)
+		chunks, _ := store.GetDocument(context.Background(), docID)
+
+		// Delete the document and add it back, to keep the index size.
+		_ = store.Delete(context.Background(), docID)
+		b.StopTimer()
+		_ = store.Insert(context.Background(), map[string][]*gptbot.Chunk{docID: chunks})
+		b.StartTimer()
+	}
+}
+
+func BenchmarkLocalVectorStore_Query(b *testing.B) {
+	const (
+		n, dim, docs = 10000, 64, 1000
+		queries      = 100
+		topK         = 10
+	)
+
+	r := rand.New(rand.NewSource(1))
+	chunks := randomChunks(r, n, dim, docs)
+	qs := make([]gptbot.Embedding, queries)
+	for i := range qs {
+		qs[i] = randomEmbedding(r, dim)
+	}
+
+	exact := gptbot.NewLocalVectorStore()
+	_ = exact.Insert(context.Background(), chunks)
+
+	want := make([][]*gptbot.Similarity, queries)
+	for i, q := range qs {
+		want[i], _ = exact.Query(context.Background(), q, "", topK, nil)
+	}
+
+	stores := []struct {
+		name  string
+		store *gptbot.LocalVectorStore
+	}{
+		{name: "brute-force", store: exact},
+	}
+	for _, ef := range []int{16, 64, 256} {
+		store := gptbot.NewLocalVectorStoreWithConfig(&gptbot.LocalVectorStoreConfig{
+			HNSW: &gptbot.HNSWConfig{EfSearch: ef},
+		})
+		_ = store.Insert(context.Background(), chunks)
+		stores = append(stores, struct {
+			name  string
+			store *gptbot.LocalVectorStore
+		}{name: fmt.Sprintf("hnsw-ef%d", ef), store: store})
+	}
+
+	for _, s := range stores {
+		b.Run(s.name, func(b *testing.B) {
+			var total float64
+			for i := 0; i < b.N; i++ {
+				got, _ := s.store.Query(context.Background(), qs[i%queries], "", topK, nil)
+				total += recall(want[i%queries], got)
+			}
+			b.ReportMetric(total/float64(b.N), "recall")
+		})
+	}
+}
@@ -0,0 +1,72 @@
+package gptbot
+
+import (
+	"context"
+	"errors"
+)
+
+var (
+	ErrDocumentNotFound = errors.New("document not found")
+)
+
+// DocumentInfo summarizes an indexed document.
+type DocumentInfo struct {
+	ID       string `json:"id"`
+	CorpusID string `json:"corpus_id,omitempty"`
+	ChunkNum int    `json:"chunk_num"`
+}
+
+// Stats are the statistics of a vector store.
+type Stats struct {
+	DocumentNum int `json:"document_num"`
+	ChunkNum    int `json:"chunk_num"`
+
+	// CorpusNum is the number of the corpora, excluding the empty corpus ID.
+	CorpusNum int `json:"corpus_num"`
+}
+
+// Lister is a vector store, which can enumerate the indexed documents.
+type Lister interface {
+	// ListDocuments returns the documents sorted by IDs, which are restricted
+	// to the given corpus if corpusID is not empty. At most limit documents
+	// (or all if limit is 0) are returned after skipping the first offset ones,
+	// along with the total number of the documents.
+	ListDocuments(ctx context.Context, corpusID string, offset, limit int) (documents []*DocumentInfo, total int, err error)
+
+	// GetDocument returns the chunks of the given document, or ErrDocumentNotFound
+	// if the document does not exist. Note that the embeddings may be omitted
+	// if they are not retrieved by the store.
+	GetDocument(ctx context.Context, documentID string) ([]*Chunk, error)
+
+	// Stats returns the statistics of the store.
+	Stats(ctx context.Context) (*Stats, error)
+}
+
+// Paginate returns the documents in the page specified by offset and limit
+// (0 means no limit), which is a helper for implementing Lister.
+func Paginate(documents []*DocumentInfo, offset, limit int) []*DocumentInfo {
+	if offset < 0 {
+		offset = 0
+	}
+	if offset >= len(documents) {
+		return nil
+	}
+	documents = documents[offset:]
+	if limit > 0 && limit < len(documents) {
+		documents = documents[:limit]
+	}
+	return documents
+}
+
+// NewStats computes the statistics from all the documents, which is a helper
+// for implementing Lister.
+func NewStats(documents []*DocumentInfo) *Stats {
+	stats := &Stats{
+		DocumentNum: len(documents),
+		CorpusNum:   len(CorpusIDs(documents)),
+	}
+	for _, doc := range documents {
+		stats.ChunkNum += doc.ChunkNum
+	}
+	return stats
+}
@@ -0,0 +1,97 @@
+package gptbot_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/go-aie/gptbot"
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestLocalVectorStore_Lister(t *testing.T) {
+	ctx := context.Background()
+	store := newPersistTestStore()
+	_ = store.Insert(ctx, map[string][]*gptbot.Chunk{
+		"doc_id_3": {{ID: "id_4", DocumentID: "doc_id_3", Metadata: gptbot.Metadata{CorpusID: "c2"}}},
+	})
+
+	tests := []struct {
+		name          string
+		corpusID      string
+		offset, limit int
+		wantDocuments []*gptbot.DocumentInfo
+		wantTotal     int
+	}{
+		{
+			name: "all",
+			wantDocuments: []*gptbot.DocumentInfo{
+				{ID: "doc_id_1", CorpusID: "c1", ChunkNum: 2},
+				{ID: "doc_id_2", ChunkNum: 1},
+				{ID: "doc_id_3", CorpusID: "c2", ChunkNum: 1},
+			},
+			wantTotal: 3,
+		},
+		{
+			name:   "paginated",
+			offset: 1,
+			limit:  1,
+			wantDocuments: []*gptbot.DocumentInfo{
+				{ID: "doc_id_2", ChunkNum: 1},
+			},
+			wantTotal: 3,
+		},
+		{
+			name:      "out of range",
+			offset:    3,
+			wantTotal: 3,
+		},
+		{
+			name:     "corpus",
+			corpusID: "c2",
+			wantDocuments: []*gptbot.DocumentInfo{
+				{ID: "doc_id_3", CorpusID: "c2", ChunkNum: 1},
+			},
+			wantTotal: 1,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			documents, total, err := store.ListDocuments(ctx, tt.corpusID, tt.offset, tt.limit)
+			if err != nil {
+				t.Fatalf("err: %v\n", err)
+			}
+			if !cmp.Equal(documents, tt.wantDocuments) {
+				diff := cmp.Diff(documents, tt.wantDocuments)
+				t.Errorf("Want - Got: %s", diff)
+			}
+			if total != tt.wantTotal {
+				t.Errorf("Got total (%d) != Want (%d)", total, tt.wantTotal)
+			}
+		})
+	}
+
+	chunks, err := store.GetDocument(ctx, "doc_id_1")
+	if err != nil {
+		t.Fatalf("err: %v\n", err)
+	}
+	want := store.GetAllData(ctx)["doc_id_1"]
+	if !cmp.Equal(chunks, want) {
+		diff := cmp.Diff(chunks, want)
+		t.Errorf("Want - Got: %s", diff)
+	}
+
+	if _, err := store.GetDocument(ctx, "doc_id_4"); !errors.Is(err, gptbot.ErrDocumentNotFound) {
+		t.Errorf("Got (%v) != Want (%v)", err, gptbot.ErrDocumentNotFound)
+	}
+
+	stats, err := store.Stats(ctx)
+	if err != nil {
+		t.Fatalf("err: %v\n", err)
+	}
+	wantStats := &gptbot.Stats{DocumentNum: 3, ChunkNum: 4, CorpusNum: 2}
+	if !cmp.Equal(stats, wantStats) {
+		diff := cmp.Diff(stats, wantStats)
+		t.Errorf("Want - Got: %s", diff)
+	}
+}
@@ -0,0 +1,69 @@
+package gptbot
+
+import (
+	"fmt"
+	"math"
+
+	"gonum.org/v1/gonum/floats"
+)
+
+// Metric is the metric type used for measuring the similarity between embeddings.
+//
+// Regardless of the metric, the scores of similarities (i.e. Similarity.Score)
+// are always normalized so that the higher score means more similar.
+type Metric string
+
+const (
+	// MetricCosine is the cosine similarity, whose score is in [-1, 1].
+	MetricCosine Metric = "cosine"
+
+	// MetricDotProduct is the dot product, whose score is the dot product itself.
+	// It's equivalent to MetricCosine for normalized embeddings.
+	MetricDotProduct Metric = "dot_product"
+
+	// MetricL2 is the Euclidean distance d, whose score is 1/(1+d) in (0, 1].
+	MetricL2 Metric = "l2"
+)
+
+// Validate checks whether the metric is supported.
+func (m Metric) Validate() error {
+	switch m {
+	case MetricCosine, MetricDotProduct, MetricL2:
+		return nil
+	default:
+		return fmt.Errorf("unsupported metric %q", m)
+	}
+}
+
+// Score returns the similarity score between the two embeddings.
+// It panics if the metric is not supported (see Validate).
+func (m Metric) Score(a, b Embedding) float64 {
+	switch m {
+	case MetricCosine:
+		return cosine(a, b)
+	case MetricDotProduct:
+		return floats.Dot(a, b)
+	case MetricL2:
+		return L2Score(floats.Distance(a, b, 2))
+	default:
+		panic(m.Validate())
+	}
+}
+
+// L2Score converts the Euclidean distance into the score of MetricL2.
+func L2Score(distance float64) float64 {
+	return 1 / (1 + distance)
+}
+
+// Normalize returns the embedding scaled to unit length. The embedding itself
+// is returned if it's already normalized or it's a zero vector.
+func Normalize(embedding Embedding) Embedding {
+	norm := floats.Norm(embedding, 2)
+	if norm == 0 || math.Abs(norm-1) < 1e-9 {
+		return embedding
+	}
+
+	normalized := make(Embedding, len(embedding))
+	floats.ScaleTo(normalized, 1/norm, embedding)
+	return normalized
+}
@@ -0,0 +1,114 @@
+package gptbot_test
+
+import (
+	"context"
+	"math"
+	"testing"
+
+	"github.com/go-aie/gptbot"
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestMetric_Score(t *testing.T) {
+	a, b := gptbot.Embedding{3, 4}, gptbot.Embedding{6, 8}
+
+	tests := []struct {
+		metric gptbot.Metric
+		want   float64
+	}{
+		{metric: gptbot.MetricCosine, want: 1},
+		{metric: gptbot.MetricDotProduct, want: 50},
+		{metric: gptbot.MetricL2, want: 1.0 / 6},
+	}
+	for _, tt := range tests {
+		if got := tt.metric.Score(a, b); math.Abs(got-tt.want) > 1e-9 {
+			t.Errorf("%s: Got (%v) != Want (%v)", tt.metric, got, tt.want)
+		}
+	}
+}
+
+func TestLocalVectorStore_InvalidMetric(t *testing.T) {
+	_, err := gptbot.OpenLocalVectorStore(&gptbot.LocalVectorStoreConfig{
+		Metric: "cosin",
+		WAL:    &gptbot.WALConfig{Dir: t.TempDir()},
+	})
+	if err == nil {
+		t.Errorf("want an error for an unsupported metric")
+	}
+
+	defer func() {
+		if recover() == nil {
+			t.Errorf("want a panic for an unsupported metric")
+		}
+	}()
+	gptbot.NewLocalVectorStoreWithConfig(&gptbot.LocalVectorStoreConfig{Metric: "cosin"})
+}
+
+func TestNormalize(t *testing.T) {
+	got := gptbot.Normalize(gptbot.Embedding{3, 4})
+	want := gptbot.Embedding{0.6, 0.8}
+	if !cmp.Equal(got, want, cmp.Comparer(func(x, y float64) bool { return math.Abs(x-y) < 1e-9 })) {
+		t.Errorf("Got (%v) != Want (%v)", got, want)
+	}
+
+	zero := gptbot.Embedding{0, 0}
+	if got := gptbot.Normalize(zero); !cmp.Equal(got, zero) {
+		t.Errorf("Got (%v) != Want (%v)", got, zero)
+	}
+}
+
+func TestLocalVectorStore_QueryMetric(t *testing.T) {
+	chunks := func() map[string][]*gptbot.Chunk {
+		return map[string][]*gptbot.Chunk{
+			"doc_id_1": {
+				// Same direction as the query, but far away.
+				{ID: "id_1", DocumentID: "doc_id_1", Embedding: gptbot.Embedding{10, 0}},
+				// Close to the query, but in a different direction.
+				{ID: "id_2", DocumentID: "doc_id_1", Embedding: gptbot.Embedding{1, 0.5}},
+			},
+		}
+	}
+	query := gptbot.Embedding{1, 0}
+
+	tests := []struct {
+		metric    gptbot.Metric
+		normalize bool
+		want      []string
+	}{
+		{metric: gptbot.MetricDotProduct, want: []string{"id_1", "id_2"}},
+		{metric: gptbot.MetricCosine, want: []string{"id_1", "id_2"}},
+		{metric: gptbot.MetricL2, want: []string{"id_2", "id_1"}},
+		{metric: gptbot.MetricL2, normalize: true, want: []string{"id_1", "id_2"}},
+	}
+	for _, tt := range tests {
+		store := gptbot.NewLocalVectorStoreWithConfig(&gptbot.LocalVectorStoreConfig{
+			Metric:    tt.metric,
+			Normalize: tt.normalize,
+		})
+		input := chunks()
+		_ = store.Insert(context.Background(), input)
+
+		// The inserted chunks are never modified.
+		if !cmp.Equal(input, chunks()) {
+			diff := cmp.Diff(input, chunks())
+			t.Errorf("%s: Want - Got: %s", tt.metric, diff)
+		}
+
+		got, err := store.Query(context.Background(), query, "", 2, nil)
+		if err != nil {
+			t.Fatalf("err: %v\n", err)
+		}
+
+		var gotIDs []string
+		for i, s := range got {
+			gotIDs = append(gotIDs, s.ID)
+			if i > 0 && s.Score > got[i-1].Score {
+				t.Errorf("%s: scores are not in descending order", tt.metric)
+			}
+		}
+		if !cmp.Equal(gotIDs, tt.want) {
+			diff := cmp.Diff(gotIDs, tt.want)
+			t.Errorf("%s: Want - Got: %s", tt.metric, diff)
+		}
+	}
+}
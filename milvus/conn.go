@@ -0,0 +1,122 @@
+package milvus
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/go-aie/xslices"
+	"github.com/milvus-io/milvus-sdk-go/v2/client"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/backoff"
+	"google.golang.org/grpc/credentials"
+)
+
+// RetryConfig is the configuration of retries with exponential backoff.
+type RetryConfig struct {
+	// MaxRetries is the maximum number of retries for connecting to the server.
+	// A negative value disables retries, in which case the connection fails
+	// after the first failed attempt.
+	// Defaults to 5.
+	MaxRetries int
+
+	// InitialBackoff is the backoff after the first failure, which is doubled
+	// after each subsequent failure.
+	// Defaults to 100ms.
+	InitialBackoff time.Duration
+
+	// MaxBackoff is the upper bound of the backoff.
+	// Defaults to 3s.
+	MaxBackoff time.Duration
+}
+
+func (cfg *RetryConfig) init() {
+	if cfg.MaxRetries == 0 {
+		cfg.MaxRetries = 5
+	}
+	if cfg.InitialBackoff == 0 {
+		cfg.InitialBackoff = 100 * time.Millisecond
+	}
+	if cfg.MaxBackoff == 0 {
+		cfg.MaxBackoff = 3 * time.Second
+	}
+}
+
+// backoff returns the backoff after the given number (1-based) of failures.
+func (cfg *RetryConfig) backoff(failures int) time.Duration {
+	d := cfg.InitialBackoff
+	for i := 1; i < failures && d < cfg.MaxBackoff; i++ {
+		d *= 2
+	}
+	return xslices.Min(d, cfg.MaxBackoff)
+}
+
+// clientConfig returns the configuration of the Milvus client.
+func (cfg *Config) clientConfig() client.Config {
+	// Keep the default options, but reconnect with our backoff.
+	opts := append([]grpc.DialOption{}, client.DefaultGrpcOpts...)
+	opts = append(opts, grpc.WithConnectParams(grpc.ConnectParams{
+		Backoff: backoff.Config{
+			BaseDelay:  cfg.Retry.InitialBackoff,
+			Multiplier: 2,
+			Jitter:     0.2,
+			MaxDelay:   cfg.Retry.MaxBackoff,
+		},
+		MinConnectTimeout: xslices.Min(cfg.DialTimeout, 3*time.Second),
+	}))
+	if cfg.TLS != nil {
+		// This overrides the transport credentials set by the client.
+		opts = append(opts, grpc.WithTransportCredentials(credentials.NewTLS(cfg.TLS)))
+	}
+
+	return client.Config{
+		Address:     cfg.Addr,
+		Username:    cfg.Username,
+		Password:    cfg.Password,
+		APIKey:      cfg.APIKey,
+		DialOptions: opts,
+	}
+}
+
+// connect connects to the Milvus server by dial, and retries on failure
+// (e.g. when the server is restarting) with exponential backoff.
+func connect(ctx context.Context, cfg *Config, dial func(context.Context, client.Config) (client.Client, error)) (client.Client, error) {
+	for failures := 0; ; {
+		dialCtx, cancel := context.WithTimeout(ctx, cfg.DialTimeout)
+		c, err := dial(dialCtx, cfg.clientConfig())
+		cancel()
+		if err == nil {
+			return c, nil
+		}
+
+		failures++
+		if failures > cfg.Retry.MaxRetries {
+			return nil, fmt.Errorf("failed to connect to %s after %d attempts: %w", cfg.Addr, failures, err)
+		}
+
+		select {
+		case <-time.After(cfg.Retry.backoff(failures)):
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+}
+
+// Ping checks whether the Milvus server is healthy, which is useful for
+// readiness probes.
+func (m *Milvus) Ping(ctx context.Context) error {
+	state, err := m.client.CheckHealth(ctx)
+	if err != nil {
+		return err
+	}
+	if !state.IsHealthy {
+		return fmt.Errorf("milvus is unhealthy: %s", strings.Join(state.Reasons, "; "))
+	}
+	return nil
+}
+
+// Close closes the connection to the Milvus server.
+func (m *Milvus) Close() error {
+	return m.client.Close()
+}
@@ -0,0 +1,146 @@
+package milvus
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/milvus-io/milvus-sdk-go/v2/client"
+)
+
+func TestRetryConfig_Backoff(t *testing.T) {
+	cfg := &RetryConfig{InitialBackoff: 100 * time.Millisecond, MaxBackoff: time.Second}
+	cfg.init()
+
+	var got []time.Duration
+	for failures := 1; failures <= 6; failures++ {
+		got = append(got, cfg.backoff(failures))
+	}
+	want := []time.Duration{
+		100 * time.Millisecond,
+		200 * time.Millisecond,
+		400 * time.Millisecond,
+		800 * time.Millisecond,
+		time.Second,
+		time.Second,
+	}
+	if !cmp.Equal(got, want) {
+		diff := cmp.Diff(got, want)
+		t.Errorf("Want - Got: %s", diff)
+	}
+}
+
+func TestConnect(t *testing.T) {
+	errUnavailable := errors.New("unavailable")
+
+	tests := []struct {
+		name         string
+		maxRetries   int
+		failures     int
+		wantAttempts int
+		wantErr      bool
+	}{
+		{
+			name:         "connected",
+			maxRetries:   3,
+			failures:     0,
+			wantAttempts: 1,
+		},
+		{
+			name:         "connected after retries",
+			maxRetries:   3,
+			failures:     2,
+			wantAttempts: 3,
+		},
+		{
+			name:         "too many failures",
+			maxRetries:   3,
+			failures:     10,
+			wantAttempts: 4,
+			wantErr:      true,
+		},
+		{
+			name:         "retries disabled",
+			maxRetries:   -1,
+			failures:     1,
+			wantAttempts: 1,
+			wantErr:      true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := &Config{
+				CollectionName: "test",
+				Username:       "user",
+				Password:       "secret",
+				Retry:          &RetryConfig{MaxRetries: tt.maxRetries, InitialBackoff: time.Millisecond},
+			}
+			cfg.init()
+
+			attempts := 0
+			dial := func(ctx context.Context, config client.Config) (client.Client, error) {
+				attempts++
+				if config.Username != "user" || config.Password != "secret" {
+					t.Errorf("unexpected credentials: %s/%s", config.Username, config.Password)
+				}
+				if _, ok := ctx.Deadline(); !ok {
+					t.Errorf("want a dial timeout")
+				}
+				if attempts <= tt.failures {
+					return nil, errUnavailable
+				}
+				return newFakeClient(), nil
+			}
+
+			_, err := connect(context.Background(), cfg, dial)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Got err (%v), Want err (%v)", err, tt.wantErr)
+			}
+			if err != nil && !errors.Is(err, errUnavailable) {
+				t.Errorf("Got err (%v), Want err (%v)", err, errUnavailable)
+			}
+			if attempts != tt.wantAttempts {
+				t.Errorf("Got (%d) != Want (%d)", attempts, tt.wantAttempts)
+			}
+		})
+	}
+}
+
+func TestNewMilvus_SetupTimeout(t *testing.T) {
+	c := newFakeClient()
+	c.hang = true
+	dial := func(ctx context.Context, config client.Config) (client.Client, error) {
+		return c, nil
+	}
+
+	_, err := newMilvus(&Config{CollectionName: "test", SetupTimeout: 10 * time.Millisecond}, dial)
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("Got err (%v), Want err (%v)", err, context.DeadlineExceeded)
+	}
+	if !c.closed {
+		t.Errorf("want the client to be closed")
+	}
+}
+
+func TestMilvus_Ping(t *testing.T) {
+	ctx := context.Background()
+	m, c := newFakeMilvus(&Config{CollectionName: "test"})
+
+	if err := m.Ping(ctx); err != nil {
+		t.Fatalf("err: %v\n", err)
+	}
+
+	c.unhealthy = []string{"proxy not ready"}
+	if err := m.Ping(ctx); err == nil {
+		t.Errorf("want an error for an unhealthy server")
+	}
+
+	if err := m.Close(); err != nil {
+		t.Fatalf("err: %v\n", err)
+	}
+	if !c.closed {
+		t.Errorf("want the client to be closed")
+	}
+}
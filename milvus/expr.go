@@ -0,0 +1,117 @@
+package milvus
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/go-aie/gptbot"
+)
+
+// stringLiteral returns the double-quoted string literal of s in Milvus
+// expressions. Backslashes, double quotes and control characters (including
+// line and paragraph separators) are escaped, so the literal can never be
+// terminated early by s. Invalid UTF-8 bytes are replaced by U+FFFD.
+func stringLiteral(s string) string {
+	var b strings.Builder
+	b.Grow(len(s) + 2)
+	b.WriteByte('"')
+	for _, r := range s {
+		switch {
+		case r == '"' || r == '\\':
+			b.WriteByte('\\')
+			b.WriteRune(r)
+		case r == '\n':
+			b.WriteString(`\n`)
+		case r == '\r':
+			b.WriteString(`\r`)
+		case r == '\t':
+			b.WriteString(`\t`)
+		case unicode.IsControl(r) || r == '\u2028' || r == '\u2029':
+			fmt.Fprintf(&b, `\u%04x`, r)
+		default:
+			b.WriteRune(r)
+		}
+	}
+	b.WriteByte('"')
+	return b.String()
+}
+
+// eqExpr returns the expression matching the rows whose field equals value.
+func eqExpr(field, value string) string {
+	return field + " == " + stringLiteral(value)
+}
+
+// inExpr returns the expression matching the rows whose field equals one of values.
+func inExpr(field string, values []string) string {
+	literals := make([]string, len(values))
+	for i, v := range values {
+		literals[i] = stringLiteral(v)
+	}
+	return field + " in [" + strings.Join(literals, ", ") + "]"
+}
+
+// andExpr combines the non-empty expressions with "and".
+func andExpr(exprs ...string) string {
+	var parts []string
+	for _, expr := range exprs {
+		if expr != "" {
+			parts = append(parts, expr)
+		}
+	}
+	if len(parts) == 1 {
+		return parts[0]
+	}
+	for i, part := range parts {
+		parts[i] = "(" + part + ")"
+	}
+	return strings.Join(parts, " and ")
+}
+
+// validateChunks checks whether all the given chunks are valid.
+func validateChunks(chunks map[string][]*gptbot.Chunk) error {
+	for _, chunkList := range chunks {
+		for _, chunk := range chunkList {
+			if err := validateChunk(chunk); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// validateChunk checks whether the IDs and the text of the given chunk are
+// valid, and fit in the corresponding fields.
+func validateChunk(chunk *gptbot.Chunk) error {
+	if err := validateID("chunk ID", chunk.ID); err != nil {
+		return err
+	}
+	if err := validateID("document ID", chunk.DocumentID); err != nil {
+		return err
+	}
+	if err := validateID("corpus ID", chunk.Metadata.CorpusID); err != nil {
+		return err
+	}
+	if len(chunk.Text) > maxVarCharLength {
+		return fmt.Errorf("%w: text of chunk %q (document %q) has %d bytes, exceeding the limit of %d bytes", ErrFieldTooLong, chunk.ID, chunk.DocumentID, len(chunk.Text), maxVarCharLength)
+	}
+	return nil
+}
+
+// validateID checks whether the given ID (of a chunk, a document or a corpus)
+// can be stored and matched exactly.
+func validateID(name, id string) error {
+	if len(id) > maxVarCharLength {
+		return fmt.Errorf("%w: %s has %d bytes, exceeding the limit of %d bytes", ErrFieldTooLong, name, len(id), maxVarCharLength)
+	}
+	if !utf8.ValidString(id) {
+		return fmt.Errorf("invalid %s %q: not valid UTF-8", name, id)
+	}
+	for _, r := range id {
+		if unicode.IsControl(r) {
+			return fmt.Errorf("invalid %s %q: contains control characters", name, id)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,149 @@
+package milvus
+
+import (
+	"context"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/go-aie/gptbot"
+	"github.com/google/go-cmp/cmp"
+)
+
+var hostileIDs = []string{
+	`x" or corpus_id != "`,
+	`x\" || true || \"`,
+	`x\`,
+	`x'`,
+	"x\n or true",
+	"x ",
+	"x\x7f",
+	"中文",
+}
+
+func TestStringLiteral(t *testing.T) {
+	for _, s := range hostileIDs {
+		literal := stringLiteral(s)
+		// Milvus parses string literals in the same way as Go.
+		got, err := strconv.Unquote(literal)
+		if err != nil {
+			t.Fatalf("err: %v\n", err)
+		}
+		if got != s {
+			t.Errorf("Got (%q) != Want (%q)", got, s)
+		}
+	}
+}
+
+func TestExprBuilders(t *testing.T) {
+	tests := []struct {
+		got  string
+		want string
+	}{
+		{
+			got:  eqExpr(corpusIDName, `x" or corpus_id != "`),
+			want: `corpus_id == "x\" or corpus_id != \""`,
+		},
+		{
+			got:  inExpr(documentIDName, []string{`a"]`, `b\`}),
+			want: `document_id in ["a\"]", "b\\"]`,
+		},
+		{
+			got:  andExpr("", eqExpr(corpusIDName, "c"), ""),
+			want: `corpus_id == "c"`,
+		},
+		{
+			got:  andExpr(eqExpr(corpusIDName, "c"), "a or b"),
+			want: `(corpus_id == "c") and (a or b)`,
+		},
+	}
+	for _, tt := range tests {
+		if tt.got != tt.want {
+			t.Errorf("Got (%s) != Want (%s)", tt.got, tt.want)
+		}
+	}
+}
+
+func TestMilvus_HostileIDs(t *testing.T) {
+	ctx := context.Background()
+	m, c := newFakeMilvus(&Config{CollectionName: "test", Dim: 2})
+
+	corpusID, documentID := hostileIDs[0], hostileIDs[1]
+	if _, err := m.Query(ctx, gptbot.Embedding{1, 0}, corpusID, 1, nil); err != nil {
+		t.Fatalf("err: %v\n", err)
+	}
+	if err := m.Delete(ctx, documentID); err != nil {
+		t.Fatalf("err: %v\n", err)
+	}
+	if err := m.DeleteCorpus(ctx, corpusID); err != nil {
+		t.Fatalf("err: %v\n", err)
+	}
+
+	wantSearched := []fakeSearch{{Expr: `corpus_id == "x\" or corpus_id != \""`}}
+	if !cmp.Equal(c.searched, wantSearched) {
+		diff := cmp.Diff(c.searched, wantSearched)
+		t.Errorf("Want - Got: %s", diff)
+	}
+	wantQueried := []string{`document_id in ["x\\\" || true || \\\""]`}
+	if !cmp.Equal(c.queried, wantQueried) {
+		diff := cmp.Diff(c.queried, wantQueried)
+		t.Errorf("Want - Got: %s", diff)
+	}
+	wantDeleted := []string{`corpus_id == "x\" or corpus_id != \""`}
+	if !cmp.Equal(c.deleted, wantDeleted) {
+		diff := cmp.Diff(c.deleted, wantDeleted)
+		t.Errorf("Want - Got: %s", diff)
+	}
+}
+
+func TestMilvus_InsertValidation(t *testing.T) {
+	tests := []struct {
+		name    string
+		chunk   *gptbot.Chunk
+		wantErr bool
+	}{
+		{
+			name:  "quotes",
+			chunk: &gptbot.Chunk{ID: `"1"`, DocumentID: `doc'1`, Metadata: gptbot.Metadata{CorpusID: `c\"`}},
+		},
+		{
+			name:    "invalid UTF-8",
+			chunk:   &gptbot.Chunk{ID: "1", DocumentID: "doc\xff"},
+			wantErr: true,
+		},
+		{
+			name:    "control characters",
+			chunk:   &gptbot.Chunk{ID: "1", DocumentID: "doc", Metadata: gptbot.Metadata{CorpusID: "c\x00"}},
+			wantErr: true,
+		},
+		{
+			name:  "longest text",
+			chunk: &gptbot.Chunk{ID: "1", DocumentID: "doc", Text: strings.Repeat("a", maxVarCharLength)},
+		},
+		{
+			name:    "text too long",
+			chunk:   &gptbot.Chunk{ID: "1", DocumentID: "doc", Text: strings.Repeat("a", maxVarCharLength+1)},
+			wantErr: true,
+		},
+		{
+			name:    "ID too long",
+			chunk:   &gptbot.Chunk{ID: strings.Repeat("1", maxVarCharLength+1), DocumentID: "doc"},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m, c := newFakeMilvus(&Config{CollectionName: "test", Dim: 2})
+			tt.chunk.Embedding = gptbot.Embedding{1, 0}
+			err := m.Insert(context.Background(), map[string][]*gptbot.Chunk{
+				tt.chunk.DocumentID: {tt.chunk},
+			})
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Got err (%v), Want err (%v)", err, tt.wantErr)
+			}
+			if tt.wantErr && len(c.inserted) != 0 {
+				t.Errorf("want nothing inserted")
+			}
+		})
+	}
+}
@@ -0,0 +1,189 @@
+package milvus
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/milvus-io/milvus-sdk-go/v2/client"
+	"github.com/milvus-io/milvus-sdk-go/v2/entity"
+)
+
+// fakeClient is a fake Milvus client, which records the calls of interest.
+// Calling any other method will panic.
+type fakeClient struct {
+	client.Client
+
+	partitions map[string]bool
+	// inserted are the numbers of the inserted rows per partition.
+	inserted map[string]int
+	// batches are the numbers of the rows of the inserts.
+	batches []int
+	// searched are the partitions and expressions of the searches.
+	searched []fakeSearch
+	queried  []string
+	deleted  []string
+	// pks are the primary keys returned by queries, if any.
+	pks        []int64
+	deletedPKs []int64
+	// searchParams are the index-specific parameters of the searches.
+	searchParams []map[string]any
+	// levels are the consistency levels of the searches and queries.
+	levels []entity.ConsistencyLevel
+	// index is the index created on the embeddings.
+	index entity.Index
+	// collection is the existing collection, if any.
+	collection *entity.Collection
+	// names are the names of the existing collections, which is used instead
+	// of collection for checking the existence if not nil.
+	names map[string]bool
+	// columns are the columns of the last insert.
+	columns []entity.Column
+	// hang specifies whether the server hangs, i.e. checking the collection
+	// blocks until the context is done.
+	hang bool
+	// unhealthy are the reasons why the server is unhealthy, if any.
+	unhealthy []string
+	closed    bool
+}
+
+type fakeSearch struct {
+	Partitions []string
+	Expr       string
+}
+
+func newFakeClient() *fakeClient {
+	return &fakeClient{
+		partitions: make(map[string]bool),
+		inserted:   make(map[string]int),
+	}
+}
+
+func (c *fakeClient) HasPartition(ctx context.Context, collName string, partitionName string) (bool, error) {
+	return c.partitions[partitionName], nil
+}
+
+func (c *fakeClient) CreatePartition(ctx context.Context, collName string, partitionName string, opts ...client.CreatePartitionOption) error {
+	c.partitions[partitionName] = true
+	return nil
+}
+
+func (c *fakeClient) LoadPartitions(ctx context.Context, collName string, partitionNames []string, async bool, opts ...client.LoadPartitionsOption) error {
+	return nil
+}
+
+func (c *fakeClient) ReleasePartitions(ctx context.Context, collName string, partitionNames []string, opts ...client.ReleasePartitionsOption) error {
+	return nil
+}
+
+func (c *fakeClient) DropPartition(ctx context.Context, collName string, partitionName string, opts ...client.DropPartitionOption) error {
+	delete(c.partitions, partitionName)
+	return nil
+}
+
+func (c *fakeClient) Insert(ctx context.Context, collName string, partitionName string, columns ...entity.Column) (entity.Column, error) {
+	c.inserted[partitionName] += columns[0].Len()
+	c.batches = append(c.batches, columns[0].Len())
+	c.columns = columns
+	return nil, nil
+}
+
+func (c *fakeClient) Delete(ctx context.Context, collName string, partitionName string, expr string) error {
+	c.deleted = append(c.deleted, expr)
+	return nil
+}
+
+func (c *fakeClient) Search(ctx context.Context, collName string, partitions []string, expr string, outputFields []string, vectors []entity.Vector, vectorField string, metricType entity.MetricType, topK int, sp entity.SearchParam, opts ...client.SearchQueryOptionFunc) ([]client.SearchResult, error) {
+	c.searched = append(c.searched, fakeSearch{Partitions: partitions, Expr: expr})
+	c.searchParams = append(c.searchParams, sp.Params())
+	c.levels = append(c.levels, consistencyLevelOf(opts))
+	return nil, nil
+}
+
+func (c *fakeClient) Query(ctx context.Context, collectionName string, partitionNames []string, expr string, outputFields []string, opts ...client.SearchQueryOptionFunc) (client.ResultSet, error) {
+	c.queried = append(c.queried, expr)
+	c.levels = append(c.levels, consistencyLevelOf(opts))
+	if c.pks == nil {
+		// Milvus returns no columns if nothing matches.
+		return client.ResultSet{}, nil
+	}
+	return client.ResultSet{entity.NewColumnInt64(pkName, c.pks)}, nil
+}
+
+func (c *fakeClient) DeleteByPks(ctx context.Context, collName string, partitionName string, ids entity.Column) error {
+	c.deletedPKs = append(c.deletedPKs, ids.(*entity.ColumnInt64).Data()...)
+	return nil
+}
+
+func (c *fakeClient) HasCollection(ctx context.Context, collName string) (bool, error) {
+	if c.hang {
+		<-ctx.Done()
+		return false, ctx.Err()
+	}
+	if c.names != nil {
+		return c.names[collName], nil
+	}
+	return c.collection != nil, nil
+}
+
+func (c *fakeClient) RenameCollection(ctx context.Context, collName, newName string) error {
+	if !c.names[collName] || c.names[newName] {
+		return fmt.Errorf("can not rename %q to %q", collName, newName)
+	}
+	delete(c.names, collName)
+	c.names[newName] = true
+	return nil
+}
+
+func (c *fakeClient) DropCollection(ctx context.Context, collName string, opts ...client.DropCollectionOption) error {
+	delete(c.names, collName)
+	return nil
+}
+
+func (c *fakeClient) DescribeCollection(ctx context.Context, collName string) (*entity.Collection, error) {
+	return c.collection, nil
+}
+
+func (c *fakeClient) DescribeIndex(ctx context.Context, collName string, fieldName string, opts ...client.IndexOption) ([]entity.Index, error) {
+	if c.index == nil {
+		// This is what Milvus returns.
+		return nil, fmt.Errorf("index not found[collection=%s]", collName)
+	}
+	return []entity.Index{c.index}, nil
+}
+
+func (c *fakeClient) CreateCollection(ctx context.Context, schema *entity.Schema, shardsNum int32, opts ...client.CreateCollectionOption) error {
+	return nil
+}
+
+func (c *fakeClient) CreateIndex(ctx context.Context, collName string, fieldName string, idx entity.Index, async bool, opts ...client.IndexOption) error {
+	c.index = idx
+	return nil
+}
+
+func (c *fakeClient) CheckHealth(ctx context.Context) (*entity.MilvusState, error) {
+	return &entity.MilvusState{IsHealthy: len(c.unhealthy) == 0, Reasons: c.unhealthy}, nil
+}
+
+func (c *fakeClient) Close() error {
+	c.closed = true
+	return nil
+}
+
+// consistencyLevelOf returns the consistency level specified by opts.
+func consistencyLevelOf(opts []client.SearchQueryOptionFunc) entity.ConsistencyLevel {
+	opt := &client.SearchQueryOption{ConsistencyLevel: entity.DefaultConsistencyLevel}
+	for _, o := range opts {
+		o(opt)
+	}
+	return opt.ConsistencyLevel
+}
+
+func newFakeMilvus(cfg *Config) (*Milvus, *fakeClient) {
+	cfg.init()
+	c := newFakeClient()
+	return &Milvus{
+		client:     c,
+		cfg:        cfg,
+		partitions: make(map[string]bool),
+	}, c
+}
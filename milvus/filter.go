@@ -0,0 +1,113 @@
+package milvus
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/go-aie/gptbot"
+)
+
+// filterExpr translates the given filter into a Milvus boolean expression
+// over the JSON field of attributes. An empty expression is returned for a
+// nil filter.
+func filterExpr(f *gptbot.Filter) (string, error) {
+	if f == nil {
+		return "", nil
+	}
+	if err := f.Validate(); err != nil {
+		return "", err
+	}
+	return buildFilterExpr(f)
+}
+
+func buildFilterExpr(f *gptbot.Filter) (string, error) {
+	switch f.Op {
+	case gptbot.OpAnd, gptbot.OpOr:
+		if len(f.Filters) == 0 {
+			// Follow the semantics of gptbot.Filter.Match.
+			if f.Op == gptbot.OpAnd {
+				return "true", nil
+			}
+			return "false", nil
+		}
+		var exprs []string
+		for _, sub := range f.Filters {
+			expr, err := buildFilterExpr(sub)
+			if err != nil {
+				return "", err
+			}
+			exprs = append(exprs, "("+expr+")")
+		}
+		return strings.Join(exprs, " "+string(f.Op)+" "), nil
+	case gptbot.OpNot:
+		expr, err := buildFilterExpr(f.Filters[0])
+		if err != nil {
+			return "", err
+		}
+		return "not (" + expr + ")", nil
+	}
+
+	field := fmt.Sprintf("%s[%s]", attributesName, stringLiteral(f.Key))
+
+	if f.Op == gptbot.OpIn {
+		values, _ := gptbot.FilterValues(f.Value)
+		var literals []string
+		for _, v := range values {
+			literal, err := filterLiteral(v)
+			if err != nil {
+				return "", err
+			}
+			literals = append(literals, literal)
+		}
+		return fmt.Sprintf("%s in [%s]", field, strings.Join(literals, ", ")), nil
+	}
+
+	literal, err := filterLiteral(f.Value)
+	if err != nil {
+		return "", err
+	}
+
+	var op string
+	switch f.Op {
+	case gptbot.OpEq:
+		op = "=="
+	case gptbot.OpNe:
+		op = "!="
+	case gptbot.OpGt:
+		op = ">"
+	case gptbot.OpGe:
+		op = ">="
+	case gptbot.OpLt:
+		op = "<"
+	case gptbot.OpLe:
+		op = "<="
+	}
+	return fmt.Sprintf("%s %s %s", field, op, literal), nil
+}
+
+// filterLiteral converts the given value into a literal of Milvus expressions.
+func filterLiteral(v any) (string, error) {
+	switch v := v.(type) {
+	case string:
+		return stringLiteral(v), nil
+	case bool:
+		return strconv.FormatBool(v), nil
+	case int, int8, int16, int32, int64, uint, uint8, uint16, uint32, uint64:
+		return fmt.Sprint(v), nil
+	case float32:
+		return floatLiteral(float64(v), 32)
+	case float64:
+		return floatLiteral(v, 64)
+	default:
+		return "", fmt.Errorf("unsupported filter value %v (type %T)", v, v)
+	}
+}
+
+func floatLiteral(v float64, bitSize int) (string, error) {
+	if math.IsNaN(v) || math.IsInf(v, 0) {
+		return "", fmt.Errorf("unsupported filter value %v", v)
+	}
+	return strconv.FormatFloat(v, 'g', -1, bitSize), nil
+}
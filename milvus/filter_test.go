@@ -0,0 +1,40 @@
+package milvus
+
+import (
+	"testing"
+
+	"github.com/go-aie/gptbot"
+)
+
+func TestFilterExpr(t *testing.T) {
+	tests := []struct {
+		in   *gptbot.Filter
+		want string
+	}{
+		{
+			in:   nil,
+			want: "",
+		},
+		{
+			in:   gptbot.Eq("product", "X"),
+			want: `attributes["product"] == "X"`,
+		},
+		{
+			in:   gptbot.And(gptbot.Eq("product", "X"), gptbot.Ge("version", 3)),
+			want: `(attributes["product"] == "X") and (attributes["version"] >= 3)`,
+		},
+		{
+			in:   gptbot.Or(gptbot.In("product", "X", "Y"), gptbot.Not(gptbot.Eq("internal", true))),
+			want: `(attributes["product"] in ["X", "Y"]) or (not (attributes["internal"] == true))`,
+		},
+	}
+	for _, tt := range tests {
+		got, err := filterExpr(tt.in)
+		if err != nil {
+			t.Fatalf("err: %v\n", err)
+		}
+		if got != tt.want {
+			t.Errorf("Got (%s) != Want (%s)", got, tt.want)
+		}
+	}
+}
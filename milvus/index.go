@@ -0,0 +1,203 @@
+package milvus
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/go-aie/xslices"
+	"github.com/milvus-io/milvus-sdk-go/v2/client"
+	"github.com/milvus-io/milvus-sdk-go/v2/entity"
+)
+
+type IndexType string
+
+const (
+	IndexFlat    IndexType = "FLAT"
+	IndexIVFFlat IndexType = "IVF_FLAT"
+	IndexIVFPQ   IndexType = "IVF_PQ"
+	IndexHNSW    IndexType = "HNSW"
+)
+
+// IndexConfig is the configuration of the index built on the embeddings.
+// Note that it's fixed once the collection is created.
+type IndexConfig struct {
+	// Type is the index type.
+	// Defaults to IndexIVFFlat.
+	Type IndexType
+
+	// NList is the number of cluster units, used by IVF_FLAT and IVF_PQ.
+	// Defaults to 128.
+	NList int
+
+	// PQM is the number of sub-vectors that an embedding is split into,
+	// used by IVF_PQ. The embedding dimension must be a multiple of it.
+	// Defaults to Dim/8 if Dim is a multiple of 8, or Dim otherwise.
+	PQM int
+
+	// PQNBits is the number of bits that each sub-vector is stored in,
+	// used by IVF_PQ.
+	// Defaults to 8.
+	PQNBits int
+
+	// M is the maximum degree of the nodes in the graph, used by HNSW.
+	// Defaults to 16.
+	M int
+
+	// EfConstruction is the size of the dynamic candidate list during the
+	// graph construction, used by HNSW.
+	// Defaults to 200.
+	EfConstruction int
+}
+
+func (cfg *IndexConfig) init(dim int) {
+	if cfg.Type == "" {
+		cfg.Type = IndexIVFFlat
+	}
+	if cfg.NList == 0 {
+		cfg.NList = 128
+	}
+	if cfg.PQM == 0 {
+		cfg.PQM = dim
+		if dim%8 == 0 {
+			cfg.PQM = dim / 8
+		}
+	}
+	if cfg.PQNBits == 0 {
+		cfg.PQNBits = 8
+	}
+	if cfg.M == 0 {
+		cfg.M = 16
+	}
+	if cfg.EfConstruction == 0 {
+		cfg.EfConstruction = 200
+	}
+}
+
+// index creates the index with the given metric type.
+func (cfg *IndexConfig) index(metricType entity.MetricType) (entity.Index, error) {
+	switch cfg.Type {
+	case IndexFlat:
+		return entity.NewIndexFlat(metricType)
+	case IndexIVFFlat:
+		return entity.NewIndexIvfFlat(metricType, cfg.NList)
+	case IndexIVFPQ:
+		return entity.NewIndexIvfPQ(metricType, cfg.NList, cfg.PQM, cfg.PQNBits)
+	case IndexHNSW:
+		return entity.NewIndexHNSW(metricType, cfg.M, cfg.EfConstruction)
+	default:
+		return nil, fmt.Errorf("unsupported index type %q", cfg.Type)
+	}
+}
+
+type ConsistencyLevel string
+
+const (
+	ConsistencyStrong     ConsistencyLevel = "Strong"
+	ConsistencySession    ConsistencyLevel = "Session"
+	ConsistencyBounded    ConsistencyLevel = "Bounded"
+	ConsistencyEventually ConsistencyLevel = "Eventually"
+)
+
+func consistencyLevel(level ConsistencyLevel) (entity.ConsistencyLevel, error) {
+	switch level {
+	case ConsistencyStrong:
+		return entity.ClStrong, nil
+	case ConsistencySession:
+		return entity.ClSession, nil
+	case ConsistencyBounded:
+		return entity.ClBounded, nil
+	case ConsistencyEventually:
+		return entity.ClEventually, nil
+	default:
+		return 0, fmt.Errorf("unsupported consistency level %q", level)
+	}
+}
+
+// SearchParams are the parameters used by searches and queries. In Config,
+// the zero fields take their default values; in WithSearchParams, they fall
+// back to the values in Config.
+type SearchParams struct {
+	// NProbe is the number of cluster units to search, used by IVF_FLAT and IVF_PQ.
+	// Defaults to 16.
+	NProbe int
+
+	// Ef is the size of the dynamic candidate list during the search, used
+	// by HNSW. Note that topK is used instead if it's larger.
+	// Defaults to 64.
+	Ef int
+
+	// ConsistencyLevel is the consistency level of searches and queries.
+	// For example, ConsistencyStrong makes the newly inserted chunks visible
+	// immediately, at the cost of higher latency.
+	// Defaults to ConsistencyBounded (the default level of the collection).
+	ConsistencyLevel ConsistencyLevel
+}
+
+func (p *SearchParams) init() {
+	if p.NProbe == 0 {
+		p.NProbe = 16
+	}
+	if p.Ef == 0 {
+		p.Ef = 64
+	}
+	if p.ConsistencyLevel == "" {
+		p.ConsistencyLevel = ConsistencyBounded
+	}
+}
+
+type contextKeyT string
+
+var contextKey = contextKeyT("github.com/go-aie/gptbot/milvus.SearchParams")
+
+// WithSearchParams returns a copy of the parent context and associates it
+// with the given search parameters, which override those in Config for the
+// calls (e.g. Query and GetDocument) made with the returned context.
+//
+// For example, to query the documents just fed:
+//
+//	ctx = milvus.WithSearchParams(ctx, &milvus.SearchParams{ConsistencyLevel: milvus.ConsistencyStrong})
+func WithSearchParams(ctx context.Context, params *SearchParams) context.Context {
+	return context.WithValue(ctx, contextKey, params)
+}
+
+// searchParams returns the search parameters in effect for the given context.
+func (m *Milvus) searchParams(ctx context.Context) *SearchParams {
+	params := *m.cfg.Search
+	if p, ok := ctx.Value(contextKey).(*SearchParams); ok && p != nil {
+		if p.NProbe != 0 {
+			params.NProbe = p.NProbe
+		}
+		if p.Ef != 0 {
+			params.Ef = p.Ef
+		}
+		if p.ConsistencyLevel != "" {
+			params.ConsistencyLevel = p.ConsistencyLevel
+		}
+	}
+	return &params
+}
+
+// searchParam returns the index-specific parameter for searching topK results.
+func (m *Milvus) searchParam(params *SearchParams, topK int) (entity.SearchParam, error) {
+	switch m.cfg.Index.Type {
+	case IndexFlat:
+		return entity.NewIndexFlatSearchParam()
+	case IndexIVFFlat:
+		return entity.NewIndexIvfFlatSearchParam(params.NProbe)
+	case IndexIVFPQ:
+		return entity.NewIndexIvfPQSearchParam(params.NProbe)
+	case IndexHNSW:
+		return entity.NewIndexHNSWSearchParam(xslices.Max(params.Ef, topK))
+	default:
+		return nil, fmt.Errorf("unsupported index type %q", m.cfg.Index.Type)
+	}
+}
+
+// queryOptions returns the options for searches and queries.
+func queryOptions(params *SearchParams) ([]client.SearchQueryOptionFunc, error) {
+	level, err := consistencyLevel(params.ConsistencyLevel)
+	if err != nil {
+		return nil, err
+	}
+	return []client.SearchQueryOptionFunc{client.WithSearchQueryConsistencyLevel(level)}, nil
+}
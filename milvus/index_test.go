@@ -0,0 +1,124 @@
+package milvus
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/go-aie/gptbot"
+	"github.com/google/go-cmp/cmp"
+	"github.com/milvus-io/milvus-sdk-go/v2/entity"
+)
+
+func TestMilvus_Index(t *testing.T) {
+	ctx := context.Background()
+
+	tests := []struct {
+		in               *IndexConfig
+		wantIndexType    entity.IndexType
+		wantIndexParams  map[string]any
+		wantSearchParams map[string]any
+	}{
+		{
+			in:               nil,
+			wantIndexType:    entity.IvfFlat,
+			wantIndexParams:  map[string]any{"nlist": "128"},
+			wantSearchParams: map[string]any{"nprobe": 16},
+		},
+		{
+			in:               &IndexConfig{Type: IndexFlat},
+			wantIndexType:    entity.Flat,
+			wantIndexParams:  map[string]any{},
+			wantSearchParams: map[string]any{},
+		},
+		{
+			in:               &IndexConfig{Type: IndexIVFPQ, NList: 256},
+			wantIndexType:    entity.IvfPQ,
+			wantIndexParams:  map[string]any{"nlist": "256", "m": "2", "nbits": "8"},
+			wantSearchParams: map[string]any{"nprobe": 16},
+		},
+		{
+			in:               &IndexConfig{Type: IndexHNSW, M: 32},
+			wantIndexType:    entity.HNSW,
+			wantIndexParams:  map[string]any{"M": "32", "efConstruction": "200"},
+			wantSearchParams: map[string]any{"ef": 64},
+		},
+	}
+	for _, tt := range tests {
+		m, c := newFakeMilvus(&Config{CollectionName: "test", Dim: 16, Index: tt.in})
+		if err := m.createCollection(ctx, false); err != nil {
+			t.Fatalf("err: %v\n", err)
+		}
+
+		if got := c.index.IndexType(); got != tt.wantIndexType {
+			t.Errorf("Got (%v) != Want (%v)", got, tt.wantIndexType)
+		}
+		var gotIndexParams map[string]any
+		if err := json.Unmarshal([]byte(c.index.Params()["params"]), &gotIndexParams); err != nil {
+			t.Fatalf("err: %v\n", err)
+		}
+		if !cmp.Equal(gotIndexParams, tt.wantIndexParams) {
+			diff := cmp.Diff(gotIndexParams, tt.wantIndexParams)
+			t.Errorf("Want - Got: %s", diff)
+		}
+
+		if _, err := m.Query(ctx, make(gptbot.Embedding, 16), "", 3, nil); err != nil {
+			t.Fatalf("err: %v\n", err)
+		}
+		if !cmp.Equal(c.searchParams[0], tt.wantSearchParams) {
+			diff := cmp.Diff(c.searchParams[0], tt.wantSearchParams)
+			t.Errorf("Want - Got: %s", diff)
+		}
+	}
+}
+
+func TestMilvus_SearchParams(t *testing.T) {
+	m, c := newFakeMilvus(&Config{
+		CollectionName: "test",
+		Dim:            2,
+		Index:          &IndexConfig{Type: IndexHNSW},
+		Search:         &SearchParams{Ef: 32},
+	})
+
+	ctx := context.Background()
+	strongCtx := WithSearchParams(ctx, &SearchParams{ConsistencyLevel: ConsistencyStrong})
+	efCtx := WithSearchParams(ctx, &SearchParams{Ef: 128})
+
+	for _, ctx := range []context.Context{ctx, strongCtx, efCtx} {
+		if _, err := m.Query(ctx, gptbot.Embedding{1, 0}, "", 3, nil); err != nil {
+			t.Fatalf("err: %v\n", err)
+		}
+	}
+	// Ef is at least topK.
+	if _, err := m.Query(ctx, gptbot.Embedding{1, 0}, "", 100, nil); err != nil {
+		t.Fatalf("err: %v\n", err)
+	}
+	// Queries follow the consistency level too.
+	if _, err := m.GetDocument(strongCtx, "doc_1"); err != gptbot.ErrDocumentNotFound {
+		t.Fatalf("err: %v\n", err)
+	}
+
+	wantSearchParams := []map[string]any{{"ef": 32}, {"ef": 32}, {"ef": 128}, {"ef": 100}}
+	if !cmp.Equal(c.searchParams, wantSearchParams) {
+		diff := cmp.Diff(c.searchParams, wantSearchParams)
+		t.Errorf("Want - Got: %s", diff)
+	}
+	wantLevels := []entity.ConsistencyLevel{entity.ClBounded, entity.ClStrong, entity.ClBounded, entity.ClBounded, entity.ClStrong}
+	if !cmp.Equal(c.levels, wantLevels) {
+		diff := cmp.Diff(c.levels, wantLevels)
+		t.Errorf("Want - Got: %s", diff)
+	}
+}
+
+func TestNewMilvus_InvalidConfig(t *testing.T) {
+	for _, cfg := range []*Config{
+		{CollectionName: "test", Index: &IndexConfig{Type: "DISKANN"}},
+		{CollectionName: "test", Index: &IndexConfig{Type: IndexHNSW, M: 100}},
+		{CollectionName: "test", Index: &IndexConfig{Type: IndexIVFFlat, NList: -1}},
+		{CollectionName: "test", Search: &SearchParams{ConsistencyLevel: "Linearizable"}},
+	} {
+		if _, err := NewMilvus(cfg); err == nil {
+			t.Errorf("want an error for config %+v", cfg)
+		}
+	}
+}
@@ -0,0 +1,139 @@
+package milvus
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/go-aie/gptbot"
+	"github.com/google/go-cmp/cmp"
+	"github.com/milvus-io/milvus-sdk-go/v2/entity"
+)
+
+func TestMilvus_InsertBatches(t *testing.T) {
+	// Each chunk takes about 1 KiB, so a batch holds at most 4 chunks.
+	m, c := newFakeMilvus(&Config{CollectionName: "test", Dim: 2, BatchBytes: 4 << 10})
+
+	var chunks []*gptbot.Chunk
+	for i := 0; i < 10; i++ {
+		chunks = append(chunks, &gptbot.Chunk{
+			ID:         fmt.Sprintf("%d", i),
+			Text:       strings.Repeat("a", 990),
+			DocumentID: "doc_1",
+			Embedding:  gptbot.Embedding{1, 0},
+		})
+	}
+	// A chunk larger than the batch size is inserted alone.
+	chunks = append(chunks, &gptbot.Chunk{ID: "10", Text: strings.Repeat("a", 5000), DocumentID: "doc_1", Embedding: gptbot.Embedding{1, 0}})
+
+	if err := m.Insert(context.Background(), map[string][]*gptbot.Chunk{"doc_1": chunks}); err != nil {
+		t.Fatalf("err: %v\n", err)
+	}
+
+	if want := []int{4, 4, 2, 1}; !cmp.Equal(c.batches, want) {
+		diff := cmp.Diff(c.batches, want)
+		t.Errorf("Want - Got: %s", diff)
+	}
+}
+
+func TestMilvus_InsertTooLong(t *testing.T) {
+	m, c := newFakeMilvus(&Config{CollectionName: "test", Dim: 2})
+
+	err := m.Insert(context.Background(), map[string][]*gptbot.Chunk{
+		"doc_1": {
+			{ID: "1", DocumentID: "doc_1", Text: "short", Embedding: gptbot.Embedding{1, 0}},
+			{ID: "2", DocumentID: "doc_1", Text: strings.Repeat("a", maxVarCharLength+1), Embedding: gptbot.Embedding{1, 0}},
+		},
+	})
+	if !errors.Is(err, ErrFieldTooLong) {
+		t.Fatalf("Got err (%v), Want err (%v)", err, ErrFieldTooLong)
+	}
+	if len(c.batches) != 0 {
+		t.Errorf("want nothing inserted")
+	}
+}
+
+func TestMilvus_Upsert(t *testing.T) {
+	ctx := context.Background()
+	m, c := newFakeMilvus(&Config{CollectionName: "test", Dim: 2})
+	c.pks = []int64{100, 101}
+
+	err := m.Upsert(ctx, map[string][]*gptbot.Chunk{
+		"doc_1": {
+			{ID: "1", DocumentID: "doc_1", Embedding: gptbot.Embedding{1, 0}},
+			{ID: "2", DocumentID: "doc_1", Embedding: gptbot.Embedding{0, 1}},
+		},
+	})
+	if err != nil {
+		t.Fatalf("err: %v\n", err)
+	}
+
+	if want := []string{`(document_id == "doc_1") and (id in ["1", "2"])`}; !cmp.Equal(c.queried, want) {
+		diff := cmp.Diff(c.queried, want)
+		t.Errorf("Want - Got: %s", diff)
+	}
+	if want := []int{2}; !cmp.Equal(c.batches, want) {
+		diff := cmp.Diff(c.batches, want)
+		t.Errorf("Want - Got: %s", diff)
+	}
+	if want := []int64{100, 101}; !cmp.Equal(c.deletedPKs, want) {
+		diff := cmp.Diff(c.deletedPKs, want)
+		t.Errorf("Want - Got: %s", diff)
+	}
+
+	// Nothing is deleted if the chunks are new.
+	c.pks, c.deletedPKs = nil, nil
+	err = m.Upsert(ctx, map[string][]*gptbot.Chunk{
+		"doc_2": {{ID: "1", DocumentID: "doc_2", Embedding: gptbot.Embedding{1, 0}}},
+	})
+	if err != nil {
+		t.Fatalf("err: %v\n", err)
+	}
+	if len(c.deletedPKs) != 0 {
+		t.Errorf("unexpected deletion: %v", c.deletedPKs)
+	}
+}
+
+func TestMilvus_UpsertAfterInsert(t *testing.T) {
+	ctx := context.Background()
+	m, c := newFakeMilvus(&Config{
+		CollectionName: "test",
+		Dim:            2,
+		Search:         &SearchParams{ConsistencyLevel: ConsistencyEventually},
+	})
+
+	chunks := map[string][]*gptbot.Chunk{
+		"doc_1": {{ID: "1", DocumentID: "doc_1", Embedding: gptbot.Embedding{1, 0}}},
+	}
+	if err := m.Insert(ctx, chunks); err != nil {
+		t.Fatalf("err: %v\n", err)
+	}
+	// The chunk just inserted must be found, so the lookup of the old chunks
+	// is strongly consistent, even if the searches are not.
+	if err := m.Upsert(ctx, chunks); err != nil {
+		t.Fatalf("err: %v\n", err)
+	}
+	if _, err := m.Query(ctx, gptbot.Embedding{1, 0}, "", 3, nil); err != nil {
+		t.Fatalf("err: %v\n", err)
+	}
+
+	wantLevels := []entity.ConsistencyLevel{entity.ClStrong, entity.ClEventually}
+	if !cmp.Equal(c.levels, wantLevels) {
+		diff := cmp.Diff(c.levels, wantLevels)
+		t.Errorf("Want - Got: %s", diff)
+	}
+}
+
+func TestMilvus_DeleteNotFound(t *testing.T) {
+	m, c := newFakeMilvus(&Config{CollectionName: "test", Dim: 2})
+
+	// The query result has no pk column.
+	if err := m.Delete(context.Background(), "doc_1"); err != nil {
+		t.Fatalf("err: %v\n", err)
+	}
+	if len(c.deletedPKs) != 0 {
+		t.Errorf("unexpected deletion: %v", c.deletedPKs)
+	}
+}
@@ -2,19 +2,38 @@ package milvus
 
 import (
 	"context"
+	"crypto/tls"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
+	"math"
 	"os"
+	"sort"
 	"strings"
+	"sync"
+	"time"
 
 	"github.com/go-aie/gptbot"
 	"github.com/go-aie/xslices"
 	"github.com/milvus-io/milvus-sdk-go/v2/client"
 	"github.com/milvus-io/milvus-sdk-go/v2/entity"
+	"golang.org/x/exp/maps"
+	"golang.org/x/exp/slices"
 )
 
 const (
-	pkName, idName, textName, documentIDName, corpusIDName, embeddingName = "pk", "id", "text", "document_id", "corpus_id", "embedding"
+	pkName, idName, textName, documentIDName, corpusIDName, attributesName, embeddingName = "pk", "id", "text", "document_id", "corpus_id", "attributes", "embedding"
+
+	// Added in schema version 1.
+	positionName, createdAtName = "position", "created_at"
+
+	// maxVarCharLength is the maximum length (in bytes) of the VarChar fields.
+	maxVarCharLength = 65535
+)
+
+var (
+	ErrFieldTooLong = errors.New("field too long")
 )
 
 type Config struct {
@@ -25,44 +44,171 @@ type Config struct {
 	// CreateNew specifies whether to overwrite if the collection already exists.
 	CreateNew bool
 
-	// Addr is the address of the Milvus server.
+	// Addr is the address of the Milvus server. TLS is enabled automatically
+	// if it starts with "https://".
 	// Defaults to "localhost:19530".
 	Addr string
 
+	// Username and Password are the credentials, if authentication is enabled.
+	Username string
+	Password string
+
+	// APIKey is the API key (e.g. of Zilliz Cloud), which is used instead
+	// of Username and Password if not empty.
+	APIKey string
+
+	// TLS is the TLS configuration, which enables TLS if not nil.
+	TLS *tls.Config
+
+	// DialTimeout is the timeout of each attempt to connect to the server.
+	// Defaults to 10s.
+	DialTimeout time.Duration
+
+	// Retry is the configuration of the retries for connecting to the server.
+	// Once connected, the connection is also re-established with the same
+	// backoff if broken (e.g. when the server restarts).
+	Retry *RetryConfig
+
+	// SetupTimeout is the timeout of setting up the collection once connected
+	// (i.e. creating, checking, migrating and loading it), which makes NewMilvus
+	// fail instead of blocking forever if the server hangs. Note that migrating
+	// a large collection (see Migrate) may need a larger timeout.
+	// Defaults to 5m.
+	SetupTimeout time.Duration
+
 	// Dim is the embedding dimension.
 	// Defaults to 1536 (the dimension generated by OpenAI's Embedding API).
 	Dim int
+
+	// Metric is the metric used for measuring the similarity between embeddings.
+	// Note that it's fixed once the collection is created.
+	// Defaults to gptbot.MetricL2.
+	Metric gptbot.Metric
+
+	// Normalize specifies whether to normalize embeddings to unit length on
+	// insert and query.
+	Normalize bool
+
+	// PartitionByCorpus specifies whether to store the chunks of each corpus
+	// in a separate partition, which is created on demand. As a result, queries
+	// of a specific corpus only search the corresponding partition, and deleting
+	// a corpus simply drops the partition. Note that Milvus limits the number of
+	// partitions per collection (4096 by default), and that the layout must not
+	// be changed once the collection is created.
+	PartitionByCorpus bool
+
+	// Index is the configuration of the index built on the embeddings.
+	// Note that it's fixed once the collection is created.
+	// Defaults to an IVF_FLAT index (see IndexConfig).
+	Index *IndexConfig
+
+	// Search is the default parameters used by searches and queries, which
+	// can be overridden per call by WithSearchParams.
+	Search *SearchParams
+
+	// OutputEmbedding specifies whether to return the embeddings along with
+	// the similarities in Query, which is required by MMR (see gptbot.BotConfig.MMR).
+	// Note that it costs an extra query to Milvus.
+	OutputEmbedding bool
+
+	// BatchBytes is the approximate maximum size (in bytes) of the chunks
+	// sent in a single insert request. Larger inserts are split into batches.
+	// Defaults to 4 MiB.
+	BatchBytes int
+
+	// Migrate specifies whether to migrate the existing collection of an older
+	// schema version to the current one, by copying all the chunks into a new
+	// collection, which then replaces the old one. The migrated chunks have
+	// zero positions, and their creation time is the time of migration.
+	// Note that it can take a long time for large collections, and it's
+	// recommended to back up the collection first. If interrupted, the
+	// migration is restarted or finished on the next NewMilvus call.
+	//
+	// Otherwise, NewMilvus returns ErrSchemaOutdated for such a collection.
+	Migrate bool
 }
 
 func (cfg *Config) init() {
 	if cfg.Addr == "" {
 		cfg.Addr = "localhost:19530"
 	}
+	if cfg.DialTimeout == 0 {
+		cfg.DialTimeout = 10 * time.Second
+	}
+	if cfg.Retry == nil {
+		cfg.Retry = &RetryConfig{}
+	}
+	if cfg.SetupTimeout == 0 {
+		cfg.SetupTimeout = 5 * time.Minute
+	}
+	cfg.Retry.init()
 	if cfg.Dim == 0 {
 		cfg.Dim = 1536
 	}
+	if cfg.Metric == "" {
+		cfg.Metric = gptbot.MetricL2
+	}
+	if cfg.Index == nil {
+		cfg.Index = &IndexConfig{}
+	}
+	cfg.Index.init(cfg.Dim)
+	if cfg.Search == nil {
+		cfg.Search = &SearchParams{}
+	}
+	cfg.Search.init()
+	if cfg.BatchBytes == 0 {
+		cfg.BatchBytes = 4 << 20
+	}
+}
+
+// validate checks whether the configuration is supported.
+func (cfg *Config) validate() error {
+	mt, err := metricType(cfg.Metric)
+	if err != nil {
+		return err
+	}
+	if _, err := cfg.Index.index(mt); err != nil {
+		return err
+	}
+	_, err = consistencyLevel(cfg.Search.ConsistencyLevel)
+	return err
 }
 
 type Milvus struct {
 	client client.Client
 	cfg    *Config
+
+	// mu protects partitions.
+	mu sync.Mutex
+	// partitions are the names of the partitions known to exist.
+	partitions map[string]bool
 }
 
 func NewMilvus(cfg *Config) (*Milvus, error) {
+	return newMilvus(cfg, client.NewClient)
+}
+
+func newMilvus(cfg *Config, dial func(context.Context, client.Config) (client.Client, error)) (*Milvus, error) {
 	cfg.init()
-	ctx := context.Background()
+	if err := cfg.validate(); err != nil {
+		return nil, err
+	}
 
-	c, err := client.NewGrpcClient(ctx, cfg.Addr)
+	c, err := connect(context.Background(), cfg, dial)
 	if err != nil {
 		return nil, err
 	}
 
 	m := &Milvus{
-		client: c,
-		cfg:    cfg,
+		client:     c,
+		cfg:        cfg,
+		partitions: make(map[string]bool),
 	}
 
+	ctx, cancel := context.WithTimeout(context.Background(), cfg.SetupTimeout)
+	defer cancel()
 	if err := m.createAndLoadCollection(ctx, cfg.CreateNew); err != nil {
+		_ = c.Close()
 		return nil, err
 	}
 
@@ -88,56 +234,216 @@ func (m *Milvus) LoadJSON(ctx context.Context, filename string) error {
 	return m.Insert(ctx, chunkMap)
 }
 
+// Insert inserts the given chunks, which are split into batches of about
+// Config.BatchBytes. Note that the batches inserted before a failure are
+// not rolled back.
 func (m *Milvus) Insert(ctx context.Context, chunks map[string][]*gptbot.Chunk) error {
+	if err := validateChunks(chunks); err != nil {
+		return err
+	}
+	return m.insertChunks(ctx, chunks)
+}
+
+// Upsert inserts the given chunks, and replaces the existing chunks with the
+// same IDs in the same documents, since chunk IDs are only unique within a
+// document.
+//
+// The new chunks are inserted before the old ones are deleted, so a chunk
+// never disappears from the search results during the upsert, although it
+// may be found twice in a short while.
+func (m *Milvus) Upsert(ctx context.Context, chunks map[string][]*gptbot.Chunk) error {
+	if err := validateChunks(chunks); err != nil {
+		return err
+	}
+
+	pks, err := m.chunkPKs(ctx, chunks)
+	if err != nil {
+		return err
+	}
+	if err := m.insertChunks(ctx, chunks); err != nil {
+		return err
+	}
+
+	if len(pks) == 0 {
+		return nil
+	}
+	return m.client.DeleteByPks(ctx, m.cfg.CollectionName, "", entity.NewColumnInt64(pkName, pks))
+}
+
+// chunkPKs returns the primary keys of the stored chunks, which have the same
+// IDs as the given chunks in the same documents.
+//
+// The chunks are always queried with strong consistency, regardless of the
+// search parameters, since the old chunks inserted recently (e.g. by the
+// previous upsert) would be left behind as duplicates if they were invisible.
+func (m *Milvus) chunkPKs(ctx context.Context, chunks map[string][]*gptbot.Chunk) ([]int64, error) {
+	opts, err := queryOptions(&SearchParams{ConsistencyLevel: ConsistencyStrong})
+	if err != nil {
+		return nil, err
+	}
+
+	chunkIDs := make(map[string][]string)
+	for _, chunkList := range chunks {
+		for _, chunk := range chunkList {
+			chunkIDs[chunk.DocumentID] = append(chunkIDs[chunk.DocumentID], chunk.ID)
+		}
+	}
+
+	documentIDs := maps.Keys(chunkIDs)
+	slices.Sort(documentIDs)
+
+	var pks []int64
+	for _, documentID := range documentIDs {
+		expr := andExpr(eqExpr(documentIDName, documentID), inExpr(idName, chunkIDs[documentID]))
+		result, err := m.client.Query(ctx, m.cfg.CollectionName, nil, expr, []string{pkName}, opts...)
+		if err != nil {
+			return nil, err
+		}
+		// The column may be missing if no chunk matches.
+		if pkCol, ok := result.GetColumn(pkName).(*entity.ColumnInt64); ok {
+			pks = append(pks, pkCol.Data()...)
+		}
+	}
+	return pks, nil
+}
+
+// insertChunks inserts the given (validated) chunks.
+func (m *Milvus) insertChunks(ctx context.Context, chunks map[string][]*gptbot.Chunk) error {
+	if !m.cfg.PartitionByCorpus {
+		var chunkList []*gptbot.Chunk
+		for _, cs := range chunks {
+			chunkList = append(chunkList, cs...)
+		}
+		return m.insert(ctx, "", chunkList)
+	}
+
+	corpusChunks := make(map[string][]*gptbot.Chunk)
+	for _, chunkList := range chunks {
+		for _, chunk := range chunkList {
+			corpusID := chunk.Metadata.CorpusID
+			corpusChunks[corpusID] = append(corpusChunks[corpusID], chunk)
+		}
+	}
+	for corpusID, chunkList := range corpusChunks {
+		if err := m.createPartition(ctx, corpusID); err != nil {
+			return err
+		}
+		if err := m.insert(ctx, partitionName(corpusID), chunkList); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// insert inserts the chunks into the given partition in batches.
+func (m *Milvus) insert(ctx context.Context, partition string, chunks []*gptbot.Chunk) error {
+	attributesList := make([][]byte, len(chunks))
+	for i, chunk := range chunks {
+		attributes, err := marshalAttributes(chunk.Metadata.Attributes)
+		if err != nil {
+			return err
+		}
+		attributesList[i] = attributes
+	}
+
+	start, size := 0, 0
+	for i, chunk := range chunks {
+		rowSize := len(chunk.ID) + len(chunk.Text) + len(chunk.DocumentID) + len(chunk.Metadata.CorpusID) + len(attributesList[i]) + 16 + 4*m.cfg.Dim
+		if i > start && size+rowSize > m.cfg.BatchBytes {
+			if err := m.insertBatch(ctx, partition, chunks[start:i], attributesList[start:i]); err != nil {
+				return err
+			}
+			start, size = i, 0
+		}
+		size += rowSize
+	}
+	if start == len(chunks) {
+		return nil
+	}
+	return m.insertBatch(ctx, partition, chunks[start:], attributesList[start:])
+}
+
+// insertBatch inserts the chunks, along with their encoded attributes, into
+// the given partition in a single request.
+func (m *Milvus) insertBatch(ctx context.Context, partition string, chunks []*gptbot.Chunk, attributesList [][]byte) error {
 	var idList []string
 	var textList []string
 	var documentIDList []string
 	var embeddingList [][]float32
 	var corpusIDList []string
-	for _, chunkList := range chunks {
-		for _, chunk := range chunkList {
-			idList = append(idList, chunk.ID)
-			textList = append(textList, chunk.Text)
-			documentIDList = append(documentIDList, chunk.DocumentID)
-			corpusIDList = append(corpusIDList, chunk.Metadata.CorpusID)
-			embeddingList = append(embeddingList, xslices.Float64ToNumber[float32](chunk.Embedding))
+	var positionList []int64
+	var createdAtList []int64
+	now := time.Now().Unix()
+	for _, chunk := range chunks {
+		createdAt := chunk.CreatedAt
+		if createdAt == 0 {
+			createdAt = now
 		}
+
+		idList = append(idList, chunk.ID)
+		textList = append(textList, chunk.Text)
+		documentIDList = append(documentIDList, chunk.DocumentID)
+		corpusIDList = append(corpusIDList, chunk.Metadata.CorpusID)
+		positionList = append(positionList, int64(chunk.Position))
+		createdAtList = append(createdAtList, createdAt)
+		embeddingList = append(embeddingList, xslices.Float64ToNumber[float32](m.normalize(chunk.Embedding)))
 	}
 
 	idCol := entity.NewColumnVarChar(idName, idList)
 	textCol := entity.NewColumnVarChar(textName, textList)
 	documentIDCol := entity.NewColumnVarChar(documentIDName, documentIDList)
 	corpusIDCol := entity.NewColumnVarChar(corpusIDName, corpusIDList)
+	attributesCol := entity.NewColumnJSONBytes(attributesName, attributesList)
+	positionCol := entity.NewColumnInt64(positionName, positionList)
+	createdAtCol := entity.NewColumnInt64(createdAtName, createdAtList)
 	embeddingCol := entity.NewColumnFloatVector(embeddingName, m.cfg.Dim, embeddingList)
 
-	_, err := m.client.Insert(ctx, m.cfg.CollectionName, "", idCol, textCol, documentIDCol, corpusIDCol, embeddingCol)
+	_, err := m.client.Insert(ctx, m.cfg.CollectionName, partition, idCol, textCol, documentIDCol, corpusIDCol, attributesCol, positionCol, createdAtCol, embeddingCol)
 	return err
 }
 
-// Query searches similarities of the given embedding with default consistency level.
-func (m *Milvus) Query(ctx context.Context, embedding gptbot.Embedding, corpusID string, topK int) ([]*gptbot.Similarity, error) {
-	float32Emb := xslices.Float64ToNumber[float32](embedding)
-	expr := ""
-	if corpusID != "" {
-		expr = fmt.Sprintf(`corpus_id == "%s"`, corpusID)
+// Query searches similarities of the given embedding with the search parameters
+// in Config, or those associated with ctx by WithSearchParams.
+func (m *Milvus) Query(ctx context.Context, embedding gptbot.Embedding, corpusID string, topK int, filter *gptbot.Filter) ([]*gptbot.Similarity, error) {
+	params := m.searchParams(ctx)
+	opts, err := queryOptions(params)
+	if err != nil {
+		return nil, err
+	}
+	sp, err := m.searchParam(params, topK)
+	if err != nil {
+		return nil, err
 	}
 
+	float32Emb := xslices.Float64ToNumber[float32](m.normalize(embedding))
+
+	partitions, scopeExpr, ok, err := m.scope(ctx, corpusID)
+	if err != nil || !ok {
+		return nil, err
+	}
+
+	fExpr, err := filterExpr(filter)
+	if err != nil {
+		return nil, err
+	}
+	expr := andExpr(scopeExpr, fExpr)
+
 	vec2search := []entity.Vector{
 		entity.FloatVector(float32Emb),
 	}
 
-	param, _ := entity.NewIndexFlatSearchParam()
 	result, err := m.client.Search(
 		ctx,
 		m.cfg.CollectionName,
-		nil,
+		partitions,
 		expr,
-		[]string{idName, textName, documentIDName, corpusIDName},
+		[]string{idName, textName, documentIDName, corpusIDName, attributesName, positionName, createdAtName},
 		vec2search,
 		embeddingName,
-		entity.L2,
+		m.metricType(),
 		topK,
-		param,
+		sp,
+		opts...,
 	)
 	if err != nil {
 		return nil, err
@@ -147,7 +453,280 @@ func (m *Milvus) Query(ctx context.Context, embedding gptbot.Embedding, corpusID
 		return nil, nil
 	}
 
-	return constructSimilaritiesFromResult(&result[0])
+	similarities, err := constructSimilaritiesFromResult(&result[0])
+	if err != nil {
+		return nil, err
+	}
+	for _, s := range similarities {
+		s.Score = m.score(s.Score)
+	}
+
+	if m.cfg.OutputEmbedding {
+		if err := m.fillEmbeddings(ctx, &result[0], similarities, opts); err != nil {
+			return nil, err
+		}
+	}
+
+	return similarities, nil
+}
+
+// fillEmbeddings retrieves the embeddings of the search result by primary keys.
+func (m *Milvus) fillEmbeddings(ctx context.Context, result *client.SearchResult, similarities []*gptbot.Similarity, opts []client.SearchQueryOptionFunc) error {
+	ids, ok := result.IDs.(*entity.ColumnInt64)
+	if !ok || len(ids.Data()) == 0 {
+		return nil
+	}
+
+	var pks []string
+	for _, pk := range ids.Data() {
+		pks = append(pks, fmt.Sprint(pk))
+	}
+	expr := fmt.Sprintf(`%s in [%s]`, pkName, strings.Join(pks, ", "))
+
+	columns, err := m.client.Query(ctx, m.cfg.CollectionName, nil, expr, []string{pkName, embeddingName}, opts...)
+	if err != nil {
+		return err
+	}
+
+	var pkCol *entity.ColumnInt64
+	var embeddingCol *entity.ColumnFloatVector
+	for _, field := range columns {
+		switch field.Name() {
+		case pkName:
+			if c, ok := field.(*entity.ColumnInt64); ok {
+				pkCol = c
+			}
+		case embeddingName:
+			if c, ok := field.(*entity.ColumnFloatVector); ok {
+				embeddingCol = c
+			}
+		}
+	}
+	if pkCol == nil || embeddingCol == nil {
+		return fmt.Errorf("missing field %q or %q in query result", pkName, embeddingName)
+	}
+
+	embeddings := make(map[int64][]float32)
+	for i, pk := range pkCol.Data() {
+		embeddings[pk] = embeddingCol.Data()[i]
+	}
+
+	for i, pk := range ids.Data() {
+		if i < len(similarities) {
+			similarities[i].Embedding = xslices.NumberToFloat64(embeddings[pk])
+		}
+	}
+	return nil
+}
+
+// ListDocuments implements gptbot.Lister. Note that it scans all the chunks
+// (of the given corpus), since Milvus does not support grouping.
+func (m *Milvus) ListDocuments(ctx context.Context, corpusID string, offset, limit int) ([]*gptbot.DocumentInfo, int, error) {
+	documents, err := m.documents(ctx, corpusID)
+	if err != nil {
+		return nil, 0, err
+	}
+	return gptbot.Paginate(documents, offset, limit), len(documents), nil
+}
+
+// GetDocument implements gptbot.Lister. The embeddings are only returned
+// if OutputEmbedding is enabled.
+func (m *Milvus) GetDocument(ctx context.Context, documentID string) ([]*gptbot.Chunk, error) {
+	opts, err := queryOptions(m.searchParams(ctx))
+	if err != nil {
+		return nil, err
+	}
+
+	outputFields := []string{pkName, idName, textName, documentIDName, corpusIDName, attributesName, positionName, createdAtName}
+	if m.cfg.OutputEmbedding {
+		outputFields = append(outputFields, embeddingName)
+	}
+
+	if err := validateID("document ID", documentID); err != nil {
+		return nil, err
+	}
+
+	expr := eqExpr(documentIDName, documentID)
+	result, err := m.client.Query(ctx, m.cfg.CollectionName, nil, expr, outputFields, opts...)
+	if err != nil {
+		return nil, err
+	}
+
+	pkCol, ok := result.GetColumn(pkName).(*entity.ColumnInt64)
+	if !ok || pkCol.Len() == 0 {
+		return nil, gptbot.ErrDocumentNotFound
+	}
+
+	chunks, err := chunksFromResult(result)
+	if err != nil {
+		return nil, err
+	}
+
+	// Keep the chunks in the order of their positions. The chunks of the same
+	// position (e.g. those migrated from an older schema) are kept in the
+	// insertion order, which is the order of the auto-generated primary keys.
+	pks := pkCol.Data()
+	sort.Sort(byPosition{pks: pks, chunks: chunks})
+	return chunks, nil
+}
+
+// Stats implements gptbot.Lister. Note that it scans all the chunks.
+func (m *Milvus) Stats(ctx context.Context) (*gptbot.Stats, error) {
+	return m.CorpusStats(ctx, "")
+}
+
+// ListCorpora implements gptbot.CorpusManager. Note that it scans all the chunks.
+func (m *Milvus) ListCorpora(ctx context.Context) ([]string, error) {
+	documents, err := m.documents(ctx, "")
+	if err != nil {
+		return nil, err
+	}
+	return gptbot.CorpusIDs(documents), nil
+}
+
+// DeleteCorpus implements gptbot.CorpusManager.
+func (m *Milvus) DeleteCorpus(ctx context.Context, corpusID string) error {
+	if corpusID == "" {
+		return gptbot.ErrEmptyCorpusID
+	}
+	if err := validateID("corpus ID", corpusID); err != nil {
+		return err
+	}
+	if m.cfg.PartitionByCorpus {
+		return m.dropPartition(ctx, corpusID)
+	}
+	return m.client.Delete(ctx, m.cfg.CollectionName, "", corpusExpr(corpusID))
+}
+
+// CorpusStats implements gptbot.CorpusManager. Note that it scans all the
+// chunks of the given corpus.
+func (m *Milvus) CorpusStats(ctx context.Context, corpusID string) (*gptbot.Stats, error) {
+	documents, err := m.documents(ctx, corpusID)
+	if err != nil {
+		return nil, err
+	}
+	return gptbot.NewStats(documents), nil
+}
+
+// documents returns the documents sorted by IDs, which are restricted to
+// the given corpus if corpusID is not empty.
+// Note that the query iterator always uses the default consistency level of
+// the collection (i.e. bounded), regardless of the search parameters.
+func (m *Milvus) documents(ctx context.Context, corpusID string) ([]*gptbot.DocumentInfo, error) {
+	partitions, expr, ok, err := m.scope(ctx, corpusID)
+	if err != nil || !ok {
+		return nil, err
+	}
+
+	opt := client.NewQueryIteratorOption(m.cfg.CollectionName).
+		WithPartitions(partitions...).
+		WithExpr(expr).
+		WithOutputFields(documentIDName, corpusIDName)
+	iter, err := m.client.QueryIterator(ctx, opt)
+	if err != nil {
+		return nil, err
+	}
+
+	documentMap := make(map[string]*gptbot.DocumentInfo)
+	for {
+		result, err := iter.Next(ctx)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		documentIDCol, corpusIDCol := result.GetColumn(documentIDName), result.GetColumn(corpusIDName)
+		if documentIDCol == nil || corpusIDCol == nil {
+			return nil, fmt.Errorf("missing field %q or %q in query result", documentIDName, corpusIDName)
+		}
+		for i := 0; i < documentIDCol.Len(); i++ {
+			documentID, err := documentIDCol.GetAsString(i)
+			if err != nil {
+				return nil, err
+			}
+			doc, ok := documentMap[documentID]
+			if !ok {
+				corpusID, err := corpusIDCol.GetAsString(i)
+				if err != nil {
+					return nil, err
+				}
+				doc = &gptbot.DocumentInfo{ID: documentID, CorpusID: corpusID}
+				documentMap[documentID] = doc
+			}
+			doc.ChunkNum++
+		}
+	}
+
+	documents := maps.Values(documentMap)
+	slices.SortFunc(documents, func(a, b *gptbot.DocumentInfo) bool {
+		return a.ID < b.ID
+	})
+	return documents, nil
+}
+
+// corpusExpr returns the expression for matching the chunks of the given corpus.
+func corpusExpr(corpusID string) string {
+	return eqExpr(corpusIDName, corpusID)
+}
+
+// byPosition sorts the chunks by their positions and then primary keys.
+type byPosition struct {
+	pks    []int64
+	chunks []*gptbot.Chunk
+}
+
+func (s byPosition) Len() int { return len(s.pks) }
+func (s byPosition) Less(i, j int) bool {
+	if s.chunks[i].Position != s.chunks[j].Position {
+		return s.chunks[i].Position < s.chunks[j].Position
+	}
+	return s.pks[i] < s.pks[j]
+}
+func (s byPosition) Swap(i, j int) {
+	s.pks[i], s.pks[j] = s.pks[j], s.pks[i]
+	s.chunks[i], s.chunks[j] = s.chunks[j], s.chunks[i]
+}
+
+// Metric returns the metric used for measuring the similarity between embeddings.
+func (m *Milvus) Metric() gptbot.Metric {
+	return m.cfg.Metric
+}
+
+func (m *Milvus) metricType() entity.MetricType {
+	mt, _ := metricType(m.cfg.Metric)
+	return mt
+}
+
+// score converts the raw score returned by Milvus into the normalized one,
+// which means more similar if higher.
+func (m *Milvus) score(raw float64) float64 {
+	if m.cfg.Metric == gptbot.MetricL2 {
+		// Milvus returns the squared Euclidean distance.
+		return gptbot.L2Score(math.Sqrt(math.Max(raw, 0)))
+	}
+	return raw
+}
+
+func (m *Milvus) normalize(embedding gptbot.Embedding) gptbot.Embedding {
+	if m.cfg.Normalize {
+		return gptbot.Normalize(embedding)
+	}
+	return embedding
+}
+
+func metricType(metric gptbot.Metric) (entity.MetricType, error) {
+	switch metric {
+	case gptbot.MetricCosine:
+		return entity.COSINE, nil
+	case gptbot.MetricDotProduct:
+		return entity.IP, nil
+	case gptbot.MetricL2:
+		return entity.L2, nil
+	default:
+		return "", fmt.Errorf("unsupported metric %q", metric)
+	}
 }
 
 // Delete deletes the chunks belonging to the given documentIDs.
@@ -155,6 +734,10 @@ func (m *Milvus) Query(ctx context.Context, embedding gptbot.Embedding, corpusID
 func (m *Milvus) Delete(ctx context.Context, documentIDs ...string) error {
 	// To delete all chunks, we drop the old collection and create a new one.
 	if len(documentIDs) == 0 {
+		m.mu.Lock()
+		maps.Clear(m.partitions)
+		m.mu.Unlock()
+
 		if err := m.client.ReleaseCollection(ctx, m.cfg.CollectionName); err != nil {
 			return err
 		}
@@ -164,22 +747,26 @@ func (m *Milvus) Delete(ctx context.Context, documentIDs ...string) error {
 		return m.createAndLoadCollection(ctx, true)
 	}
 
-	expr := fmt.Sprintf(`document_id in ["%s"]`, strings.Join(documentIDs, `", "`))
-	result, err := m.client.Query(ctx, m.cfg.CollectionName, nil, expr, []string{pkName})
+	for _, documentID := range documentIDs {
+		if err := validateID("document ID", documentID); err != nil {
+			return err
+		}
+	}
+
+	opts, err := queryOptions(m.searchParams(ctx))
 	if err != nil {
 		return err
 	}
 
-	var pkCol *entity.ColumnInt64
-	for _, field := range result {
-		if field.Name() == pkName {
-			if c, ok := field.(*entity.ColumnInt64); ok {
-				pkCol = c
-			}
-		}
+	expr := inExpr(documentIDName, documentIDs)
+	result, err := m.client.Query(ctx, m.cfg.CollectionName, nil, expr, []string{pkName}, opts...)
+	if err != nil {
+		return err
 	}
 
-	if len(pkCol.Data()) == 0 {
+	// The column may be missing if no chunk matches.
+	pkCol, ok := result.GetColumn(pkName).(*entity.ColumnInt64)
+	if !ok || pkCol.Len() == 0 {
 		return nil
 	}
 	return m.client.DeleteByPks(ctx, m.cfg.CollectionName, "", pkCol)
@@ -193,13 +780,23 @@ func (m *Milvus) createAndLoadCollection(ctx context.Context, createNew bool) er
 }
 
 func (m *Milvus) createCollection(ctx context.Context, createNew bool) error {
+	if !createNew {
+		if err := m.resumeMigration(ctx); err != nil {
+			return err
+		}
+	}
+
 	has, err := m.client.HasCollection(ctx, m.cfg.CollectionName)
 	if err != nil {
 		return err
 	}
 
 	if has && !createNew {
-		return nil
+		err := m.checkSchema(ctx)
+		if errors.Is(err, ErrSchemaOutdated) && m.cfg.Migrate {
+			return m.migrate(ctx)
+		}
+		return err
 	}
 
 	if has {
@@ -208,61 +805,17 @@ func (m *Milvus) createCollection(ctx context.Context, createNew bool) error {
 
 	// The collection does not exist, so we need to create one.
 
-	schema := &entity.Schema{
-		CollectionName: m.cfg.CollectionName,
-		AutoID:         true,
-		Fields: []*entity.Field{
-			{
-				Name:       pkName,
-				DataType:   entity.FieldTypeInt64,
-				PrimaryKey: true,
-				AutoID:     true,
-			},
-			{
-				Name:     idName,
-				DataType: entity.FieldTypeVarChar,
-				TypeParams: map[string]string{
-					entity.TypeParamMaxLength: fmt.Sprintf("%d", 65535),
-				},
-			},
-			{
-				Name:     textName,
-				DataType: entity.FieldTypeVarChar,
-				TypeParams: map[string]string{
-					entity.TypeParamMaxLength: fmt.Sprintf("%d", 65535),
-				},
-			},
-			{
-				Name:     documentIDName,
-				DataType: entity.FieldTypeVarChar,
-				TypeParams: map[string]string{
-					entity.TypeParamMaxLength: fmt.Sprintf("%d", 65535),
-				},
-			},
-			{
-				Name:     corpusIDName,
-				DataType: entity.FieldTypeVarChar,
-				TypeParams: map[string]string{
-					entity.TypeParamMaxLength: fmt.Sprintf("%d", 65535),
-				},
-			},
-			{
-				Name:     embeddingName,
-				DataType: entity.FieldTypeFloatVector,
-				TypeParams: map[string]string{
-					entity.TypeParamDim: fmt.Sprintf("%d", m.cfg.Dim),
-				},
-			},
-		},
-	}
+	schema := m.schema()
 
 	// Create collection with consistency level, which serves as the default search/query consistency level.
 	if err := m.client.CreateCollection(ctx, schema, 2, client.WithConsistencyLevel(entity.ClBounded)); err != nil {
 		return err
 	}
+	return m.createIndex(ctx)
+}
 
-	// Create index "IVF_FLAT".
-	idx, err := entity.NewIndexIvfFlat(entity.L2, 128)
+func (m *Milvus) createIndex(ctx context.Context) error {
+	idx, err := m.cfg.Index.index(m.metricType())
 	if err != nil {
 		return err
 	}
@@ -274,6 +827,9 @@ func constructSimilaritiesFromResult(result *client.SearchResult) ([]*gptbot.Sim
 	var textCol *entity.ColumnVarChar
 	var documentIDCol *entity.ColumnVarChar
 	var corpusIDCol *entity.ColumnVarChar
+	var attributesCol *entity.ColumnJSONBytes
+	var positionCol *entity.ColumnInt64
+	var createdAtCol *entity.ColumnInt64
 
 	for _, field := range result.Fields {
 		switch field.Name() {
@@ -293,6 +849,18 @@ func constructSimilaritiesFromResult(result *client.SearchResult) ([]*gptbot.Sim
 			if c, ok := field.(*entity.ColumnVarChar); ok {
 				corpusIDCol = c
 			}
+		case attributesName:
+			if c, ok := field.(*entity.ColumnJSONBytes); ok {
+				attributesCol = c
+			}
+		case positionName:
+			if c, ok := field.(*entity.ColumnInt64); ok {
+				positionCol = c
+			}
+		case createdAtName:
+			if c, ok := field.(*entity.ColumnInt64); ok {
+				createdAtCol = c
+			}
 		}
 	}
 
@@ -316,14 +884,40 @@ func constructSimilaritiesFromResult(result *client.SearchResult) ([]*gptbot.Sim
 			return nil, err
 		}
 
+		var attributes map[string]any
+		if attributesCol != nil {
+			data, err := attributesCol.ValueByIdx(i)
+			if err != nil {
+				return nil, err
+			}
+			if attributes, err = unmarshalAttributes(data); err != nil {
+				return nil, err
+			}
+		}
+
+		var position, createdAt int64
+		if positionCol != nil {
+			if position, err = positionCol.ValueByIdx(i); err != nil {
+				return nil, err
+			}
+		}
+		if createdAtCol != nil {
+			if createdAt, err = createdAtCol.ValueByIdx(i); err != nil {
+				return nil, err
+			}
+		}
+
 		similarities = append(similarities, &gptbot.Similarity{
 			Chunk: &gptbot.Chunk{
 				ID:         id,
 				Text:       text,
 				DocumentID: documentID,
 				Metadata: gptbot.Metadata{
-					CorpusID: corpusID,
+					CorpusID:   corpusID,
+					Attributes: attributes,
 				},
+				Position:  int(position),
+				CreatedAt: createdAt,
 			},
 			Score: float64(result.Scores[i]),
 		})
@@ -331,3 +925,77 @@ func constructSimilaritiesFromResult(result *client.SearchResult) ([]*gptbot.Sim
 
 	return similarities, nil
 }
+
+// chunksFromResult constructs the chunks from the query result. The fields
+// other than the IDs and the text are optional.
+func chunksFromResult(result client.ResultSet) ([]*gptbot.Chunk, error) {
+	idCol, textCol := result.GetColumn(idName), result.GetColumn(textName)
+	documentIDCol, corpusIDCol := result.GetColumn(documentIDName), result.GetColumn(corpusIDName)
+	if idCol == nil || textCol == nil || documentIDCol == nil || corpusIDCol == nil {
+		return nil, fmt.Errorf("missing fields in query result")
+	}
+
+	var err error
+	chunks := make([]*gptbot.Chunk, idCol.Len())
+	for i := range chunks {
+		chunk := &gptbot.Chunk{}
+		if chunk.ID, err = idCol.GetAsString(i); err != nil {
+			return nil, err
+		}
+		if chunk.Text, err = textCol.GetAsString(i); err != nil {
+			return nil, err
+		}
+		if chunk.DocumentID, err = documentIDCol.GetAsString(i); err != nil {
+			return nil, err
+		}
+		if chunk.Metadata.CorpusID, err = corpusIDCol.GetAsString(i); err != nil {
+			return nil, err
+		}
+		if c, ok := result.GetColumn(attributesName).(*entity.ColumnJSONBytes); ok {
+			data, err := c.ValueByIdx(i)
+			if err != nil {
+				return nil, err
+			}
+			if chunk.Metadata.Attributes, err = unmarshalAttributes(data); err != nil {
+				return nil, err
+			}
+		}
+		if c, ok := result.GetColumn(positionName).(*entity.ColumnInt64); ok {
+			position, err := c.ValueByIdx(i)
+			if err != nil {
+				return nil, err
+			}
+			chunk.Position = int(position)
+		}
+		if c, ok := result.GetColumn(createdAtName).(*entity.ColumnInt64); ok {
+			if chunk.CreatedAt, err = c.ValueByIdx(i); err != nil {
+				return nil, err
+			}
+		}
+		if c, ok := result.GetColumn(embeddingName).(*entity.ColumnFloatVector); ok {
+			chunk.Embedding = xslices.NumberToFloat64(c.Data()[i])
+		}
+		chunks[i] = chunk
+	}
+	return chunks, nil
+}
+
+// marshalAttributes encodes the attributes into a JSON object. A nil map is
+// encoded as an empty object, since Milvus does not accept null JSON values.
+func marshalAttributes(attributes map[string]any) ([]byte, error) {
+	if attributes == nil {
+		return []byte("{}"), nil
+	}
+	return json.Marshal(attributes)
+}
+
+func unmarshalAttributes(data []byte) (map[string]any, error) {
+	var attributes map[string]any
+	if err := json.Unmarshal(data, &attributes); err != nil {
+		return nil, err
+	}
+	if len(attributes) == 0 {
+		return nil, nil
+	}
+	return attributes, nil
+}
@@ -4,6 +4,7 @@ import (
 	"context"
 	"os"
 	"testing"
+	"time"
 
 	"github.com/go-aie/gptbot"
 	"github.com/go-aie/gptbot/milvus"
@@ -14,8 +15,11 @@ func TestMilvus_Query(t *testing.T) {
 	apiKey := os.Getenv("OPENAI_API_KEY")
 	encoder := gptbot.NewOpenAIEncoder(apiKey, "")
 
+	// Fail fast if the server is unavailable.
 	store, err := milvus.NewMilvus(&milvus.Config{
 		CollectionName: "olympics_knowledge",
+		DialTimeout:    3 * time.Second,
+		Retry:          &milvus.RetryConfig{MaxRetries: -1},
 	})
 	if err != nil {
 		t.Fatalf("err: %v\n", err)
@@ -25,6 +29,9 @@ func TestMilvus_Query(t *testing.T) {
 		t.Fatalf("err: %v\n", err)
 	}
 
+	// Make sure that the chunks just loaded are visible.
+	ctx := milvus.WithSearchParams(context.Background(), &milvus.SearchParams{ConsistencyLevel: milvus.ConsistencyStrong})
+
 	tests := []struct {
 		in   string
 		want []*gptbot.Similarity
@@ -68,7 +75,7 @@ func TestMilvus_Query(t *testing.T) {
 			t.Errorf("err: %v\n", err)
 		}
 
-		got, err := store.Query(context.Background(), embedding, "olympic:2020", 3)
+		got, err := store.Query(ctx, embedding, "olympic:2020", 3, nil)
 		if err != nil {
 			t.Errorf("err: %v\n", err)
 		}
@@ -0,0 +1,117 @@
+package milvus
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+)
+
+// partitionName returns the name of the partition for the given corpus, or
+// "" (i.e. the default partition) if corpusID is empty.
+//
+// Since partition names can only contain letters, digits and underscores,
+// the name is derived from the hash of the corpus ID.
+func partitionName(corpusID string) string {
+	if corpusID == "" {
+		return ""
+	}
+	sum := sha256.Sum256([]byte(corpusID))
+	return "corpus_" + hex.EncodeToString(sum[:16])
+}
+
+// hasPartition reports whether the partition for the given corpus exists.
+func (m *Milvus) hasPartition(ctx context.Context, corpusID string) (bool, error) {
+	name := partitionName(corpusID)
+	if name == "" {
+		return true, nil
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.partitions[name] {
+		return true, nil
+	}
+	has, err := m.client.HasPartition(ctx, m.cfg.CollectionName, name)
+	if err != nil {
+		return false, err
+	}
+	if has {
+		m.partitions[name] = true
+	}
+	return has, nil
+}
+
+// createPartition creates the partition for the given corpus if it does not exist.
+func (m *Milvus) createPartition(ctx context.Context, corpusID string) error {
+	has, err := m.hasPartition(ctx, corpusID)
+	if err != nil || has {
+		return err
+	}
+
+	name := partitionName(corpusID)
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.partitions[name] {
+		return nil
+	}
+	if err := m.client.CreatePartition(ctx, m.cfg.CollectionName, name); err != nil {
+		// The partition may have been created concurrently.
+		if has, _ := m.client.HasPartition(ctx, m.cfg.CollectionName, name); !has {
+			return err
+		}
+	}
+	// Make sure that the new partition is searchable.
+	if err := m.client.LoadPartitions(ctx, m.cfg.CollectionName, []string{name}, false); err != nil {
+		return err
+	}
+	m.partitions[name] = true
+	return nil
+}
+
+// dropPartition drops the partition for the given corpus if it exists.
+func (m *Milvus) dropPartition(ctx context.Context, corpusID string) error {
+	has, err := m.hasPartition(ctx, corpusID)
+	if err != nil || !has {
+		return err
+	}
+
+	name := partitionName(corpusID)
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	// A loaded partition must be released before being dropped.
+	if err := m.client.ReleasePartitions(ctx, m.cfg.CollectionName, []string{name}); err != nil {
+		return err
+	}
+	if err := m.client.DropPartition(ctx, m.cfg.CollectionName, name); err != nil {
+		return err
+	}
+	delete(m.partitions, name)
+	return nil
+}
+
+// scope returns the partitions and the expression for restricting the search
+// (or query) to the given corpus. If the corpus is known to be absent, ok will
+// be false.
+func (m *Milvus) scope(ctx context.Context, corpusID string) (partitions []string, expr string, ok bool, err error) {
+	if err := validateID("corpus ID", corpusID); err != nil {
+		return nil, "", false, err
+	}
+
+	switch {
+	case corpusID == "":
+		return nil, "", true, nil
+	case m.cfg.PartitionByCorpus:
+		has, err := m.hasPartition(ctx, corpusID)
+		if err != nil || !has {
+			return nil, "", false, err
+		}
+		return []string{partitionName(corpusID)}, "", true, nil
+	default:
+		return nil, corpusExpr(corpusID), true, nil
+	}
+}
@@ -0,0 +1,111 @@
+package milvus
+
+import (
+	"context"
+	"regexp"
+	"testing"
+
+	"github.com/go-aie/gptbot"
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestPartitionName(t *testing.T) {
+	valid := regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]{0,254}$`)
+	for _, corpusID := range []string{"olympic:2020", "中文", `"; drop`, string(make([]byte, 1024))} {
+		name := partitionName(corpusID)
+		if !valid.MatchString(name) {
+			t.Errorf("invalid partition name %q for corpus %q", name, corpusID)
+		}
+	}
+
+	if got := partitionName(""); got != "" {
+		t.Errorf("Got (%q) != Want (%q)", got, "")
+	}
+	if partitionName("a") == partitionName("b") {
+		t.Errorf("want different partition names for different corpora")
+	}
+}
+
+func TestMilvus_PartitionByCorpus(t *testing.T) {
+	ctx := context.Background()
+	m, c := newFakeMilvus(&Config{CollectionName: "test", Dim: 2, PartitionByCorpus: true})
+
+	err := m.Insert(ctx, map[string][]*gptbot.Chunk{
+		"doc_1": {
+			{ID: "1", DocumentID: "doc_1", Metadata: gptbot.Metadata{CorpusID: "olympic:2020"}, Embedding: gptbot.Embedding{1, 0}},
+			{ID: "2", DocumentID: "doc_1", Metadata: gptbot.Metadata{CorpusID: "olympic:2020"}, Embedding: gptbot.Embedding{0, 1}},
+		},
+		"doc_2": {
+			{ID: "3", DocumentID: "doc_2", Embedding: gptbot.Embedding{1, 1}},
+		},
+	})
+	if err != nil {
+		t.Fatalf("err: %v\n", err)
+	}
+
+	wantInserted := map[string]int{partitionName("olympic:2020"): 2, "": 1}
+	if !cmp.Equal(c.inserted, wantInserted) {
+		diff := cmp.Diff(c.inserted, wantInserted)
+		t.Errorf("Want - Got: %s", diff)
+	}
+
+	// Search only the partition of the corpus, or nothing if it does not exist.
+	for _, corpusID := range []string{"olympic:2020", "", "unknown"} {
+		if _, err := m.Query(ctx, gptbot.Embedding{1, 0}, corpusID, 1, nil); err != nil {
+			t.Fatalf("err: %v\n", err)
+		}
+	}
+	wantSearched := []fakeSearch{
+		{Partitions: []string{partitionName("olympic:2020")}},
+		{},
+	}
+	if !cmp.Equal(c.searched, wantSearched) {
+		diff := cmp.Diff(c.searched, wantSearched)
+		t.Errorf("Want - Got: %s", diff)
+	}
+
+	// Deleting the corpus drops the partition.
+	if err := m.DeleteCorpus(ctx, "olympic:2020"); err != nil {
+		t.Fatalf("err: %v\n", err)
+	}
+	if has, _ := m.hasPartition(ctx, "olympic:2020"); has || c.partitions[partitionName("olympic:2020")] {
+		t.Errorf("want the partition to be dropped")
+	}
+	if len(c.deleted) != 0 {
+		t.Errorf("unexpected deletion by expressions: %v", c.deleted)
+	}
+}
+
+func TestMilvus_SingleCollection(t *testing.T) {
+	ctx := context.Background()
+	m, c := newFakeMilvus(&Config{CollectionName: "test", Dim: 2})
+
+	err := m.Insert(ctx, map[string][]*gptbot.Chunk{
+		"doc_1": {{ID: "1", DocumentID: "doc_1", Metadata: gptbot.Metadata{CorpusID: "c1"}, Embedding: gptbot.Embedding{1, 0}}},
+	})
+	if err != nil {
+		t.Fatalf("err: %v\n", err)
+	}
+	if _, err := m.Query(ctx, gptbot.Embedding{1, 0}, "c1", 1, nil); err != nil {
+		t.Fatalf("err: %v\n", err)
+	}
+	if err := m.DeleteCorpus(ctx, "c1"); err != nil {
+		t.Fatalf("err: %v\n", err)
+	}
+
+	if len(c.partitions) != 0 {
+		t.Errorf("unexpected partitions: %v", c.partitions)
+	}
+	if want := map[string]int{"": 1}; !cmp.Equal(c.inserted, want) {
+		diff := cmp.Diff(c.inserted, want)
+		t.Errorf("Want - Got: %s", diff)
+	}
+	if want := []fakeSearch{{Expr: `corpus_id == "c1"`}}; !cmp.Equal(c.searched, want) {
+		diff := cmp.Diff(c.searched, want)
+		t.Errorf("Want - Got: %s", diff)
+	}
+	if want := []string{`corpus_id == "c1"`}; !cmp.Equal(c.deleted, want) {
+		diff := cmp.Diff(c.deleted, want)
+		t.Errorf("Want - Got: %s", diff)
+	}
+}
@@ -0,0 +1,291 @@
+package milvus
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/go-aie/gptbot"
+	"github.com/milvus-io/milvus-sdk-go/v2/client"
+	"github.com/milvus-io/milvus-sdk-go/v2/entity"
+)
+
+// schemaVersion is the version of the current collection schema, which is
+// recorded in the collection description. The collections created before
+// versioning are of version 0.
+//
+// Version 1 added the fields attributes, position and created_at.
+const schemaVersion = 1
+
+const schemaDescriptionPrefix = "gptbot schema v"
+
+var (
+	// ErrSchemaMismatch means that the existing collection does not match
+	// the configuration (e.g. the embedding dimension or the metric), which
+	// can not be fixed by migration.
+	ErrSchemaMismatch = errors.New("schema mismatch")
+
+	// ErrSchemaOutdated means that the existing collection is of an older
+	// schema version, which can be fixed by migration (see Config.Migrate).
+	ErrSchemaOutdated = errors.New("schema outdated")
+)
+
+func schemaDescription(version int) string {
+	return schemaDescriptionPrefix + strconv.Itoa(version)
+}
+
+// schemaVersionOf returns the schema version recorded in the given collection
+// description, or 0 if not recorded.
+func schemaVersionOf(description string) int {
+	if !strings.HasPrefix(description, schemaDescriptionPrefix) {
+		return 0
+	}
+	version, err := strconv.Atoi(strings.TrimPrefix(description, schemaDescriptionPrefix))
+	if err != nil {
+		return 0
+	}
+	return version
+}
+
+// schema returns the current schema of the collection.
+func (m *Milvus) schema() *entity.Schema {
+	return &entity.Schema{
+		CollectionName: m.cfg.CollectionName,
+		Description:    schemaDescription(schemaVersion),
+		AutoID:         true,
+		Fields: []*entity.Field{
+			{
+				Name:       pkName,
+				DataType:   entity.FieldTypeInt64,
+				PrimaryKey: true,
+				AutoID:     true,
+			},
+			{
+				Name:     idName,
+				DataType: entity.FieldTypeVarChar,
+				TypeParams: map[string]string{
+					entity.TypeParamMaxLength: fmt.Sprintf("%d", maxVarCharLength),
+				},
+			},
+			{
+				Name:     textName,
+				DataType: entity.FieldTypeVarChar,
+				TypeParams: map[string]string{
+					entity.TypeParamMaxLength: fmt.Sprintf("%d", maxVarCharLength),
+				},
+			},
+			{
+				Name:     documentIDName,
+				DataType: entity.FieldTypeVarChar,
+				TypeParams: map[string]string{
+					entity.TypeParamMaxLength: fmt.Sprintf("%d", maxVarCharLength),
+				},
+			},
+			{
+				Name:     corpusIDName,
+				DataType: entity.FieldTypeVarChar,
+				TypeParams: map[string]string{
+					entity.TypeParamMaxLength: fmt.Sprintf("%d", maxVarCharLength),
+				},
+			},
+			{
+				Name:     attributesName,
+				DataType: entity.FieldTypeJSON,
+			},
+			{
+				Name:     positionName,
+				DataType: entity.FieldTypeInt64,
+			},
+			{
+				Name:     createdAtName,
+				DataType: entity.FieldTypeInt64,
+			},
+			{
+				Name:     embeddingName,
+				DataType: entity.FieldTypeFloatVector,
+				TypeParams: map[string]string{
+					entity.TypeParamDim: fmt.Sprintf("%d", m.cfg.Dim),
+				},
+			},
+		},
+	}
+
+}
+
+// checkSchema checks whether the existing collection matches the current
+// schema and the configuration.
+func (m *Milvus) checkSchema(ctx context.Context) error {
+	coll, err := m.client.DescribeCollection(ctx, m.cfg.CollectionName)
+	if err != nil {
+		return err
+	}
+
+	version := schemaVersionOf(coll.Schema.Description)
+	if version > schemaVersion {
+		return fmt.Errorf("%w: collection %q has schema version %d, which is newer than %d", ErrSchemaMismatch, m.cfg.CollectionName, version, schemaVersion)
+	}
+
+	fields := make(map[string]*entity.Field)
+	for _, f := range coll.Schema.Fields {
+		fields[f.Name] = f
+	}
+	var missing []string
+	for _, want := range m.schema().Fields {
+		f, ok := fields[want.Name]
+		if !ok {
+			missing = append(missing, want.Name)
+			continue
+		}
+		if f.DataType != want.DataType {
+			return fmt.Errorf("%w: field %q of collection %q has type %s, want %s", ErrSchemaMismatch, f.Name, m.cfg.CollectionName, f.DataType.Name(), want.DataType.Name())
+		}
+		if f.Name == embeddingName && f.TypeParams[entity.TypeParamDim] != want.TypeParams[entity.TypeParamDim] {
+			return fmt.Errorf("%w: collection %q has embedding dimension %s, but Dim is %d", ErrSchemaMismatch, m.cfg.CollectionName, f.TypeParams[entity.TypeParamDim], m.cfg.Dim)
+		}
+	}
+
+	indexes, err := m.client.DescribeIndex(ctx, m.cfg.CollectionName, embeddingName)
+	if err != nil && !isIndexNotFound(err) {
+		return err
+	}
+	for _, idx := range indexes {
+		mt := entity.MetricType(idx.Params()["metric_type"])
+		if mt != "" && mt != m.metricType() {
+			return fmt.Errorf("%w: collection %q is indexed with metric %s, but Metric is %q", ErrSchemaMismatch, m.cfg.CollectionName, mt, m.cfg.Metric)
+		}
+	}
+	if len(indexes) == 0 {
+		// The index is missing (e.g. the creation of the collection was
+		// interrupted), which is required for loading the collection.
+		if err := m.createIndex(ctx); err != nil {
+			return err
+		}
+	}
+
+	if version < schemaVersion || len(missing) > 0 {
+		return fmt.Errorf("%w: collection %q has schema version %d (missing fields %v), want version %d", ErrSchemaOutdated, m.cfg.CollectionName, version, missing, schemaVersion)
+	}
+	return nil
+}
+
+// isIndexNotFound reports whether err means that the index does not exist.
+// The error code is not kept by the client, so the message is checked instead.
+func isIndexNotFound(err error) bool {
+	msg := err.Error()
+	return strings.Contains(msg, "index not found") || strings.Contains(msg, "index not exist")
+}
+
+// migrationNames returns the names of the collections used by migration:
+// the target, into which the chunks are copied, and the backup, to which
+// the old collection is renamed before being replaced.
+func (m *Milvus) migrationNames() (target, backup string) {
+	return fmt.Sprintf("%s_v%d", m.cfg.CollectionName, schemaVersion), m.cfg.CollectionName + "_old"
+}
+
+// resumeMigration finishes the migration interrupted after the old collection
+// was renamed aside (see migrate), if any. The migration interrupted before
+// that will be restarted by migrate.
+func (m *Milvus) resumeMigration(ctx context.Context) error {
+	target, backup := m.migrationNames()
+	hasBackup, err := m.client.HasCollection(ctx, backup)
+	if err != nil || !hasBackup {
+		return err
+	}
+
+	has, err := m.client.HasCollection(ctx, m.cfg.CollectionName)
+	if err != nil {
+		return err
+	}
+	if !has {
+		hasTarget, err := m.client.HasCollection(ctx, target)
+		if err != nil {
+			return err
+		}
+		if !hasTarget {
+			// The target is gone, so restore the old collection instead.
+			return m.client.RenameCollection(ctx, backup, m.cfg.CollectionName)
+		}
+		// All the chunks were copied before the old collection was renamed.
+		if err := m.client.RenameCollection(ctx, target, m.cfg.CollectionName); err != nil {
+			return err
+		}
+	}
+	return m.client.DropCollection(ctx, backup)
+}
+
+// migrate migrates the existing collection to the current schema version,
+// by copying all the chunks into a new collection, which then replaces the
+// old one.
+func (m *Milvus) migrate(ctx context.Context) error {
+	coll, err := m.client.DescribeCollection(ctx, m.cfg.CollectionName)
+	if err != nil {
+		return err
+	}
+	var outputFields []string
+	for _, f := range coll.Schema.Fields {
+		if f.Name != pkName {
+			outputFields = append(outputFields, f.Name)
+		}
+	}
+
+	// Create the new collection, which shares the client with m. Any leftover
+	// of an interrupted migration is dropped, since the copying starts over.
+	targetName, backup := m.migrationNames()
+	cfg := *m.cfg
+	cfg.CollectionName = targetName
+	target := &Milvus{
+		client:     m.client,
+		cfg:        &cfg,
+		partitions: make(map[string]bool),
+	}
+	if err := target.createAndLoadCollection(ctx, true); err != nil {
+		return err
+	}
+
+	// The old collection must be loaded before being queried.
+	if err := m.client.LoadCollection(ctx, m.cfg.CollectionName, false); err != nil {
+		return err
+	}
+	opt := client.NewQueryIteratorOption(m.cfg.CollectionName).WithOutputFields(outputFields...)
+	iter, err := m.client.QueryIterator(ctx, opt)
+	if err != nil {
+		return err
+	}
+	for {
+		result, err := iter.Next(ctx)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+
+		chunks, err := chunksFromResult(result)
+		if err != nil {
+			return err
+		}
+		chunkMap := make(map[string][]*gptbot.Chunk)
+		for _, chunk := range chunks {
+			chunkMap[chunk.DocumentID] = append(chunkMap[chunk.DocumentID], chunk)
+		}
+		if err := target.insertChunks(ctx, chunkMap); err != nil {
+			return err
+		}
+	}
+
+	if err := m.client.ReleaseCollection(ctx, m.cfg.CollectionName); err != nil {
+		return err
+	}
+	// Rename the old collection aside instead of dropping it, so that there
+	// is always a complete copy of the chunks (see resumeMigration).
+	if err := m.client.RenameCollection(ctx, m.cfg.CollectionName, backup); err != nil {
+		return err
+	}
+	if err := m.client.RenameCollection(ctx, targetName, m.cfg.CollectionName); err != nil {
+		return err
+	}
+	return m.client.DropCollection(ctx, backup)
+}
@@ -0,0 +1,224 @@
+package milvus
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"testing"
+
+	"github.com/go-aie/gptbot"
+	"github.com/google/go-cmp/cmp"
+	"github.com/milvus-io/milvus-sdk-go/v2/client"
+	"github.com/milvus-io/milvus-sdk-go/v2/entity"
+)
+
+func TestMilvus_CheckSchema(t *testing.T) {
+	// existing returns the collection and the index created with the given config.
+	existing := func(cfg *Config) (*entity.Collection, entity.Index) {
+		m, _ := newFakeMilvus(cfg)
+		idx, err := m.cfg.Index.index(m.metricType())
+		if err != nil {
+			t.Fatalf("err: %v\n", err)
+		}
+		return &entity.Collection{Name: cfg.CollectionName, Schema: m.schema()}, idx
+	}
+
+	current, currentIndex := existing(&Config{CollectionName: "test", Dim: 2})
+	otherDim, _ := existing(&Config{CollectionName: "test", Dim: 3})
+	_, otherMetricIndex := existing(&Config{CollectionName: "test", Dim: 2, Metric: gptbot.MetricDotProduct})
+
+	legacy, _ := existing(&Config{CollectionName: "test", Dim: 2})
+	legacy.Schema.Description = ""
+	legacy.Schema.Fields = legacy.Schema.Fields[:len(legacy.Schema.Fields)-3]
+	legacy.Schema.Fields = append(legacy.Schema.Fields, current.Schema.Fields[len(current.Schema.Fields)-1])
+
+	newer, _ := existing(&Config{CollectionName: "test", Dim: 2})
+	newer.Schema.Description = schemaDescription(schemaVersion + 1)
+
+	tests := []struct {
+		name       string
+		collection *entity.Collection
+		index      entity.Index
+		wantErr    error
+	}{
+		{
+			name:       "current",
+			collection: current,
+			index:      currentIndex,
+		},
+		{
+			name:       "dimension mismatch",
+			collection: otherDim,
+			index:      currentIndex,
+			wantErr:    ErrSchemaMismatch,
+		},
+		{
+			name:       "metric mismatch",
+			collection: current,
+			index:      otherMetricIndex,
+			wantErr:    ErrSchemaMismatch,
+		},
+		{
+			name:       "legacy",
+			collection: legacy,
+			index:      currentIndex,
+			wantErr:    ErrSchemaOutdated,
+		},
+		{
+			name:       "newer",
+			collection: newer,
+			index:      currentIndex,
+			wantErr:    ErrSchemaMismatch,
+		},
+		{
+			// The index will be created.
+			name:       "missing index",
+			collection: current,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m, c := newFakeMilvus(&Config{CollectionName: "test", Dim: 2})
+			c.collection, c.index = tt.collection, tt.index
+
+			err := m.createCollection(context.Background(), false)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("Got err (%v), Want err (%v)", err, tt.wantErr)
+			}
+			if c.index == nil {
+				t.Errorf("want the collection to be indexed")
+			}
+		})
+	}
+}
+
+func TestMilvus_ResumeMigration(t *testing.T) {
+	tests := []struct {
+		name string
+		in   []string
+		want []string
+	}{
+		{
+			name: "no migration",
+			in:   []string{"test"},
+			want: []string{"test"},
+		},
+		{
+			// The migration will be restarted by copying into a new target.
+			name: "interrupted while copying",
+			in:   []string{"test", "test_v1"},
+			want: []string{"test", "test_v1"},
+		},
+		{
+			name: "interrupted before replacing",
+			in:   []string{"test_old", "test_v1"},
+			want: []string{"test"},
+		},
+		{
+			name: "interrupted before dropping",
+			in:   []string{"test", "test_old"},
+			want: []string{"test"},
+		},
+		{
+			name: "target gone",
+			in:   []string{"test_old"},
+			want: []string{"test"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m, c := newFakeMilvus(&Config{CollectionName: "test", Dim: 2})
+			c.names = make(map[string]bool)
+			for _, name := range tt.in {
+				c.names[name] = true
+			}
+
+			if err := m.resumeMigration(context.Background()); err != nil {
+				t.Fatalf("err: %v\n", err)
+			}
+
+			var got []string
+			for name := range c.names {
+				got = append(got, name)
+			}
+			sort.Strings(got)
+			if !cmp.Equal(got, tt.want) {
+				diff := cmp.Diff(got, tt.want)
+				t.Errorf("Want - Got: %s", diff)
+			}
+		})
+	}
+}
+
+func TestSchemaVersionOf(t *testing.T) {
+	for in, want := range map[string]int{
+		"":                            0,
+		"my collection":               0,
+		schemaDescription(1):          1,
+		schemaDescriptionPrefix + "x": 0,
+	} {
+		if got := schemaVersionOf(in); got != want {
+			t.Errorf("Got (%d) != Want (%d) for %q", got, want, in)
+		}
+	}
+}
+
+func TestMilvus_MetadataRoundTrip(t *testing.T) {
+	m, c := newFakeMilvus(&Config{CollectionName: "test", Dim: 2})
+
+	chunks := []*gptbot.Chunk{
+		{
+			ID:         "doc_1_0",
+			Text:       "foo",
+			DocumentID: "doc_1",
+			Metadata: gptbot.Metadata{
+				CorpusID:   "c1",
+				Attributes: map[string]any{"product": "gptbot", "version": 2.0},
+			},
+			Position:  0,
+			CreatedAt: 1700000000,
+		},
+		{
+			ID:         "doc_1_1",
+			Text:       "bar",
+			DocumentID: "doc_1",
+			Metadata:   gptbot.Metadata{CorpusID: "c1"},
+			Position:   1,
+			CreatedAt:  1700000001,
+		},
+	}
+	for _, chunk := range chunks {
+		chunk.Embedding = gptbot.Embedding{1, 0}
+	}
+
+	if err := m.Insert(context.Background(), map[string][]*gptbot.Chunk{"doc_1": chunks}); err != nil {
+		t.Fatalf("err: %v\n", err)
+	}
+
+	got, err := chunksFromResult(client.ResultSet(c.columns))
+	if err != nil {
+		t.Fatalf("err: %v\n", err)
+	}
+	if !cmp.Equal(got, chunks) {
+		diff := cmp.Diff(got, chunks)
+		t.Errorf("Want - Got: %s", diff)
+	}
+
+	similarities, err := constructSimilaritiesFromResult(&client.SearchResult{
+		ResultCount: len(chunks),
+		Fields:      client.ResultSet(c.columns),
+		Scores:      []float32{1, 0.5},
+	})
+	if err != nil {
+		t.Fatalf("err: %v\n", err)
+	}
+	for i, s := range similarities {
+		// Embeddings are not returned by searches.
+		want := *chunks[i]
+		want.Embedding = nil
+		if !cmp.Equal(s.Chunk, &want) {
+			diff := cmp.Diff(s.Chunk, &want)
+			t.Errorf("Want - Got: %s", diff)
+		}
+	}
+}
@@ -0,0 +1,23 @@
+//go:build !unix
+
+package gptbot
+
+import (
+	"os"
+)
+
+// mmapFile reads the whole file into memory, since memory-mapping is not
+// supported on this platform.
+func mmapFile(filename string) (data []byte, unmap func() error, err error) {
+	data, err = os.ReadFile(filename)
+	if err != nil {
+		return nil, nil, err
+	}
+	return data, func() error { return nil }, nil
+}
+
+// mmapFileRW allocates the memory from the heap, since memory-mapping is not
+// supported on this platform.
+func mmapFileRW(f *os.File, size int) (data []byte, unmap func() error, err error) {
+	return make([]byte, size), func() error { return nil }, nil
+}
@@ -0,0 +1,81 @@
+package gptbot
+
+import (
+	"math"
+
+	"gonum.org/v1/gonum/floats"
+)
+
+// mmr selects at most k similarities from the candidates by using Maximal
+// Marginal Relevance (MMR), which balances the relevance to the query and the
+// diversity among the selected ones. The similarities are returned in the
+// order of selection.
+//
+// The lambda, between 0 and 1, specifies the degree of relevance. Higher values
+// favor relevance while lower values favor diversity.
+//
+// Note that all candidates must have embeddings.
+//
+// See https://www.cs.cmu.edu/~jgc/publication/The_Use_MMR_Diversity_Based_LTMIR_1998.pdf.
+func mmr(query Embedding, candidates []*Similarity, k int, lambda float64) []*Similarity {
+	if k >= len(candidates) {
+		k = len(candidates)
+	}
+
+	relevance := make([]float64, len(candidates))
+	for i, c := range candidates {
+		relevance[i] = cosine(query, c.Embedding)
+	}
+
+	// redundancy[i] is the maximum similarity between candidates[i]
+	// and the selected ones.
+	redundancy := make([]float64, len(candidates))
+	for i := range redundancy {
+		redundancy[i] = math.Inf(-1)
+	}
+
+	selected := make([]bool, len(candidates))
+	var result []*Similarity
+
+	for len(result) < k {
+		best, bestScore := -1, math.Inf(-1)
+		for i := range candidates {
+			if selected[i] {
+				continue
+			}
+
+			score := lambda * relevance[i]
+			if len(result) > 0 {
+				score -= (1 - lambda) * redundancy[i]
+			}
+			if score > bestScore {
+				best, bestScore = i, score
+			}
+		}
+
+		selected[best] = true
+		result = append(result, candidates[best])
+
+		// Update the redundancy of the remaining candidates.
+		for i, c := range candidates {
+			if !selected[i] {
+				redundancy[i] = math.Max(redundancy[i], cosine(c.Embedding, candidates[best].Embedding))
+			}
+		}
+	}
+
+	return result
+}
+
+// cosine returns the cosine similarity between the two embeddings.
+func cosine(a, b Embedding) float64 {
+	if len(a) != len(b) || len(a) == 0 {
+		return 0
+	}
+
+	norm := floats.Norm(a, 2) * floats.Norm(b, 2)
+	if norm == 0 {
+		return 0
+	}
+	return floats.Dot(a, b) / norm
+}
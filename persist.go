@@ -0,0 +1,501 @@
+package gptbot
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash"
+	"hash/crc32"
+	"io"
+	"math"
+	"os"
+	"path/filepath"
+	"unsafe"
+
+	"github.com/go-aie/xslices"
+)
+
+// The binary format of LocalVectorStore is as follows (in little endian):
+//
+//	+--------------------------------------------------------------+
+//	| Header (32 bytes)                                            |
+//	|   magic "GPTBOTVS" (8) | version (2) | flags (2) | dim (4)   |
+//	|   count (8) | reserved (8)                                   |
+//	+--------------------------------------------------------------+
+//	| Body (gzip compressed if binaryFlagGzip is set)              |
+//	|   vectors: count*dim float32 or float64 values               |
+//	|   metadata: count records of (length (4) | chunk JSON)       |
+//	|   checksum: CRC-32C of the header and the uncompressed body  |
+//	+--------------------------------------------------------------+
+//
+// The vectors come first so that they are 8-byte aligned in uncompressed
+// files, which makes it possible to memory-map them directly.
+const (
+	binaryMagic      = "GPTBOTVS"
+	binaryVersion    = 1
+	binaryHeaderSize = 32
+
+	binaryFlagFloat32 uint16 = 1 << 0
+	binaryFlagGzip    uint16 = 1 << 1
+
+	// maxMetadataSize is the maximum size of the metadata of a single chunk,
+	// which guards against corrupted files.
+	maxMetadataSize = 64 << 20
+)
+
+var (
+	castagnoli = crc32.MakeTable(crc32.Castagnoli)
+
+	nativeLittleEndian = func() bool {
+		x := uint16(1)
+		return *(*byte)(unsafe.Pointer(&x)) == 1
+	}()
+
+	ErrChecksumMismatch = errors.New("checksum mismatch")
+)
+
+type BinaryOptions struct {
+	// Float32 specifies whether to store embeddings as float32 instead of
+	// float64, which halves the size at the cost of precision.
+	Float32 bool
+
+	// Compress specifies whether to compress the data by using gzip.
+	// Note that compressed files can not be memory-mapped.
+	Compress bool
+}
+
+type binaryHeader struct {
+	Version uint16
+	Flags   uint16
+	Dim     uint32
+	Count   uint64
+}
+
+func (h *binaryHeader) elemSize() int {
+	if h.Flags&binaryFlagFloat32 != 0 {
+		return 4
+	}
+	return 8
+}
+
+// vectorsSize returns the total size of the vectors in bytes.
+func (h *binaryHeader) vectorsSize() (int, error) {
+	size := uint64(h.Dim) * uint64(h.elemSize())
+	if h.Dim != 0 && h.Count > math.MaxInt/size {
+		return 0, fmt.Errorf("too many vectors: %d*%d", h.Count, h.Dim)
+	}
+	return int(h.Count * size), nil
+}
+
+func (h *binaryHeader) MarshalBinary() []byte {
+	b := make([]byte, binaryHeaderSize)
+	copy(b, binaryMagic)
+	binary.LittleEndian.PutUint16(b[8:], h.Version)
+	binary.LittleEndian.PutUint16(b[10:], h.Flags)
+	binary.LittleEndian.PutUint32(b[12:], h.Dim)
+	binary.LittleEndian.PutUint64(b[16:], h.Count)
+	return b
+}
+
+func (h *binaryHeader) UnmarshalBinary(b []byte) error {
+	if len(b) < binaryHeaderSize || string(b[:8]) != binaryMagic {
+		return fmt.Errorf("not a binary vector store file")
+	}
+	h.Version = binary.LittleEndian.Uint16(b[8:])
+	h.Flags = binary.LittleEndian.Uint16(b[10:])
+	h.Dim = binary.LittleEndian.Uint32(b[12:])
+	h.Count = binary.LittleEndian.Uint64(b[16:])
+	if h.Version == 0 || h.Version > binaryVersion {
+		return fmt.Errorf("unsupported binary format version %d", h.Version)
+	}
+	return nil
+}
+
+// WriteBinary serializes the `LocalVectorStore` into w in the binary format.
+// All the embeddings must have the same dimension.
+func (vs *LocalVectorStore) WriteBinary(w io.Writer, opts *BinaryOptions) error {
+	if opts == nil {
+		opts = &BinaryOptions{}
+	}
+
+	vs.mu.RLock()
+	defer vs.mu.RUnlock()
+
+	return writeBinary(w, vs.allChunks(), opts)
+}
+
+func writeBinary(w io.Writer, chunks []*Chunk, opts *BinaryOptions) error {
+	header := &binaryHeader{
+		Version: binaryVersion,
+		Count:   uint64(len(chunks)),
+	}
+	if len(chunks) > 0 {
+		header.Dim = uint32(len(chunks[0].Embedding))
+	}
+	for _, chunk := range chunks {
+		if len(chunk.Embedding) != int(header.Dim) {
+			return fmt.Errorf("chunk %q: embedding dimension mismatch: want %d, got %d", chunk.ID, header.Dim, len(chunk.Embedding))
+		}
+	}
+	if opts.Float32 {
+		header.Flags |= binaryFlagFloat32
+	}
+	if opts.Compress {
+		header.Flags |= binaryFlagGzip
+	}
+
+	headerData := header.MarshalBinary()
+	if _, err := w.Write(headerData); err != nil {
+		return err
+	}
+
+	crc := crc32.New(castagnoli)
+	_, _ = crc.Write(headerData)
+
+	var gw *gzip.Writer
+	if opts.Compress {
+		gw = gzip.NewWriter(w)
+		w = gw
+	}
+	bw := bufio.NewWriter(w)
+	body := io.MultiWriter(bw, crc)
+
+	buf := make([]byte, 8)
+	for _, chunk := range chunks {
+		for _, v := range chunk.Embedding {
+			var err error
+			if opts.Float32 {
+				binary.LittleEndian.PutUint32(buf, math.Float32bits(float32(v)))
+				_, err = body.Write(buf[:4])
+			} else {
+				binary.LittleEndian.PutUint64(buf, math.Float64bits(v))
+				_, err = body.Write(buf)
+			}
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	for _, chunk := range chunks {
+		c := *chunk
+		c.Embedding = nil
+		data, err := json.Marshal(&c)
+		if err != nil {
+			return err
+		}
+
+		binary.LittleEndian.PutUint32(buf, uint32(len(data)))
+		if _, err := body.Write(buf[:4]); err != nil {
+			return err
+		}
+		if _, err := body.Write(data); err != nil {
+			return err
+		}
+	}
+
+	// The checksum itself is not covered by the checksum.
+	binary.LittleEndian.PutUint32(buf, crc.Sum32())
+	if _, err := bw.Write(buf[:4]); err != nil {
+		return err
+	}
+
+	if err := bw.Flush(); err != nil {
+		return err
+	}
+	if gw != nil {
+		return gw.Close()
+	}
+	return nil
+}
+
+// ReadBinary deserializes from r, which is in the binary format, into the `LocalVectorStore`.
+func (vs *LocalVectorStore) ReadBinary(ctx context.Context, r io.Reader) error {
+	chunks, err := readBinary(r)
+	if err != nil {
+		return err
+	}
+	return vs.Insert(ctx, groupChunks(chunks))
+}
+
+func readBinary(r io.Reader) ([]*Chunk, error) {
+	headerData := make([]byte, binaryHeaderSize)
+	if _, err := io.ReadFull(r, headerData); err != nil {
+		return nil, err
+	}
+	header := new(binaryHeader)
+	if err := header.UnmarshalBinary(headerData); err != nil {
+		return nil, err
+	}
+
+	crc := crc32.New(castagnoli)
+	_, _ = crc.Write(headerData)
+
+	if header.Flags&binaryFlagGzip != 0 {
+		gr, err := gzip.NewReader(r)
+		if err != nil {
+			return nil, err
+		}
+		defer gr.Close()
+		r = gr
+	}
+	br := bufio.NewReader(r)
+	body := io.TeeReader(br, crc)
+
+	if _, err := header.vectorsSize(); err != nil {
+		return nil, err
+	}
+
+	// Each iteration below consumes at least 4 bytes of the body (if dim is
+	// not 0) or of the metadata, so a corrupted count can only make the loops
+	// run until the data is exhausted.
+	elemSize := header.elemSize()
+	dim := int(header.Dim)
+	var vectors []float64
+	if dim > 0 {
+		buf := make([]byte, dim*elemSize)
+		// Do not trust the header for preallocation, which might be corrupted.
+		vectors = make([]float64, 0, xslices.Min(int(header.Count)*dim, 1<<20))
+		for i := uint64(0); i < header.Count; i++ {
+			if _, err := io.ReadFull(body, buf); err != nil {
+				return nil, unexpectedEOF(err)
+			}
+			for j := 0; j < dim; j++ {
+				if elemSize == 4 {
+					vectors = append(vectors, float64(math.Float32frombits(binary.LittleEndian.Uint32(buf[j*4:]))))
+				} else {
+					vectors = append(vectors, math.Float64frombits(binary.LittleEndian.Uint64(buf[j*8:])))
+				}
+			}
+		}
+	}
+
+	records, err := readRecords(body, header.Count)
+	if err != nil {
+		return nil, err
+	}
+
+	// Verify the checksum before decoding the metadata.
+	if err := verifyChecksum(br, crc); err != nil {
+		return nil, err
+	}
+
+	chunks, err := decodeMetadata(records)
+	if err != nil {
+		return nil, err
+	}
+	setEmbeddings(chunks, vectors, dim)
+	return chunks, nil
+}
+
+// readRecords reads count raw metadata records from r.
+func readRecords(r io.Reader, count uint64) ([][]byte, error) {
+	// Do not trust count for preallocation, which might be corrupted.
+	records := make([][]byte, 0, xslices.Min(count, 1<<16))
+	lenBuf := make([]byte, 4)
+	for i := uint64(0); i < count; i++ {
+		if _, err := io.ReadFull(r, lenBuf); err != nil {
+			return nil, unexpectedEOF(err)
+		}
+		n := binary.LittleEndian.Uint32(lenBuf)
+		if n > maxMetadataSize {
+			return nil, fmt.Errorf("metadata too large: %d bytes", n)
+		}
+
+		data := make([]byte, n)
+		if _, err := io.ReadFull(r, data); err != nil {
+			return nil, unexpectedEOF(err)
+		}
+		records = append(records, data)
+	}
+	return records, nil
+}
+
+// decodeMetadata decodes the chunks from the raw metadata records.
+func decodeMetadata(records [][]byte) ([]*Chunk, error) {
+	chunks := make([]*Chunk, len(records))
+	for i, data := range records {
+		chunk := new(Chunk)
+		if err := json.Unmarshal(data, chunk); err != nil {
+			return nil, err
+		}
+		chunks[i] = chunk
+	}
+	return chunks, nil
+}
+
+func verifyChecksum(r io.Reader, crc hash.Hash32) error {
+	buf := make([]byte, 4)
+	if _, err := io.ReadFull(r, buf); err != nil {
+		return unexpectedEOF(err)
+	}
+	if binary.LittleEndian.Uint32(buf) != crc.Sum32() {
+		return ErrChecksumMismatch
+	}
+	return nil
+}
+
+// setEmbeddings sets the embeddings of the chunks by slicing the vectors,
+// which are concatenated in the same order as the chunks.
+func setEmbeddings(chunks []*Chunk, vectors []float64, dim int) {
+	if dim == 0 {
+		return
+	}
+	for i, chunk := range chunks {
+		chunk.Embedding = vectors[i*dim : (i+1)*dim : (i+1)*dim]
+	}
+}
+
+func groupChunks(chunks []*Chunk) map[string][]*Chunk {
+	chunkMap := make(map[string][]*Chunk)
+	for _, chunk := range chunks {
+		chunkMap[chunk.DocumentID] = append(chunkMap[chunk.DocumentID], chunk)
+	}
+	return chunkMap
+}
+
+func unexpectedEOF(err error) error {
+	if err == io.EOF {
+		return io.ErrUnexpectedEOF
+	}
+	return err
+}
+
+// StoreBinary will serialize the `LocalVectorStore` to disk in the binary format
+// based on the provided filename. The file is replaced atomically.
+func (vs *LocalVectorStore) StoreBinary(filename string, opts *BinaryOptions) error {
+	f, err := os.CreateTemp(filepath.Dir(filename), filepath.Base(filename)+".tmp*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name()) // No-op if renamed.
+
+	if err := vs.WriteBinary(f, opts); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	return os.Rename(f.Name(), filename)
+}
+
+// LoadBinary will deserialize from disk in the binary format into a `LocalVectorStore`
+// based on the provided filename.
+func (vs *LocalVectorStore) LoadBinary(ctx context.Context, filename string) error {
+	f, err := os.Open(filename)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	return vs.ReadBinary(ctx, f)
+}
+
+// LoadBinaryMmap is like LoadBinary, but the embeddings are memory-mapped from
+// the file instead of being copied into memory, which makes loading faster and
+// lets the OS page them in on demand.
+//
+// It only works for uncompressed files with float64 embeddings on little-endian
+// platforms, and falls back to LoadBinary otherwise. The memory-mapped embeddings
+// are read-only, and they are valid until the store is closed (see Close).
+// The chunks returned by the store have their embeddings copied, though.
+func (vs *LocalVectorStore) LoadBinaryMmap(ctx context.Context, filename string) error {
+	data, unmap, err := mmapFile(filename)
+	if err != nil {
+		return err
+	}
+
+	header := new(binaryHeader)
+	if err := header.UnmarshalBinary(data); err != nil {
+		_ = unmap()
+		return err
+	}
+	if header.Flags != 0 || !nativeLittleEndian {
+		_ = unmap()
+		return vs.LoadBinary(ctx, filename)
+	}
+
+	chunks, err := mmapChunks(header, data)
+	if err != nil {
+		_ = unmap()
+		return err
+	}
+
+	vs.mu.Lock()
+	vs.unmaps = append(vs.unmaps, unmap)
+	vs.mu.Unlock()
+
+	return vs.Insert(ctx, groupChunks(chunks))
+}
+
+func mmapChunks(header *binaryHeader, data []byte) ([]*Chunk, error) {
+	vectorsSize, err := header.vectorsSize()
+	if err != nil {
+		return nil, err
+	}
+	vectorsEnd := binaryHeaderSize + vectorsSize
+	if len(data) < vectorsEnd+4 {
+		return nil, io.ErrUnexpectedEOF
+	}
+
+	// Each metadata record takes at least 4 bytes.
+	if header.Count > uint64(len(data)-vectorsEnd-4)/4 {
+		return nil, fmt.Errorf("too many chunks: %d", header.Count)
+	}
+
+	crc := crc32.New(castagnoli)
+	_, _ = crc.Write(data[:len(data)-4])
+	if err := verifyChecksum(bytes.NewReader(data[len(data)-4:]), crc); err != nil {
+		return nil, err
+	}
+
+	records, err := readRecords(bytes.NewReader(data[vectorsEnd:len(data)-4]), header.Count)
+	if err != nil {
+		return nil, err
+	}
+	chunks, err := decodeMetadata(records)
+	if err != nil {
+		return nil, err
+	}
+
+	if vectorsSize > 0 {
+		vectors := unsafe.Slice((*float64)(unsafe.Pointer(&data[binaryHeaderSize])), vectorsSize/8)
+		setEmbeddings(chunks, vectors, int(header.Dim))
+	}
+	return chunks, nil
+}
+
+// Close releases the resources held by the `LocalVectorStore` (e.g. the
+// memory-mapped files and the write-ahead log). The store must not be used
+// after closing.
+func (vs *LocalVectorStore) Close() error {
+	vs.mu.Lock()
+	defer vs.mu.Unlock()
+
+	var firstErr error
+	if vs.wal != nil {
+		firstErr = vs.wal.Close()
+		vs.wal = nil
+	}
+	if vs.quant != nil {
+		if err := vs.quant.Close(); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	for _, unmap := range vs.unmaps {
+		if err := unmap(); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	vs.unmaps = nil
+	return firstErr
+}
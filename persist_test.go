@@ -0,0 +1,182 @@
+package gptbot_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"math"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/go-aie/gptbot"
+	"github.com/google/go-cmp/cmp"
+)
+
+func newPersistTestStore() *gptbot.LocalVectorStore {
+	store := gptbot.NewLocalVectorStore()
+	_ = store.Insert(context.Background(), map[string][]*gptbot.Chunk{
+		"doc_id_1": {
+			{
+				ID:         "id_1",
+				Text:       "text_1",
+				DocumentID: "doc_id_1",
+				Metadata:   gptbot.Metadata{CorpusID: "c1", Attributes: map[string]any{"version": float64(3)}},
+				Embedding:  gptbot.Embedding{0.5, -0.25, 1},
+			},
+			{
+				ID:         "id_2",
+				Text:       "text_2",
+				DocumentID: "doc_id_1",
+				Embedding:  gptbot.Embedding{0, 0.125, -1},
+			},
+		},
+		"doc_id_2": {
+			{
+				ID:         "id_3",
+				Text:       "text_3",
+				DocumentID: "doc_id_2",
+				Embedding:  gptbot.Embedding{1, 2, 3},
+			},
+		},
+	})
+	return store
+}
+
+func TestLocalVectorStore_Binary(t *testing.T) {
+	store := newPersistTestStore()
+	want := store.GetAllData(context.Background())
+
+	tests := []struct {
+		name string
+		opts *gptbot.BinaryOptions
+	}{
+		{name: "float64", opts: nil},
+		{name: "float32", opts: &gptbot.BinaryOptions{Float32: true}},
+		{name: "compressed", opts: &gptbot.BinaryOptions{Compress: true}},
+		{name: "float32 compressed", opts: &gptbot.BinaryOptions{Float32: true, Compress: true}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			if err := store.WriteBinary(&buf, tt.opts); err != nil {
+				t.Fatalf("err: %v\n", err)
+			}
+
+			loaded := gptbot.NewLocalVectorStore()
+			if err := loaded.ReadBinary(context.Background(), &buf); err != nil {
+				t.Fatalf("err: %v\n", err)
+			}
+
+			// All the values are exactly representable in float32.
+			got := loaded.GetAllData(context.Background())
+			if !cmp.Equal(got, want) {
+				diff := cmp.Diff(got, want)
+				t.Errorf("Want - Got: %s", diff)
+			}
+		})
+	}
+}
+
+func TestLocalVectorStore_BinaryChecksum(t *testing.T) {
+	store := newPersistTestStore()
+
+	var buf bytes.Buffer
+	if err := store.WriteBinary(&buf, nil); err != nil {
+		t.Fatalf("err: %v\n", err)
+	}
+
+	// Corrupt one byte of the vectors.
+	data := buf.Bytes()
+	data[40] ^= 0xff
+
+	err := gptbot.NewLocalVectorStore().ReadBinary(context.Background(), bytes.NewReader(data))
+	if !errors.Is(err, gptbot.ErrChecksumMismatch) {
+		t.Errorf("Got (%v) != Want (%v)", err, gptbot.ErrChecksumMismatch)
+	}
+
+	// Truncated data.
+	err = gptbot.NewLocalVectorStore().ReadBinary(context.Background(), bytes.NewReader(data[:len(data)-10]))
+	if err == nil {
+		t.Errorf("want an error for truncated data")
+	}
+}
+
+func TestLocalVectorStore_BinaryCorruptCount(t *testing.T) {
+	var buf bytes.Buffer
+	if err := gptbot.NewLocalVectorStore().WriteBinary(&buf, nil); err != nil {
+		t.Fatalf("err: %v\n", err)
+	}
+
+	// An empty store with a huge count of chunks, whose dimension is 0.
+	data := buf.Bytes()
+	binary.LittleEndian.PutUint64(data[16:], math.MaxUint64)
+
+	err := gptbot.NewLocalVectorStore().ReadBinary(context.Background(), bytes.NewReader(data))
+	if err == nil {
+		t.Errorf("want an error for the corrupted count")
+	}
+
+	filename := filepath.Join(t.TempDir(), "store.bin")
+	if err := os.WriteFile(filename, data, 0644); err != nil {
+		t.Fatalf("err: %v\n", err)
+	}
+	store := gptbot.NewLocalVectorStore()
+	defer store.Close()
+	if err := store.LoadBinaryMmap(context.Background(), filename); err == nil {
+		t.Errorf("want an error for the corrupted count")
+	}
+}
+
+func TestLocalVectorStore_LoadBinaryMmap(t *testing.T) {
+	store := newPersistTestStore()
+	want := store.GetAllData(context.Background())
+
+	for _, opts := range []*gptbot.BinaryOptions{nil, {Compress: true}} {
+		filename := filepath.Join(t.TempDir(), "store.bin")
+		if err := store.StoreBinary(filename, opts); err != nil {
+			t.Fatalf("err: %v\n", err)
+		}
+
+		loaded := gptbot.NewLocalVectorStore()
+		if err := loaded.LoadBinaryMmap(context.Background(), filename); err != nil {
+			t.Fatalf("err: %v\n", err)
+		}
+
+		got := loaded.GetAllData(context.Background())
+		if !cmp.Equal(got, want) {
+			diff := cmp.Diff(got, want)
+			t.Errorf("Want - Got: %s", diff)
+		}
+
+		sims, err := loaded.Query(context.Background(), gptbot.Embedding{1, 1, 1}, "", 1, nil)
+		if err != nil {
+			t.Fatalf("err: %v\n", err)
+		}
+		if len(sims) != 1 || sims[0].ID != "id_3" {
+			t.Fatalf("unexpected similarities: %v", sims)
+		}
+
+		// The returned embeddings are writable copies of the memory-mapped ones.
+		sims[0].Embedding[0] = 0
+		chunks, err := loaded.GetDocument(context.Background(), "doc_id_1")
+		if err != nil {
+			t.Fatalf("err: %v\n", err)
+		}
+		chunks[0].Embedding[0] = 0
+		if got := loaded.GetAllData(context.Background()); opts == nil && !cmp.Equal(got, want) {
+			diff := cmp.Diff(got, want)
+			t.Errorf("Want - Got: %s", diff)
+		}
+
+		if err := loaded.Close(); err != nil {
+			t.Fatalf("err: %v\n", err)
+		}
+
+		// They are still valid after the store is closed.
+		if got, want := sims[0].Embedding, (gptbot.Embedding{0, 2, 3}); !cmp.Equal(got, want) {
+			t.Errorf("Got (%v) != Want (%v)", got, want)
+		}
+	}
+}
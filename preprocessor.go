@@ -88,6 +88,7 @@ func (p *Preprocessor) Preprocess(docs ...*Document) (map[string][]*Chunk, error
 				Text:       textChunk,
 				DocumentID: docID,
 				Metadata:   meta,
+				Position:   i,
 			})
 		}
 	}
@@ -210,3 +211,12 @@ func (t *dummyTokenizer) Encode(runes []rune, tokenNum int) ([]rune, error) {
 	}
 	return runes, nil
 }
+
+// Count returns the number of tokens in the given text.
+func (t *dummyTokenizer) Count(text string) (int, error) {
+	tokens, err := t.encoder.Encode(text)
+	if err != nil {
+		return 0, err
+	}
+	return len(tokens), nil
+}
@@ -55,11 +55,13 @@ func TestPreprocessor_Preprocess(t *testing.T) {
 						ID:         "2_1",
 						Text:       "GPT-3是由在旧金山的人工智能公司OpenAI训练与开发，模型设计基于谷歌开发的 Transformer 语言模型。",
 						DocumentID: "2",
+						Position:   1,
 					},
 					{
 						ID:         "2_2",
 						Text:       "GPT-3的神经网络包含1750亿个参数，需要800GB来存储, 为有史以来参数最多的神经网络模型[2]。该模型在许多任务上展示了强大的零样本和少样本的能力。",
 						DocumentID: "2",
+						Position:   2,
 					},
 				},
 				"3": {
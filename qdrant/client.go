@@ -0,0 +1,151 @@
+package qdrant
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+
+	"github.com/go-aie/gptbot"
+)
+
+// Error is an error returned by the Qdrant server.
+type Error struct {
+	StatusCode int
+	Message    string
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("qdrant: %s (status %d)", e.Message, e.StatusCode)
+}
+
+func isNotFound(err error) bool {
+	var e *Error
+	return errors.As(err, &e) && e.StatusCode == http.StatusNotFound
+}
+
+// condition is a condition (or a nested filter) of Qdrant filters.
+type condition map[string]any
+
+type payload struct {
+	ID         string         `json:"id"`
+	Text       string         `json:"text"`
+	DocumentID string         `json:"document_id"`
+	CorpusID   string         `json:"corpus_id"`
+	Attributes map[string]any `json:"attributes,omitempty"`
+	Position   int            `json:"position"`
+	CreatedAt  int64          `json:"created_at"`
+}
+
+type point struct {
+	ID      string           `json:"id"`
+	Vector  gptbot.Embedding `json:"vector"`
+	Payload *payload         `json:"payload"`
+}
+
+type scoredPoint struct {
+	ID      string           `json:"id"`
+	Score   float64          `json:"score"`
+	Vector  gptbot.Embedding `json:"vector,omitempty"`
+	Payload *payload         `json:"payload,omitempty"`
+}
+
+type vectorParams struct {
+	Size     int    `json:"size"`
+	Distance string `json:"distance"`
+}
+
+type collectionInfo struct {
+	Config struct {
+		Params struct {
+			Vectors vectorParams `json:"vectors"`
+		} `json:"params"`
+	} `json:"config"`
+}
+
+type createCollectionRequest struct {
+	Vectors vectorParams `json:"vectors"`
+}
+
+type createIndexRequest struct {
+	FieldName   string `json:"field_name"`
+	FieldSchema string `json:"field_schema"`
+}
+
+type upsertRequest struct {
+	Points []*point `json:"points"`
+}
+
+type searchRequest struct {
+	Vector      gptbot.Embedding `json:"vector"`
+	Limit       int              `json:"limit"`
+	Filter      condition        `json:"filter,omitempty"`
+	WithPayload bool             `json:"with_payload"`
+	WithVector  bool             `json:"with_vector"`
+}
+
+type deleteRequest struct {
+	Filter condition `json:"filter"`
+}
+
+// do sends a request with the JSON body in (if not nil) to the given path,
+// and decodes the result of the response into out (if not nil).
+func (q *Qdrant) do(ctx context.Context, method, path string, in, out any) error {
+	var body io.Reader
+	if in != nil {
+		data, err := json.Marshal(in)
+		if err != nil {
+			return err
+		}
+		body = bytes.NewReader(data)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, q.cfg.BaseURL+path, body)
+	if err != nil {
+		return err
+	}
+	if in != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if q.cfg.APIKey != "" {
+		req.Header.Set("api-key", q.cfg.APIKey)
+	}
+
+	resp, err := q.cfg.HTTPClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+
+	var r struct {
+		Result json.RawMessage `json:"result"`
+		Status json.RawMessage `json:"status"`
+	}
+	if resp.StatusCode/100 != 2 {
+		// The status is an object containing the error message on failure.
+		var status struct {
+			Error string `json:"error"`
+		}
+		msg := string(data)
+		if json.Unmarshal(data, &r) == nil && json.Unmarshal(r.Status, &status) == nil && status.Error != "" {
+			msg = status.Error
+		}
+		return &Error{StatusCode: resp.StatusCode, Message: msg}
+	}
+
+	if out == nil {
+		return nil
+	}
+	if err := json.Unmarshal(data, &r); err != nil {
+		return err
+	}
+	return json.Unmarshal(r.Result, out)
+}
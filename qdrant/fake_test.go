@@ -0,0 +1,277 @@
+package qdrant
+
+import (
+	"encoding/json"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// fakeServer is a fake Qdrant server, which implements the subset of the
+// REST API used by Qdrant in memory.
+type fakeServer struct {
+	apiKey string
+
+	mu          sync.Mutex
+	collections map[string]*fakeCollection
+}
+
+type fakeCollection struct {
+	params vectorParams
+	points map[string]*fakePoint
+}
+
+type fakePoint struct {
+	ID      string         `json:"id"`
+	Vector  []float64      `json:"vector"`
+	Payload map[string]any `json:"payload"`
+}
+
+type fakeScoredPoint struct {
+	ID      string         `json:"id"`
+	Score   float64        `json:"score"`
+	Vector  []float64      `json:"vector,omitempty"`
+	Payload map[string]any `json:"payload"`
+}
+
+// newFakeQdrant starts a fake server, and creates a Qdrant connecting to it.
+func newFakeQdrant(t *testing.T, cfg *Config) (*Qdrant, *fakeServer) {
+	s := &fakeServer{collections: make(map[string]*fakeCollection)}
+	ts := httptest.NewServer(s)
+	t.Cleanup(ts.Close)
+
+	cfg.BaseURL = ts.URL
+	q, err := NewQdrant(cfg)
+	if err != nil {
+		t.Fatalf("err: %v\n", err)
+	}
+	return q, s
+}
+
+func (s *fakeServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.apiKey != "" && r.Header.Get("api-key") != s.apiKey {
+		fail(w, http.StatusUnauthorized, "Invalid api-key")
+		return
+	}
+
+	name, resource, _ := strings.Cut(strings.TrimPrefix(r.URL.Path, "/collections/"), "/")
+	coll := s.collections[name]
+	if coll == nil && !(r.Method == http.MethodPut && resource == "") {
+		fail(w, http.StatusNotFound, "Not found: Collection `"+name+"` doesn't exist!")
+		return
+	}
+
+	switch r.Method + " " + resource {
+	case "GET ":
+		info := &collectionInfo{}
+		info.Config.Params.Vectors = coll.params
+		reply(w, info)
+	case "PUT ":
+		var req createCollectionRequest
+		if !decode(w, r, &req) {
+			return
+		}
+		s.collections[name] = &fakeCollection{params: req.Vectors, points: make(map[string]*fakePoint)}
+		reply(w, true)
+	case "DELETE ":
+		delete(s.collections, name)
+		reply(w, true)
+	case "PUT index":
+		reply(w, map[string]any{"status": "completed"})
+	case "PUT points":
+		var req struct {
+			Points []*fakePoint `json:"points"`
+		}
+		if !decode(w, r, &req) {
+			return
+		}
+		for _, p := range req.Points {
+			if len(p.Vector) != coll.params.Size {
+				fail(w, http.StatusBadRequest, "Wrong input: Vector dimension error")
+				return
+			}
+		}
+		for _, p := range req.Points {
+			coll.points[p.ID] = p
+		}
+		reply(w, map[string]any{"status": "completed"})
+	case "POST points/search":
+		var req struct {
+			Vector     []float64      `json:"vector"`
+			Limit      int            `json:"limit"`
+			Filter     map[string]any `json:"filter"`
+			WithVector bool           `json:"with_vector"`
+		}
+		if !decode(w, r, &req) {
+			return
+		}
+		reply(w, coll.search(req.Vector, req.Limit, req.Filter, req.WithVector))
+	case "POST points/delete":
+		var req struct {
+			Filter map[string]any `json:"filter"`
+		}
+		if !decode(w, r, &req) {
+			return
+		}
+		for id, p := range coll.points {
+			if fakeMatch(req.Filter, p) {
+				delete(coll.points, id)
+			}
+		}
+		reply(w, map[string]any{"status": "completed"})
+	default:
+		fail(w, http.StatusNotFound, "Not found")
+	}
+}
+
+func (c *fakeCollection) search(vector []float64, limit int, filter map[string]any, withVector bool) []*fakeScoredPoint {
+	var points []*fakeScoredPoint
+	for _, p := range c.points {
+		if filter != nil && !fakeMatch(filter, p) {
+			continue
+		}
+		sp := &fakeScoredPoint{ID: p.ID, Score: fakeScore(c.params.Distance, vector, p.Vector), Payload: p.Payload}
+		if withVector {
+			sp.Vector = p.Vector
+		}
+		points = append(points, sp)
+	}
+
+	sort.Slice(points, func(i, j int) bool {
+		if c.params.Distance == "Euclid" {
+			return points[i].Score < points[j].Score
+		}
+		return points[i].Score > points[j].Score
+	})
+	if len(points) > limit {
+		points = points[:limit]
+	}
+	return points
+}
+
+func fakeScore(distance string, a, b []float64) float64 {
+	var dot, na, nb, d2 float64
+	for i := range a {
+		dot += a[i] * b[i]
+		na += a[i] * a[i]
+		nb += b[i] * b[i]
+		d2 += (a[i] - b[i]) * (a[i] - b[i])
+	}
+	switch distance {
+	case "Cosine":
+		return dot / math.Sqrt(na*nb)
+	case "Dot":
+		return dot
+	default:
+		return math.Sqrt(d2)
+	}
+}
+
+// fakeMatch reports whether the point satisfies the condition (or the filter).
+func fakeMatch(cond map[string]any, p *fakePoint) bool {
+	if isFilter := cond["must"] != nil || cond["should"] != nil || cond["must_not"] != nil; isFilter {
+		for _, c := range conditions(cond["must"]) {
+			if !fakeMatch(c, p) {
+				return false
+			}
+		}
+		should := conditions(cond["should"])
+		matched := len(should) == 0
+		for _, c := range should {
+			matched = matched || fakeMatch(c, p)
+		}
+		for _, c := range conditions(cond["must_not"]) {
+			if fakeMatch(c, p) {
+				return false
+			}
+		}
+		return matched
+	}
+
+	if ids, ok := cond["has_id"].([]any); ok {
+		for _, id := range ids {
+			if id == p.ID {
+				return true
+			}
+		}
+		return false
+	}
+	if c, ok := cond["is_empty"].(map[string]any); ok {
+		return payloadValue(p.Payload, c["key"].(string)) == nil
+	}
+
+	value := payloadValue(p.Payload, cond["key"].(string))
+	if m, ok := cond["match"].(map[string]any); ok {
+		if values, ok := m["any"].([]any); ok {
+			for _, v := range values {
+				if v == value {
+					return true
+				}
+			}
+			return false
+		}
+		return m["value"] == value
+	}
+	if r, ok := cond["range"].(map[string]any); ok {
+		x, ok := value.(float64)
+		if !ok {
+			return false
+		}
+		for op, v := range r {
+			y := v.(float64)
+			if op == "gt" && !(x > y) || op == "gte" && !(x >= y) || op == "lt" && !(x < y) || op == "lte" && !(x <= y) {
+				return false
+			}
+		}
+		return true
+	}
+	return false
+}
+
+func conditions(v any) []map[string]any {
+	list, _ := v.([]any)
+	var conds []map[string]any
+	for _, c := range list {
+		conds = append(conds, c.(map[string]any))
+	}
+	return conds
+}
+
+// payloadValue returns the value of the nested payload field by the given key.
+func payloadValue(payload map[string]any, key string) any {
+	var value any = payload
+	for _, name := range strings.Split(key, ".") {
+		m, ok := value.(map[string]any)
+		if !ok {
+			return nil
+		}
+		value = m[name]
+	}
+	return value
+}
+
+func decode(w http.ResponseWriter, r *http.Request, v any) bool {
+	if err := json.NewDecoder(r.Body).Decode(v); err != nil {
+		fail(w, http.StatusBadRequest, "Format error in JSON body: "+err.Error())
+		return false
+	}
+	return true
+}
+
+func reply(w http.ResponseWriter, result any) {
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(map[string]any{"result": result, "status": "ok", "time": 0})
+}
+
+func fail(w http.ResponseWriter, code int, msg string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	_ = json.NewEncoder(w).Encode(map[string]any{"status": map[string]any{"error": msg}, "time": 0})
+}
@@ -0,0 +1,166 @@
+package qdrant
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strings"
+
+	"github.com/go-aie/gptbot"
+)
+
+// ErrUnsupportedFilter is returned by queries with a filter that can not be
+// translated into a Qdrant condition.
+var ErrUnsupportedFilter = errors.New("unsupported filter")
+
+// noneCondition is the condition matching no points.
+var noneCondition = condition{"has_id": []string{}}
+
+// buildCondition translates the given (validated) filter into a Qdrant
+// condition over the payload field of attributes.
+func buildCondition(f *gptbot.Filter) (condition, error) {
+	switch f.Op {
+	case gptbot.OpAnd, gptbot.OpOr:
+		if len(f.Filters) == 0 {
+			// Follow the semantics of gptbot.Filter.Match.
+			if f.Op == gptbot.OpAnd {
+				return condition{"must_not": []condition{noneCondition}}, nil
+			}
+			return condition{"must": []condition{noneCondition}}, nil
+		}
+		var conds []condition
+		for _, sub := range f.Filters {
+			cond, err := buildCondition(sub)
+			if err != nil {
+				return nil, err
+			}
+			conds = append(conds, cond)
+		}
+		if f.Op == gptbot.OpAnd {
+			return condition{"must": conds}, nil
+		}
+		return condition{"should": conds}, nil
+	case gptbot.OpNot:
+		cond, err := buildCondition(f.Filters[0])
+		if err != nil {
+			return nil, err
+		}
+		return condition{"must_not": []condition{cond}}, nil
+	}
+
+	key, err := attributeKey(f.Key)
+	if err != nil {
+		return nil, err
+	}
+
+	switch f.Op {
+	case gptbot.OpEq:
+		return equalCondition(key, f.Value)
+	case gptbot.OpNe:
+		cond, err := equalCondition(key, f.Value)
+		if err != nil {
+			return nil, err
+		}
+		// Comparisons against a missing attribute always evaluate to false.
+		return condition{"must_not": []condition{cond, {"is_empty": condition{"key": key}}}}, nil
+	case gptbot.OpIn:
+		values, _ := gptbot.FilterValues(f.Value)
+		if len(values) == 0 {
+			return condition{"must": []condition{noneCondition}}, nil
+		}
+		var conds []condition
+		for _, v := range values {
+			cond, err := equalCondition(key, v)
+			if err != nil {
+				return nil, err
+			}
+			conds = append(conds, cond)
+		}
+		return condition{"should": conds}, nil
+	}
+
+	// Qdrant only supports ranges over numbers.
+	v, err := number(f.Value)
+	if err != nil {
+		return nil, fmt.Errorf("%w: range over %v (type %T) on %q, only numbers are supported", ErrUnsupportedFilter, f.Value, f.Value, f.Key)
+	}
+	var op string
+	switch f.Op {
+	case gptbot.OpGt:
+		op = "gt"
+	case gptbot.OpGe:
+		op = "gte"
+	case gptbot.OpLt:
+		op = "lt"
+	case gptbot.OpLe:
+		op = "lte"
+	}
+	return condition{"key": key, "range": condition{op: v}}, nil
+}
+
+// equalCondition returns the condition matching the points whose payload
+// field equals value.
+func equalCondition(key string, value any) (condition, error) {
+	switch value.(type) {
+	case string, bool:
+		return matchCondition(key, value), nil
+	}
+
+	// Numbers are matched by ranges, since Qdrant only matches integers
+	// exactly, and integers may be stored as floats (e.g. decoded from JSON).
+	v, err := number(value)
+	if err != nil {
+		return nil, err
+	}
+	return condition{"key": key, "range": condition{"gte": v, "lte": v}}, nil
+}
+
+func matchCondition(key string, value any) condition {
+	return condition{"key": key, "match": condition{"value": value}}
+}
+
+// attributeKey returns the key of the payload field for the given attribute.
+func attributeKey(key string) (string, error) {
+	// These characters have special meanings in Qdrant keys.
+	if strings.ContainsAny(key, `.[]"`) {
+		return "", fmt.Errorf("%w: key %q", ErrUnsupportedFilter, key)
+	}
+	return attributesName + "." + key, nil
+}
+
+// number converts the given value into a float64.
+func number(value any) (float64, error) {
+	var v float64
+	switch x := value.(type) {
+	case int:
+		v = float64(x)
+	case int8:
+		v = float64(x)
+	case int16:
+		v = float64(x)
+	case int32:
+		v = float64(x)
+	case int64:
+		v = float64(x)
+	case uint:
+		v = float64(x)
+	case uint8:
+		v = float64(x)
+	case uint16:
+		v = float64(x)
+	case uint32:
+		v = float64(x)
+	case uint64:
+		v = float64(x)
+	case float32:
+		v = float64(x)
+	case float64:
+		v = x
+	default:
+		return 0, fmt.Errorf("%w: value %v (type %T)", ErrUnsupportedFilter, value, value)
+	}
+	if math.IsNaN(v) || math.IsInf(v, 0) {
+		return 0, fmt.Errorf("%w: value %v", ErrUnsupportedFilter, value)
+	}
+	return v, nil
+}
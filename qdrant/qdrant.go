@@ -0,0 +1,297 @@
+package qdrant
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/go-aie/gptbot"
+	"github.com/go-aie/xslices"
+	"github.com/google/uuid"
+)
+
+const (
+	documentIDName, corpusIDName, attributesName = "document_id", "corpus_id", "attributes"
+)
+
+// namespace is the UUID namespace for generating point IDs.
+var namespace = uuid.MustParse("0b6f3c6e-4a0f-4f3e-9d55-6d0c1ad3e1a7")
+
+type Config struct {
+	// CollectionName is the collection name.
+	// This field is required.
+	CollectionName string
+
+	// CreateNew specifies whether to overwrite if the collection already exists.
+	CreateNew bool
+
+	// BaseURL is the base URL of the REST API of the Qdrant server.
+	// Defaults to "http://localhost:6333".
+	BaseURL string
+
+	// APIKey is the API key, if authentication is enabled.
+	APIKey string
+
+	// HTTPClient is the HTTP client for sending requests.
+	// Defaults to an HTTP client with a timeout of 30s.
+	HTTPClient *http.Client
+
+	// Dim is the embedding dimension.
+	// Defaults to 1536 (the dimension generated by OpenAI's Embedding API).
+	Dim int
+
+	// Metric is the metric used for measuring the similarity between embeddings.
+	// Note that it's fixed once the collection is created.
+	// Defaults to gptbot.MetricL2.
+	Metric gptbot.Metric
+
+	// OutputEmbedding specifies whether to return the embeddings along with
+	// the similarities in Query, which is required by MMR (see gptbot.BotConfig.MMR).
+	OutputEmbedding bool
+
+	// BatchSize is the maximum number of chunks sent in a single upsert request.
+	// Defaults to 256.
+	BatchSize int
+}
+
+func (cfg *Config) init() {
+	if cfg.BaseURL == "" {
+		cfg.BaseURL = "http://localhost:6333"
+	}
+	cfg.BaseURL = strings.TrimRight(cfg.BaseURL, "/")
+	if cfg.HTTPClient == nil {
+		cfg.HTTPClient = &http.Client{Timeout: 30 * time.Second}
+	}
+	if cfg.Dim == 0 {
+		cfg.Dim = 1536
+	}
+	if cfg.Metric == "" {
+		cfg.Metric = gptbot.MetricL2
+	}
+	if cfg.BatchSize == 0 {
+		cfg.BatchSize = 256
+	}
+}
+
+// Qdrant is a vector store backed by a Qdrant server, which speaks the REST API.
+//
+// Chunks are stored as points, whose IDs are derived from the document IDs
+// and the chunk IDs. As a result, inserting a chunk replaces the existing one
+// with the same ID in the same document.
+type Qdrant struct {
+	cfg *Config
+}
+
+func NewQdrant(cfg *Config) (*Qdrant, error) {
+	cfg.init()
+	if _, err := distance(cfg.Metric); err != nil {
+		return nil, err
+	}
+
+	q := &Qdrant{cfg: cfg}
+	if err := q.createCollection(context.Background(), cfg.CreateNew); err != nil {
+		return nil, err
+	}
+	return q, nil
+}
+
+// Insert implements gptbot.Updater.
+func (q *Qdrant) Insert(ctx context.Context, chunks map[string][]*gptbot.Chunk) error {
+	var points []*point
+	for _, chunkList := range chunks {
+		for _, chunk := range chunkList {
+			points = append(points, &point{
+				ID:     pointID(chunk),
+				Vector: chunk.Embedding,
+				Payload: &payload{
+					ID:         chunk.ID,
+					Text:       chunk.Text,
+					DocumentID: chunk.DocumentID,
+					CorpusID:   chunk.Metadata.CorpusID,
+					Attributes: chunk.Metadata.Attributes,
+					Position:   chunk.Position,
+					CreatedAt:  chunk.CreatedAt,
+				},
+			})
+		}
+	}
+
+	for start := 0; start < len(points); start += q.cfg.BatchSize {
+		end := xslices.Min(start+q.cfg.BatchSize, len(points))
+		req := &upsertRequest{Points: points[start:end]}
+		if err := q.do(ctx, http.MethodPut, q.path("/points?wait=true"), req, nil); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Query implements gptbot.Querier.
+func (q *Qdrant) Query(ctx context.Context, embedding gptbot.Embedding, corpusID string, topK int, filter *gptbot.Filter) ([]*gptbot.Similarity, error) {
+	if topK <= 0 {
+		return nil, nil
+	}
+
+	var must []condition
+	if corpusID != "" {
+		must = append(must, matchCondition(corpusIDName, corpusID))
+	}
+	if filter != nil {
+		if err := filter.Validate(); err != nil {
+			return nil, err
+		}
+		cond, err := buildCondition(filter)
+		if err != nil {
+			return nil, err
+		}
+		must = append(must, cond)
+	}
+
+	req := &searchRequest{
+		Vector:      embedding,
+		Limit:       topK,
+		WithPayload: true,
+		WithVector:  q.cfg.OutputEmbedding,
+	}
+	if len(must) > 0 {
+		req.Filter = condition{"must": must}
+	}
+
+	var points []*scoredPoint
+	if err := q.do(ctx, http.MethodPost, q.path("/points/search"), req, &points); err != nil {
+		return nil, err
+	}
+
+	var similarities []*gptbot.Similarity
+	for _, p := range points {
+		if p.Payload == nil {
+			return nil, fmt.Errorf("missing payload of point %s", p.ID)
+		}
+		similarities = append(similarities, &gptbot.Similarity{
+			Chunk: &gptbot.Chunk{
+				ID:         p.Payload.ID,
+				Text:       p.Payload.Text,
+				DocumentID: p.Payload.DocumentID,
+				Metadata: gptbot.Metadata{
+					CorpusID:   p.Payload.CorpusID,
+					Attributes: p.Payload.Attributes,
+				},
+				Embedding: p.Vector,
+				Position:  p.Payload.Position,
+				CreatedAt: p.Payload.CreatedAt,
+			},
+			Score: q.score(p.Score),
+		})
+	}
+	return similarities, nil
+}
+
+// Delete deletes the chunks belonging to the given documentIDs.
+// As a special case, empty documentIDs means deleting all chunks.
+func (q *Qdrant) Delete(ctx context.Context, documentIDs ...string) error {
+	// To delete all chunks, we drop the old collection and create a new one.
+	if len(documentIDs) == 0 {
+		return q.createCollection(ctx, true)
+	}
+
+	values := make([]any, len(documentIDs))
+	for i, id := range documentIDs {
+		values[i] = id
+	}
+	req := &deleteRequest{
+		Filter: condition{"must": []condition{{
+			"key":   documentIDName,
+			"match": condition{"any": values},
+		}}},
+	}
+	return q.do(ctx, http.MethodPost, q.path("/points/delete?wait=true"), req, nil)
+}
+
+// Metric returns the metric used for measuring the similarity between embeddings.
+func (q *Qdrant) Metric() gptbot.Metric {
+	return q.cfg.Metric
+}
+
+func (q *Qdrant) createCollection(ctx context.Context, createNew bool) error {
+	var info collectionInfo
+	err := q.do(ctx, http.MethodGet, q.path(""), nil, &info)
+	has := err == nil
+	if err != nil && !isNotFound(err) {
+		return err
+	}
+
+	if has && !createNew {
+		return q.checkCollection(&info)
+	}
+
+	if has {
+		if err := q.do(ctx, http.MethodDelete, q.path(""), nil, nil); err != nil {
+			return err
+		}
+	}
+
+	// The collection does not exist, so we need to create one.
+
+	d, _ := distance(q.cfg.Metric)
+	req := &createCollectionRequest{Vectors: vectorParams{Size: q.cfg.Dim, Distance: d}}
+	if err := q.do(ctx, http.MethodPut, q.path(""), req, nil); err != nil {
+		return err
+	}
+
+	// Index the fields used for scoping queries and deletions.
+	for _, field := range []string{documentIDName, corpusIDName} {
+		req := &createIndexRequest{FieldName: field, FieldSchema: "keyword"}
+		if err := q.do(ctx, http.MethodPut, q.path("/index?wait=true"), req, nil); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// checkCollection checks whether the existing collection matches the config.
+func (q *Qdrant) checkCollection(info *collectionInfo) error {
+	want, _ := distance(q.cfg.Metric)
+	got := info.Config.Params.Vectors
+	if got.Size != q.cfg.Dim || got.Distance != want {
+		return fmt.Errorf("collection %q has vectors of size %d and distance %s, want size %d and distance %s",
+			q.cfg.CollectionName, got.Size, got.Distance, q.cfg.Dim, want)
+	}
+	return nil
+}
+
+// path returns the path of the given resource of the collection.
+func (q *Qdrant) path(resource string) string {
+	return "/collections/" + url.PathEscape(q.cfg.CollectionName) + resource
+}
+
+// score converts the raw score returned by Qdrant into the normalized one,
+// which means more similar if higher.
+func (q *Qdrant) score(raw float64) float64 {
+	if q.cfg.Metric == gptbot.MetricL2 {
+		// Qdrant returns the Euclidean distance.
+		return gptbot.L2Score(raw)
+	}
+	return raw
+}
+
+// pointID returns the ID of the point for the given chunk, which is unique
+// among all the chunks of all the documents.
+func pointID(chunk *gptbot.Chunk) string {
+	return uuid.NewSHA1(namespace, []byte(chunk.DocumentID+"\x00"+chunk.ID)).String()
+}
+
+func distance(metric gptbot.Metric) (string, error) {
+	switch metric {
+	case gptbot.MetricCosine:
+		return "Cosine", nil
+	case gptbot.MetricDotProduct:
+		return "Dot", nil
+	case gptbot.MetricL2:
+		return "Euclid", nil
+	default:
+		return "", fmt.Errorf("unsupported metric %q", metric)
+	}
+}
@@ -0,0 +1,323 @@
+package qdrant
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/go-aie/gptbot"
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestQdrant_Query(t *testing.T) {
+	ctx := context.Background()
+	q, _ := newFakeQdrant(t, &Config{CollectionName: "test", Dim: 2, Metric: gptbot.MetricCosine})
+
+	doc1 := &gptbot.Chunk{
+		ID:         "doc_1_0",
+		Text:       "foo",
+		DocumentID: "doc_1",
+		Metadata: gptbot.Metadata{
+			CorpusID:   "c1",
+			Attributes: map[string]any{"product": "X", "version": 1.0},
+		},
+		Embedding: gptbot.Embedding{1, 0},
+		Position:  0,
+		CreatedAt: 1700000000,
+	}
+	err := q.Insert(ctx, map[string][]*gptbot.Chunk{
+		"doc_1": {doc1},
+		"doc_2": {{ID: "doc_2_0", DocumentID: "doc_2", Metadata: gptbot.Metadata{CorpusID: "c1", Attributes: map[string]any{"product": "Y", "version": 3.0}}, Embedding: gptbot.Embedding{0.6, 0.8}}},
+		"doc_3": {{ID: "doc_3_0", DocumentID: "doc_3", Metadata: gptbot.Metadata{CorpusID: "c2", Attributes: map[string]any{"product": "X", "version": 3.0}}, Embedding: gptbot.Embedding{0.8, 0.6}}},
+		"doc_4": {{ID: "doc_4_0", DocumentID: "doc_4", Metadata: gptbot.Metadata{CorpusID: "c2"}, Embedding: gptbot.Embedding{0, 1}}},
+	})
+	if err != nil {
+		t.Fatalf("err: %v\n", err)
+	}
+
+	tests := []struct {
+		name     string
+		corpusID string
+		filter   *gptbot.Filter
+		want     []string
+	}{
+		{
+			name: "all",
+			want: []string{"doc_1", "doc_3", "doc_2", "doc_4"},
+		},
+		{
+			name:     "corpus",
+			corpusID: "c1",
+			want:     []string{"doc_1", "doc_2"},
+		},
+		{
+			name:   "range",
+			filter: gptbot.Ge("version", 3),
+			want:   []string{"doc_3", "doc_2"},
+		},
+		{
+			name:     "and",
+			corpusID: "c2",
+			filter:   gptbot.And(gptbot.Eq("product", "X"), gptbot.Ge("version", 3)),
+			want:     []string{"doc_3"},
+		},
+		{
+			name:   "or",
+			filter: gptbot.Or(gptbot.Eq("version", 1), gptbot.Eq("product", "Y")),
+			want:   []string{"doc_1", "doc_2"},
+		},
+		{
+			name:   "ne excludes missing attributes",
+			filter: gptbot.Ne("product", "X"),
+			want:   []string{"doc_2"},
+		},
+		{
+			name:   "not includes missing attributes",
+			filter: gptbot.Not(gptbot.Eq("product", "X")),
+			want:   []string{"doc_2", "doc_4"},
+		},
+		{
+			name:   "in",
+			filter: gptbot.In("product", "Y", "Z"),
+			want:   []string{"doc_2"},
+		},
+		{
+			name:   "empty or",
+			filter: gptbot.Or(),
+			want:   nil,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			similarities, err := q.Query(ctx, gptbot.Embedding{1, 0}, tt.corpusID, 10, tt.filter)
+			if err != nil {
+				t.Fatalf("err: %v\n", err)
+			}
+
+			var got []string
+			for _, s := range similarities {
+				got = append(got, s.DocumentID)
+				if !tt.filter.Match(s.Metadata.Attributes) {
+					t.Errorf("chunk %q does not match the filter", s.ID)
+				}
+			}
+			if !cmp.Equal(got, tt.want) {
+				diff := cmp.Diff(got, tt.want)
+				t.Errorf("Want - Got: %s", diff)
+			}
+		})
+	}
+
+	// No request is sent for a non-positive topK.
+	similarities, err := q.Query(ctx, gptbot.Embedding{1, 0}, "", 0, nil)
+	if err != nil {
+		t.Fatalf("err: %v\n", err)
+	}
+	if len(similarities) != 0 {
+		t.Errorf("unexpected similarities: %v", similarities)
+	}
+
+	// Range filters over strings are not supported.
+	_, err = q.Query(ctx, gptbot.Embedding{1, 0}, "", 10, gptbot.Gt("product", "X"))
+	if !errors.Is(err, ErrUnsupportedFilter) {
+		t.Errorf("Got err (%v), Want err (%v)", err, ErrUnsupportedFilter)
+	}
+
+	// The chunks are returned as is.
+	similarities, err = q.Query(ctx, gptbot.Embedding{1, 0}, "c1", 1, nil)
+	if err != nil {
+		t.Fatalf("err: %v\n", err)
+	}
+	want := *doc1
+	want.Embedding = nil
+	if len(similarities) != 1 || !cmp.Equal(similarities[0].Chunk, &want) {
+		t.Errorf("Got (%+v) != Want (%+v)", similarities, &want)
+	}
+}
+
+func TestQdrant_Upsert(t *testing.T) {
+	ctx := context.Background()
+	q, s := newFakeQdrant(t, &Config{CollectionName: "test", Dim: 2, OutputEmbedding: true})
+
+	for _, text := range []string{"old", "new"} {
+		err := q.Insert(ctx, map[string][]*gptbot.Chunk{
+			"doc_1": {{ID: "1", Text: text, DocumentID: "doc_1", Embedding: gptbot.Embedding{1, 0}}},
+			// The same chunk ID in another document.
+			"doc_2": {{ID: "1", Text: text, DocumentID: "doc_2", Embedding: gptbot.Embedding{0, 1}}},
+		})
+		if err != nil {
+			t.Fatalf("err: %v\n", err)
+		}
+	}
+
+	if n := len(s.collections["test"].points); n != 2 {
+		t.Fatalf("Got (%d) != Want (%d)", n, 2)
+	}
+
+	similarities, err := q.Query(ctx, gptbot.Embedding{1, 0}, "", 1, nil)
+	if err != nil {
+		t.Fatalf("err: %v\n", err)
+	}
+	want := []*gptbot.Similarity{{
+		Chunk: &gptbot.Chunk{ID: "1", Text: "new", DocumentID: "doc_1", Embedding: gptbot.Embedding{1, 0}},
+		Score: 1,
+	}}
+	if !cmp.Equal(similarities, want) {
+		diff := cmp.Diff(similarities, want)
+		t.Errorf("Want - Got: %s", diff)
+	}
+}
+
+func TestQdrant_InsertBatches(t *testing.T) {
+	ctx := context.Background()
+	q, s := newFakeQdrant(t, &Config{CollectionName: "test", Dim: 2, BatchSize: 2})
+
+	var chunks []*gptbot.Chunk
+	for _, id := range []string{"1", "2", "3", "4", "5"} {
+		chunks = append(chunks, &gptbot.Chunk{ID: id, DocumentID: "doc_1", Embedding: gptbot.Embedding{1, 0}})
+	}
+	if err := q.Insert(ctx, map[string][]*gptbot.Chunk{"doc_1": chunks}); err != nil {
+		t.Fatalf("err: %v\n", err)
+	}
+	if n := len(s.collections["test"].points); n != 5 {
+		t.Errorf("Got (%d) != Want (%d)", n, 5)
+	}
+
+	// An invalid embedding fails the insert.
+	err := q.Insert(ctx, map[string][]*gptbot.Chunk{
+		"doc_2": {{ID: "1", DocumentID: "doc_2", Embedding: gptbot.Embedding{1, 0, 0}}},
+	})
+	var e *Error
+	if !errors.As(err, &e) || e.StatusCode != http.StatusBadRequest {
+		t.Errorf("Got err (%v), Want a bad request error", err)
+	}
+}
+
+func TestQdrant_Delete(t *testing.T) {
+	ctx := context.Background()
+	q, s := newFakeQdrant(t, &Config{CollectionName: "test", Dim: 2})
+
+	err := q.Insert(ctx, map[string][]*gptbot.Chunk{
+		"doc_1": {{ID: "1", DocumentID: "doc_1", Embedding: gptbot.Embedding{1, 0}}, {ID: "2", DocumentID: "doc_1", Embedding: gptbot.Embedding{0, 1}}},
+		"doc_2": {{ID: "1", DocumentID: "doc_2", Embedding: gptbot.Embedding{1, 0}}},
+		"doc_3": {{ID: "1", DocumentID: "doc_3", Embedding: gptbot.Embedding{1, 0}}},
+	})
+	if err != nil {
+		t.Fatalf("err: %v\n", err)
+	}
+
+	if err := q.Delete(ctx, "doc_1", "doc_3"); err != nil {
+		t.Fatalf("err: %v\n", err)
+	}
+	similarities, err := q.Query(ctx, gptbot.Embedding{1, 0}, "", 10, nil)
+	if err != nil {
+		t.Fatalf("err: %v\n", err)
+	}
+	if len(similarities) != 1 || similarities[0].DocumentID != "doc_2" {
+		t.Errorf("want only doc_2 left, got %v", similarities)
+	}
+
+	// Delete all.
+	if err := q.Delete(ctx); err != nil {
+		t.Fatalf("err: %v\n", err)
+	}
+	if n := len(s.collections["test"].points); n != 0 {
+		t.Errorf("Got (%d) != Want (%d)", n, 0)
+	}
+}
+
+func TestNewQdrant_APIKey(t *testing.T) {
+	s := &fakeServer{apiKey: "secret", collections: make(map[string]*fakeCollection)}
+	ts := httptest.NewServer(s)
+	defer ts.Close()
+
+	_, err := NewQdrant(&Config{CollectionName: "test", BaseURL: ts.URL, APIKey: "wrong"})
+	var e *Error
+	if !errors.As(err, &e) || e.StatusCode != http.StatusUnauthorized {
+		t.Fatalf("Got err (%v), Want an unauthorized error", err)
+	}
+
+	if _, err := NewQdrant(&Config{CollectionName: "test", BaseURL: ts.URL, APIKey: "secret"}); err != nil {
+		t.Fatalf("err: %v\n", err)
+	}
+}
+
+func TestNewQdrant_CollectionMismatch(t *testing.T) {
+	_, s := newFakeQdrant(t, &Config{CollectionName: "test", Dim: 2})
+	ts := httptest.NewServer(s)
+	defer ts.Close()
+
+	for _, cfg := range []*Config{
+		{CollectionName: "test", BaseURL: ts.URL, Dim: 3},
+		{CollectionName: "test", BaseURL: ts.URL, Dim: 2, Metric: gptbot.MetricCosine},
+	} {
+		if _, err := NewQdrant(cfg); err == nil {
+			t.Errorf("want an error for config %+v", cfg)
+		}
+	}
+
+	// Overwrite the collection.
+	if _, err := NewQdrant(&Config{CollectionName: "test", BaseURL: ts.URL, Dim: 3, CreateNew: true}); err != nil {
+		t.Fatalf("err: %v\n", err)
+	}
+}
+
+func TestBuildCondition(t *testing.T) {
+	tests := []struct {
+		in      *gptbot.Filter
+		want    string
+		wantErr bool
+	}{
+		{
+			in:   gptbot.Eq("product", "X"),
+			want: `{"key":"attributes.product","match":{"value":"X"}}`,
+		},
+		{
+			in:   gptbot.Eq("version", 3),
+			want: `{"key":"attributes.version","range":{"gte":3,"lte":3}}`,
+		},
+		{
+			in:   gptbot.And(gptbot.Lt("version", 3), gptbot.Not(gptbot.Eq("beta", true))),
+			want: `{"must":[{"key":"attributes.version","range":{"lt":3}},{"must_not":[{"key":"attributes.beta","match":{"value":true}}]}]}`,
+		},
+		{
+			in:   gptbot.In("product"),
+			want: `{"must":[{"has_id":[]}]}`,
+		},
+		{
+			in:      gptbot.Gt("date", "2023-01-01"),
+			wantErr: true,
+		},
+		{
+			in:      gptbot.Eq("a.b", "X"),
+			wantErr: true,
+		},
+		{
+			in:      gptbot.Eq("version", []int{1}),
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		cond, err := buildCondition(tt.in)
+		if (err != nil) != tt.wantErr {
+			t.Fatalf("Got err (%v), Want err (%v)", err, tt.wantErr)
+		}
+		if err != nil {
+			if !errors.Is(err, ErrUnsupportedFilter) {
+				t.Errorf("Got err (%v), Want err (%v)", err, ErrUnsupportedFilter)
+			}
+			continue
+		}
+
+		got, err := json.Marshal(cond)
+		if err != nil {
+			t.Fatalf("err: %v\n", err)
+		}
+		if string(got) != tt.want {
+			t.Errorf("Got (%s) != Want (%s)", got, tt.want)
+		}
+	}
+}
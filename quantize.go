@@ -0,0 +1,458 @@
+package gptbot
+
+import (
+	"math"
+	"math/rand"
+
+	"github.com/go-aie/xslices"
+	"golang.org/x/exp/maps"
+	"golang.org/x/exp/slices"
+	"gonum.org/v1/gonum/floats"
+)
+
+type QuantizationType string
+
+const (
+	// QuantizationInt8 quantizes each dimension into an int8 (with a scale
+	// per embedding), which takes 1/8 of the memory of float64.
+	QuantizationInt8 QuantizationType = "int8"
+
+	// QuantizationPQ uses product quantization, which splits each embedding
+	// into subspaces and encodes each subvector as the index (a byte) of its
+	// nearest centroid. It's much more compact than QuantizationInt8 but less
+	// accurate, and the codebooks must be trained first (see TrainSize).
+	QuantizationPQ QuantizationType = "pq"
+)
+
+type QuantizationConfig struct {
+	// Type is the quantization type.
+	// Defaults to QuantizationInt8.
+	Type QuantizationType
+
+	// Subspaces is the number of subspaces for QuantizationPQ, which is also
+	// the size of each code in bytes.
+	// Defaults to 1/8 of the embedding dimension.
+	Subspaces int
+
+	// TrainSize is the number of embeddings used for training the codebooks
+	// of QuantizationPQ, which happens once that many embeddings have been
+	// inserted. Before that, the embeddings are searched in full precision.
+	// Defaults to 1024.
+	TrainSize int
+
+	// RescoreFactor specifies how many top candidates (i.e. RescoreFactor*topK)
+	// found by using the quantized embeddings will be rescored against the
+	// full-precision embeddings, which improves the recall.
+	// Defaults to 0, which means no rescoring.
+	RescoreFactor int
+
+	// Dir is the directory for keeping the full-precision embeddings on disk
+	// (by using memory-mapped files), which are only accessed for rescoring
+	// and persistence, so that the OS can page them out of memory. The store
+	// keeps its own copies of the embeddings there, and the space of deleted
+	// ones is reused.
+	// Defaults to "", which means keeping them in memory.
+	Dir string
+
+	// Seed is the seed for training the codebooks of QuantizationPQ.
+	// Defaults to 1.
+	Seed int64
+}
+
+func (cfg *QuantizationConfig) init() {
+	if cfg.Type == "" {
+		cfg.Type = QuantizationInt8
+	}
+	if cfg.TrainSize == 0 {
+		cfg.TrainSize = 1024
+	}
+	if cfg.Seed == 0 {
+		cfg.Seed = 1
+	}
+}
+
+// quantizedVector is the quantized form of an embedding.
+type quantizedVector struct {
+	// codes are the int8 values for QuantizationInt8, or the centroid indexes
+	// for QuantizationPQ.
+	codes []byte
+
+	// scale is the scale of the int8 values.
+	scale float64
+
+	// norm is the L2 norm of the original embedding.
+	norm float64
+}
+
+// quantizedIndex searches chunks by using the quantized embeddings, with
+// asymmetric distance computation, i.e. the query embedding is not quantized.
+type quantizedIndex struct {
+	cfg    *QuantizationConfig
+	metric Metric
+	arena  *vectorArena
+
+	vectors map[*Chunk]*quantizedVector
+
+	// pq is the product quantizer, which is nil until trained.
+	pq *productQuantizer
+	// pending are the chunks waiting for the codebooks being trained.
+	pending map[*Chunk]bool
+}
+
+func newQuantizedIndex(cfg *QuantizationConfig, metric Metric) *quantizedIndex {
+	cfg.init()
+	idx := &quantizedIndex{
+		cfg:     cfg,
+		metric:  metric,
+		vectors: make(map[*Chunk]*quantizedVector),
+		pending: make(map[*Chunk]bool),
+	}
+	if cfg.Dir != "" {
+		idx.arena = newVectorArena(cfg.Dir)
+	}
+	return idx
+}
+
+// Store returns the chunks to be kept by the index: the copies whose
+// embeddings are stored in the arena if Dir is set, or the chunks themselves
+// otherwise. Either all or none of the chunks are stored.
+func (idx *quantizedIndex) Store(chunks ...*Chunk) ([]*Chunk, error) {
+	if idx.arena == nil {
+		return chunks, nil
+	}
+
+	stored := make([]*Chunk, len(chunks))
+	for i, chunk := range chunks {
+		emb, err := idx.arena.Store(chunk.Embedding)
+		if err != nil {
+			idx.Release(stored[:i]...)
+			return nil, err
+		}
+		c := *chunk
+		c.Embedding = emb
+		stored[i] = &c
+	}
+	return stored, nil
+}
+
+// Release releases the embeddings of the stored chunks in the arena, if any.
+// It's only used for the chunks not inserted, see Delete otherwise.
+func (idx *quantizedIndex) Release(chunks ...*Chunk) {
+	if idx.arena == nil {
+		return
+	}
+	for _, chunk := range chunks {
+		idx.arena.Release(chunk.Embedding)
+	}
+}
+
+// Insert adds the stored chunks (see Store) to the index. Chunks without
+// embeddings are skipped.
+func (idx *quantizedIndex) Insert(chunks ...*Chunk) {
+	for _, chunk := range chunks {
+		switch {
+		case len(chunk.Embedding) == 0:
+			continue
+		case idx.cfg.Type == QuantizationInt8:
+			idx.vectors[chunk] = quantizeInt8(chunk.Embedding)
+		case idx.pq != nil:
+			idx.vectors[chunk] = idx.pq.Encode(chunk.Embedding)
+		default:
+			idx.pending[chunk] = true
+		}
+	}
+
+	if idx.cfg.Type == QuantizationPQ && idx.pq == nil && len(idx.pending) >= idx.cfg.TrainSize {
+		idx.train()
+	}
+}
+
+func (idx *quantizedIndex) train() {
+	// Sort the chunks for deterministic training.
+	chunks := maps.Keys(idx.pending)
+	slices.SortFunc(chunks, func(a, b *Chunk) bool {
+		if a.DocumentID != b.DocumentID {
+			return a.DocumentID < b.DocumentID
+		}
+		return a.ID < b.ID
+	})
+
+	var samples []Embedding
+	for _, chunk := range chunks {
+		samples = append(samples, chunk.Embedding)
+	}
+
+	idx.pq = trainProductQuantizer(samples, idx.cfg.Subspaces, rand.New(rand.NewSource(idx.cfg.Seed)))
+	for chunk := range idx.pending {
+		idx.vectors[chunk] = idx.pq.Encode(chunk.Embedding)
+	}
+	idx.pending = make(map[*Chunk]bool)
+}
+
+// Delete removes the chunks from the index, and releases their embeddings
+// in the arena, if any.
+func (idx *quantizedIndex) Delete(chunks ...*Chunk) {
+	for _, chunk := range chunks {
+		if _, ok := idx.vectors[chunk]; !ok && !idx.pending[chunk] {
+			continue
+		}
+		delete(idx.vectors, chunk)
+		delete(idx.pending, chunk)
+		idx.Release(chunk)
+	}
+}
+
+// Detach returns the chunk to be handed out to the callers, which is a copy
+// with its embedding moved out of the arena if Dir is set, since the arena
+// memory will be reused once the chunk is deleted.
+func (idx *quantizedIndex) Detach(chunk *Chunk) *Chunk {
+	if idx.arena == nil {
+		return chunk
+	}
+	c := *chunk
+	c.Embedding = slices.Clone(chunk.Embedding)
+	return &c
+}
+
+// Search returns the top k chunks, which satisfy match, most similar to q.
+func (idx *quantizedIndex) Search(q Embedding, k int, match func(*Chunk) bool) []*Similarity {
+	n := k
+	if idx.cfg.RescoreFactor > 0 {
+		n = k * idx.cfg.RescoreFactor
+	}
+
+	qNorm := floats.Norm(q, 2)
+	var table [][]float64
+	if idx.pq != nil {
+		table = idx.pq.Table(q, idx.metric)
+	}
+
+	var candidates []*Similarity
+	for chunk, v := range idx.vectors {
+		if !match(chunk) {
+			continue
+		}
+
+		var dot, dist2 float64
+		if idx.pq != nil {
+			dot, dist2 = idx.pq.Distance(table, v, idx.metric)
+		} else {
+			dot = dotInt8(q, v)
+			dist2 = qNorm*qNorm + v.norm*v.norm - 2*dot
+		}
+
+		candidates = append(candidates, &Similarity{
+			Chunk: chunk,
+			Score: quantizedScore(idx.metric, dot, dist2, qNorm, v.norm),
+		})
+	}
+	for chunk := range idx.pending {
+		if match(chunk) {
+			candidates = append(candidates, &Similarity{
+				Chunk: chunk,
+				Score: idx.metric.Score(q, chunk.Embedding),
+			})
+		}
+	}
+
+	candidates = topSimilarities(candidates, n)
+	if n == k {
+		return candidates
+	}
+
+	// Rescore against the full-precision embeddings.
+	for _, s := range candidates {
+		s.Score = idx.metric.Score(q, s.Embedding)
+	}
+	return topSimilarities(candidates, k)
+}
+
+func (idx *quantizedIndex) Close() error {
+	if idx.arena != nil {
+		return idx.arena.Close()
+	}
+	return nil
+}
+
+func quantizedScore(metric Metric, dot, dist2, qNorm, norm float64) float64 {
+	switch metric {
+	case MetricCosine:
+		if qNorm == 0 || norm == 0 {
+			return 0
+		}
+		return dot / (qNorm * norm)
+	case MetricL2:
+		return L2Score(math.Sqrt(math.Max(dist2, 0)))
+	case MetricDotProduct:
+		return dot
+	default:
+		panic(metric.Validate())
+	}
+}
+
+func quantizeInt8(e Embedding) *quantizedVector {
+	v := &quantizedVector{
+		codes: make([]byte, len(e)),
+		norm:  floats.Norm(e, 2),
+	}
+
+	if len(e) == 0 {
+		return v
+	}
+	maxAbs := math.Max(floats.Max(e), -floats.Min(e))
+	if maxAbs == 0 {
+		return v
+	}
+
+	v.scale = maxAbs / 127
+	for i, x := range e {
+		v.codes[i] = byte(int8(math.Round(x / v.scale)))
+	}
+	return v
+}
+
+// dotInt8 returns the approximate dot product between q and the int8 vector.
+func dotInt8(q Embedding, v *quantizedVector) float64 {
+	var sum float64
+	for i, c := range v.codes {
+		sum += q[i] * float64(int8(c))
+	}
+	return sum * v.scale
+}
+
+// productQuantizer is a trained product quantizer with up to 256 centroids
+// per subspace.
+type productQuantizer struct {
+	// bounds[j] and bounds[j+1] are the start and end dimensions of subspace j.
+	bounds []int
+	// centroids[j] are the centroids of subspace j.
+	centroids [][]Embedding
+}
+
+const pqIterations = 20
+
+func trainProductQuantizer(samples []Embedding, subspaces int, r *rand.Rand) *productQuantizer {
+	dim := len(samples[0])
+	if subspaces <= 0 {
+		subspaces = xslices.Max(dim/8, 1)
+	}
+	subspaces = xslices.Min(subspaces, dim)
+
+	pq := &productQuantizer{
+		bounds:    make([]int, subspaces+1),
+		centroids: make([][]Embedding, subspaces),
+	}
+	for j := range pq.bounds {
+		pq.bounds[j] = j * dim / subspaces
+	}
+
+	k := xslices.Min(256, len(samples))
+	for j := 0; j < subspaces; j++ {
+		subs := make([]Embedding, len(samples))
+		for i, s := range samples {
+			subs[i] = s[pq.bounds[j]:pq.bounds[j+1]]
+		}
+		pq.centroids[j] = kmeans(subs, k, pqIterations, r)
+	}
+	return pq
+}
+
+func (pq *productQuantizer) Encode(e Embedding) *quantizedVector {
+	v := &quantizedVector{
+		codes: make([]byte, len(pq.centroids)),
+		norm:  floats.Norm(e, 2),
+	}
+	for j, centroids := range pq.centroids {
+		v.codes[j] = byte(nearest(e[pq.bounds[j]:pq.bounds[j+1]], centroids))
+	}
+	return v
+}
+
+// Table precomputes the distances between the subvectors of q and all the
+// centroids: the dot products for MetricDotProduct and MetricCosine, and the
+// squared Euclidean distances for MetricL2.
+func (pq *productQuantizer) Table(q Embedding, metric Metric) [][]float64 {
+	table := make([][]float64, len(pq.centroids))
+	for j, centroids := range pq.centroids {
+		sub := q[pq.bounds[j]:pq.bounds[j+1]]
+		table[j] = make([]float64, len(centroids))
+		for c, centroid := range centroids {
+			if metric == MetricL2 {
+				d := floats.Distance(sub, centroid, 2)
+				table[j][c] = d * d
+			} else {
+				table[j][c] = floats.Dot(sub, centroid)
+			}
+		}
+	}
+	return table
+}
+
+// Distance returns the approximate dot product (if metric is not MetricL2)
+// or squared Euclidean distance (if metric is MetricL2) by using the table.
+func (pq *productQuantizer) Distance(table [][]float64, v *quantizedVector, metric Metric) (dot, dist2 float64) {
+	var sum float64
+	for j, c := range v.codes {
+		sum += table[j][c]
+	}
+	if metric == MetricL2 {
+		return 0, sum
+	}
+	return sum, 0
+}
+
+// kmeans clusters the points into k clusters by using Lloyd's algorithm,
+// and returns the centroids.
+func kmeans(points []Embedding, k, iterations int, r *rand.Rand) []Embedding {
+	centroids := make([]Embedding, k)
+	for i, p := range r.Perm(len(points))[:k] {
+		centroids[i] = append(Embedding(nil), points[p]...)
+	}
+
+	assignments := make([]int, len(points))
+	for it := 0; it < iterations; it++ {
+		changed := false
+		for i, p := range points {
+			if c := nearest(p, centroids); it == 0 || c != assignments[i] {
+				assignments[i] = c
+				changed = true
+			}
+		}
+		if !changed {
+			break
+		}
+
+		counts := make([]int, k)
+		sums := make([]Embedding, k)
+		for c := range sums {
+			sums[c] = make(Embedding, len(centroids[c]))
+		}
+		for i, p := range points {
+			floats.Add(sums[assignments[i]], p)
+			counts[assignments[i]]++
+		}
+		for c := range centroids {
+			// Keep the old centroid for empty clusters.
+			if counts[c] > 0 {
+				floats.ScaleTo(centroids[c], 1/float64(counts[c]), sums[c])
+			}
+		}
+	}
+	return centroids
+}
+
+// nearest returns the index of the centroid nearest to p in Euclidean distance.
+func nearest(p Embedding, centroids []Embedding) int {
+	best, bestDist := 0, math.Inf(1)
+	for c, centroid := range centroids {
+		var d float64
+		for i, x := range p {
+			diff := x - centroid[i]
+			d += diff * diff
+		}
+		if d < bestDist {
+			best, bestDist = c, d
+		}
+	}
+	return best
+}
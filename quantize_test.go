@@ -0,0 +1,223 @@
+package gptbot_test
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"runtime"
+	"testing"
+
+	"github.com/go-aie/gptbot"
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestLocalVectorStore_Quantization(t *testing.T) {
+	const (
+		n, dim, docs = 2000, 32, 100
+		queries      = 50
+		topK         = 10
+	)
+
+	exact := gptbot.NewLocalVectorStore()
+	_ = exact.Insert(context.Background(), randomChunks(rand.New(rand.NewSource(1)), n, dim, docs))
+
+	tests := []struct {
+		name      string
+		cfg       *gptbot.QuantizationConfig
+		minRecall float64
+	}{
+		{
+			name:      "int8",
+			cfg:       &gptbot.QuantizationConfig{},
+			minRecall: 0.9,
+		},
+		{
+			name:      "int8 with rescoring",
+			cfg:       &gptbot.QuantizationConfig{RescoreFactor: 2, Dir: t.TempDir()},
+			minRecall: 0.99,
+		},
+		{
+			name:      "pq with rescoring",
+			cfg:       &gptbot.QuantizationConfig{Type: gptbot.QuantizationPQ, Subspaces: 8, RescoreFactor: 10, TrainSize: 1000},
+			minRecall: 0.8,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store := gptbot.NewLocalVectorStoreWithConfig(&gptbot.LocalVectorStoreConfig{
+				Quantization: tt.cfg,
+			})
+			defer store.Close()
+			_ = store.Insert(context.Background(), randomChunks(rand.New(rand.NewSource(1)), n, dim, docs))
+
+			r := rand.New(rand.NewSource(2))
+			var total float64
+			for i := 0; i < queries; i++ {
+				q := randomEmbedding(r, dim)
+				want, _ := exact.Query(context.Background(), q, "", topK, nil)
+				got, err := store.Query(context.Background(), q, "", topK, nil)
+				if err != nil {
+					t.Fatalf("err: %v\n", err)
+				}
+				if len(got) != topK {
+					t.Fatalf("Got (%d) != Want (%d) similarities", len(got), topK)
+				}
+				total += recallByID(want, got)
+			}
+			if got := total / queries; got < tt.minRecall {
+				t.Errorf("Got recall (%v) < Want (%v)", got, tt.minRecall)
+			}
+
+			// The full-precision embeddings are still available.
+			data := store.GetAllData(context.Background())
+			if got := len(data["doc_0"][0].Embedding); got != dim {
+				t.Errorf("Got dimension (%d) != Want (%d)", got, dim)
+			}
+		})
+	}
+}
+
+func TestLocalVectorStore_QuantizationDir(t *testing.T) {
+	ctx := context.Background()
+	store := gptbot.NewLocalVectorStoreWithConfig(&gptbot.LocalVectorStoreConfig{
+		Quantization: &gptbot.QuantizationConfig{RescoreFactor: 2, Dir: t.TempDir()},
+	})
+	defer store.Close()
+
+	chunk := &gptbot.Chunk{ID: "1", DocumentID: "doc_1", Embedding: gptbot.Embedding{1, 0}}
+	embedding := chunk.Embedding
+	if err := store.Insert(ctx, map[string][]*gptbot.Chunk{"doc_1": {chunk}}); err != nil {
+		t.Fatalf("err: %v\n", err)
+	}
+
+	// The inserted chunk is left untouched.
+	if &chunk.Embedding[0] != &embedding[0] {
+		t.Errorf("want the embedding of the inserted chunk to be kept")
+	}
+
+	got, err := store.Query(ctx, gptbot.Embedding{1, 0}, "", 1, nil)
+	if err != nil {
+		t.Fatalf("err: %v\n", err)
+	}
+
+	// Replace the document, whose space is reused by the new embedding.
+	if err := store.Delete(ctx, "doc_1"); err != nil {
+		t.Fatalf("err: %v\n", err)
+	}
+	if err := store.Insert(ctx, map[string][]*gptbot.Chunk{
+		"doc_2": {{ID: "1", DocumentID: "doc_2", Embedding: gptbot.Embedding{0, 1}}},
+	}); err != nil {
+		t.Fatalf("err: %v\n", err)
+	}
+
+	// The returned chunks are not affected.
+	if !cmp.Equal(got[0].Embedding, gptbot.Embedding{1, 0}) {
+		diff := cmp.Diff(got[0].Embedding, gptbot.Embedding{1, 0})
+		t.Errorf("Want - Got: %s", diff)
+	}
+
+	got, err = store.Query(ctx, gptbot.Embedding{0, 1}, "", 1, nil)
+	if err != nil {
+		t.Fatalf("err: %v\n", err)
+	}
+	if len(got) != 1 || got[0].DocumentID != "doc_2" || !cmp.Equal(got[0].Embedding, gptbot.Embedding{0, 1}) {
+		t.Errorf("Got (%+v), Want the chunk of doc_2", got)
+	}
+}
+
+// recallByID is like recall, but compares chunks by IDs instead of pointers.
+func recallByID(exact, approx []*gptbot.Similarity) float64 {
+	found := make(map[string]bool)
+	for _, s := range approx {
+		found[s.ID] = true
+	}
+	var hits int
+	for _, s := range exact {
+		if found[s.ID] {
+			hits++
+		}
+	}
+	return float64(hits) / float64(len(exact))
+}
+
+// BenchmarkLocalVectorStore_Quantization reports the heap memory per chunk
+// and the recall of different quantization modes.
+func BenchmarkLocalVectorStore_Quantization(b *testing.B) {
+	const (
+		n, dim, docs = 10000, 256, 1000
+		queries      = 100
+		topK         = 10
+	)
+
+	r := rand.New(rand.NewSource(1))
+	qs := make([]gptbot.Embedding, queries)
+	for i := range qs {
+		qs[i] = randomEmbedding(r, dim)
+	}
+
+	exact := gptbot.NewLocalVectorStore()
+	_ = exact.Insert(context.Background(), randomChunks(rand.New(rand.NewSource(1)), n, dim, docs))
+	want := make([][]*gptbot.Similarity, queries)
+	for i, q := range qs {
+		want[i], _ = exact.Query(context.Background(), q, "", topK, nil)
+	}
+
+	dir := b.TempDir()
+	tests := []struct {
+		name string
+		cfg  *gptbot.QuantizationConfig
+	}{
+		{name: "none"},
+		{name: "int8", cfg: &gptbot.QuantizationConfig{Dir: dir}},
+		{name: "int8-rescore", cfg: &gptbot.QuantizationConfig{Dir: dir, RescoreFactor: 4}},
+		{name: "pq", cfg: &gptbot.QuantizationConfig{Dir: dir, Type: gptbot.QuantizationPQ}},
+		{name: "pq-rescore", cfg: &gptbot.QuantizationConfig{Dir: dir, Type: gptbot.QuantizationPQ, RescoreFactor: 10}},
+	}
+	for _, tt := range tests {
+		b.Run(tt.name, func(b *testing.B) {
+			// Measure the heap memory used by the chunks and the store, which
+			// excludes the full-precision embeddings if they are kept on disk.
+			before := heapAlloc()
+			store := gptbot.NewLocalVectorStoreWithConfig(&gptbot.LocalVectorStoreConfig{
+				Quantization: tt.cfg,
+			})
+			defer store.Close()
+			_ = store.Insert(context.Background(), randomChunks(rand.New(rand.NewSource(1)), n, dim, docs))
+			used := heapAlloc() - before
+
+			b.ResetTimer()
+			var total float64
+			for i := 0; i < b.N; i++ {
+				got, _ := store.Query(context.Background(), qs[i%queries], "", topK, nil)
+				total += recallByID(want[i%queries], got)
+			}
+			b.ReportMetric(total/float64(b.N), "recall")
+			b.ReportMetric(float64(used)/n, "heap-bytes/chunk")
+		})
+	}
+}
+
+func heapAlloc() int64 {
+	runtime.GC()
+	var m runtime.MemStats
+	runtime.ReadMemStats(&m)
+	return int64(m.HeapAlloc)
+}
+
+func ExampleQuantizationConfig() {
+	store := gptbot.NewLocalVectorStoreWithConfig(&gptbot.LocalVectorStoreConfig{
+		Quantization: &gptbot.QuantizationConfig{
+			Type:          gptbot.QuantizationInt8,
+			RescoreFactor: 4,
+		},
+	})
+	_ = store.Insert(context.Background(), map[string][]*gptbot.Chunk{
+		"doc_1": {{ID: "1", DocumentID: "doc_1", Embedding: gptbot.Embedding{0.6, 0.8}}},
+	})
+
+	sims, _ := store.Query(context.Background(), gptbot.Embedding{0.6, 0.8}, "", 1, nil)
+	fmt.Printf("%s %.2f\n", sims[0].ID, sims[0].Score)
+
+	// Output:
+	// 1 1.00
+}
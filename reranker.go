@@ -0,0 +1,220 @@
+package gptbot
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"golang.org/x/exp/slices"
+)
+
+// Reranker reorders and/or filters the similarities retrieved for the question.
+// The returned similarities should be sorted by relevance in descending order,
+// and their scores may be updated by the reranker accordingly.
+type Reranker interface {
+	Rerank(ctx context.Context, question string, similarities []*Similarity) ([]*Similarity, error)
+}
+
+// LLMReranker is a reranker, which asks the LLM to score the relevance of each
+// similarity to the question.
+type LLMReranker struct {
+	// Engine is the LLM engine for scoring.
+	// Defaults to BotConfig.Engine if used by Bot.
+	Engine Engine
+
+	// PromptTmpl specifies a custom prompt template for scoring.
+	// Defaults to DefaultRerankPromptTmpl.
+	PromptTmpl string
+
+	// MinScore specifies the minimum relevance score, from 0 to 10, of the
+	// similarities to keep. Defaults to 0, which means no filtering.
+	MinScore float64
+}
+
+func NewLLMReranker(engine Engine) *LLMReranker {
+	return &LLMReranker{Engine: engine}
+}
+
+// Rerank implements Reranker. The similarities will be scored by the LLM and
+// sorted by their new scores. Similarities not scored by the LLM (e.g. due to an
+// unexpected response) are kept in the original order, after the scored ones.
+func (r *LLMReranker) Rerank(ctx context.Context, question string, similarities []*Similarity) ([]*Similarity, error) {
+	if len(similarities) == 0 {
+		return nil, nil
+	}
+
+	tmpl := r.PromptTmpl
+	if tmpl == "" {
+		tmpl = DefaultRerankPromptTmpl
+	}
+
+	var texts []string
+	for i, s := range similarities {
+		texts = append(texts, fmt.Sprintf("[%d] %s", i+1, s.Text))
+	}
+	prompt, err := PromptTemplate(tmpl).Render(PromptData{
+		Question: question,
+		Sections: texts,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := r.Engine.Infer(ctx, &EngineRequest{
+		Messages: []*EngineMessage{{Role: "user", Content: prompt}},
+		// We want the scores to be as deterministic as possible.
+		Temperature: 0,
+		MaxTokens:   10 * len(similarities),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	scores := parseRerankScores(resp.Text)
+
+	var scored, unscored []*Similarity
+	for i, s := range similarities {
+		score, ok := scores[i+1]
+		if !ok {
+			unscored = append(unscored, s)
+			continue
+		}
+		if score < r.MinScore {
+			continue
+		}
+		scored = append(scored, &Similarity{
+			Chunk: s.Chunk,
+			Score: score,
+		})
+	}
+
+	slices.SortStableFunc(scored, func(a, b *Similarity) bool {
+		return a.Score > b.Score
+	})
+	return append(scored, unscored...), nil
+}
+
+var rerankScoreRegexp = regexp.MustCompile(`(?m)^\s*\[?(\d+)\]?\s*:\s*(\d+(?:\.\d+)?)`)
+
+// parseRerankScores parses the scores (e.g. "[1]: 8") from the LLM response.
+func parseRerankScores(text string) map[int]float64 {
+	scores := make(map[int]float64)
+	for _, match := range rerankScoreRegexp.FindAllStringSubmatch(text, -1) {
+		i, err := strconv.Atoi(match[1])
+		if err != nil {
+			continue
+		}
+		score, err := strconv.ParseFloat(match[2], 64)
+		if err != nil {
+			continue
+		}
+		scores[i] = score
+	}
+	return scores
+}
+
+// LexicalReranker is a reranker, which scores each similarity by the lexical
+// overlap between its text and the question. It works offline.
+type LexicalReranker struct {
+	// MinOverlap specifies the minimum overlap, between 0 and 1, of the
+	// similarities to keep. The overlap is the fraction of the question terms
+	// found in the text. Defaults to 0, which means no filtering.
+	MinOverlap float64
+}
+
+func NewLexicalReranker() *LexicalReranker {
+	return &LexicalReranker{}
+}
+
+// Rerank implements Reranker. The similarities will be sorted by their overlaps,
+// and similarities with the same overlap are kept in the original order.
+func (r *LexicalReranker) Rerank(ctx context.Context, question string, similarities []*Similarity) ([]*Similarity, error) {
+	questionTerms := make(map[string]bool)
+	for _, t := range terms(question) {
+		questionTerms[t] = true
+	}
+	if len(questionTerms) == 0 {
+		return similarities, nil
+	}
+
+	var result []*Similarity
+	for _, s := range similarities {
+		found := make(map[string]bool)
+		for _, t := range terms(s.Text) {
+			if questionTerms[t] {
+				found[t] = true
+			}
+		}
+
+		overlap := float64(len(found)) / float64(len(questionTerms))
+		if overlap < r.MinOverlap {
+			continue
+		}
+		result = append(result, &Similarity{
+			Chunk: s.Chunk,
+			Score: overlap,
+		})
+	}
+
+	slices.SortStableFunc(result, func(a, b *Similarity) bool {
+		return a.Score > b.Score
+	})
+	return result, nil
+}
+
+// terms splits the text into lowercase terms, with stop words removed.
+// Each CJK character is treated as a separate term.
+func terms(text string) []string {
+	var result []string
+	var b strings.Builder
+
+	flush := func() {
+		if b.Len() == 0 {
+			return
+		}
+		if t := b.String(); !stopWords[t] {
+			result = append(result, t)
+		}
+		b.Reset()
+	}
+
+	for _, r := range strings.ToLower(text) {
+		switch {
+		case unicode.In(r, unicode.Han, unicode.Hiragana, unicode.Katakana, unicode.Hangul):
+			flush()
+			result = append(result, string(r))
+		case unicode.IsLetter(r) || unicode.IsDigit(r):
+			b.WriteRune(r)
+		default:
+			flush()
+		}
+	}
+	flush()
+
+	return result
+}
+
+var stopWords = map[string]bool{
+	"a": true, "an": true, "and": true, "are": true, "as": true, "at": true,
+	"be": true, "by": true, "did": true, "do": true, "does": true, "for": true,
+	"from": true, "how": true, "in": true, "is": true, "it": true, "of": true,
+	"on": true, "or": true, "that": true, "the": true, "this": true, "to": true,
+	"was": true, "were": true, "what": true, "when": true, "where": true,
+	"which": true, "who": true, "why": true, "with": true,
+}
+
+const DefaultRerankPromptTmpl = `Rate how relevant each of the following passages is to the question, on a scale from 0 (irrelevant) to 10 (highly relevant).
+Respond with one line per passage in the format "[number]: score", and nothing else.
+
+Question: {{.Question}}
+
+Passages:
+
+{{range .Sections -}}
+{{.}}
+{{end}}
+Scores:
+`
@@ -0,0 +1,66 @@
+package gptbot_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/go-aie/gptbot"
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestLLMReranker_Rerank(t *testing.T) {
+	similarities := []*gptbot.Similarity{
+		{Chunk: &gptbot.Chunk{ID: "1", Text: "GPT-3 is an AI model."}, Score: 0.9},
+		{Chunk: &gptbot.Chunk{ID: "2", Text: "GPT-3 was released in 2020."}, Score: 0.8},
+		{Chunk: &gptbot.Chunk{ID: "3", Text: "Apples are red."}, Score: 0.7},
+		{Chunk: &gptbot.Chunk{ID: "4", Text: "GPT-3 uses 175 billion parameters."}, Score: 0.6},
+	}
+
+	engine := &fakeEngine{answers: []string{"[1]: 5\n[2]: 9\n[3]: 1"}}
+	r := gptbot.NewLLMReranker(engine)
+	r.MinScore = 2
+
+	got, err := r.Rerank(context.Background(), "When was GPT-3 released?", similarities)
+	if err != nil {
+		t.Fatalf("err: %v\n", err)
+	}
+
+	want := []*gptbot.Similarity{
+		{Chunk: similarities[1].Chunk, Score: 9},
+		{Chunk: similarities[0].Chunk, Score: 5},
+		// Not scored by the LLM.
+		{Chunk: similarities[3].Chunk, Score: 0.6},
+	}
+	if !cmp.Equal(got, want) {
+		diff := cmp.Diff(got, want)
+		t.Errorf("Want - Got: %s", diff)
+	}
+}
+
+func TestLexicalReranker_Rerank(t *testing.T) {
+	similarities := []*gptbot.Similarity{
+		{Chunk: &gptbot.Chunk{ID: "1", Text: "GPT-3 is an AI model."}, Score: 0.9},
+		{Chunk: &gptbot.Chunk{ID: "2", Text: "GPT-3 was released in 2020."}, Score: 0.8},
+		{Chunk: &gptbot.Chunk{ID: "3", Text: "Apples are red."}, Score: 0.7},
+		{Chunk: &gptbot.Chunk{ID: "4", Text: "生成型预训练变换模型 3（GPT-3）"}, Score: 0.6},
+	}
+
+	r := gptbot.NewLexicalReranker()
+	r.MinOverlap = 0.2
+
+	// Question terms: "gpt", "3", "released", "模", "型".
+	got, err := r.Rerank(context.Background(), "When was GPT-3 released? 模型", similarities)
+	if err != nil {
+		t.Fatalf("err: %v\n", err)
+	}
+
+	want := []*gptbot.Similarity{
+		{Chunk: similarities[3].Chunk, Score: 0.8},
+		{Chunk: similarities[1].Chunk, Score: 0.6},
+		{Chunk: similarities[0].Chunk, Score: 0.4},
+	}
+	if !cmp.Equal(got, want) {
+		diff := cmp.Diff(got, want)
+		t.Errorf("Want - Got: %s", diff)
+	}
+}
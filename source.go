@@ -0,0 +1,68 @@
+package gptbot
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Source is a document chunk, which is used to generate the answer.
+type Source struct {
+	// Index is the 1-based number of the section in the prompt.
+	Index int `json:"index,omitempty"`
+
+	ChunkID    string  `json:"chunk_id,omitempty"`
+	DocumentID string  `json:"document_id,omitempty"`
+	CorpusID   string  `json:"corpus_id,omitempty"`
+	Score      float64 `json:"score,omitempty"`
+
+	// Cited reports whether the source is cited in the answer.
+	// It's only available if citation is enabled.
+	Cited bool `json:"cited,omitempty"`
+}
+
+func newSources(similarities []*Similarity) []*Source {
+	var sources []*Source
+	for i, s := range similarities {
+		sources = append(sources, &Source{
+			Index:      i + 1,
+			ChunkID:    s.ID,
+			DocumentID: s.DocumentID,
+			CorpusID:   s.Metadata.CorpusID,
+			Score:      s.Score,
+		})
+	}
+	return sources
+}
+
+// sources returns the sources of the given answer. If citation is enabled,
+// the sources cited in the answer will be marked accordingly.
+func (b *Bot) sources(p *chatPrompt, answer string) []*Source {
+	sources := newSources(p.Similarities)
+	if !b.cfg.Citation {
+		return sources
+	}
+
+	for _, i := range parseCitations(answer) {
+		if i >= 1 && i <= len(sources) {
+			sources[i-1].Cited = true
+		}
+	}
+	return sources
+}
+
+var citationRegexp = regexp.MustCompile(`\[(\d+(?:\s*,\s*\d+)*)\]`)
+
+// parseCitations returns the section numbers cited in the answer (e.g. "[1]" or "[1, 2]").
+func parseCitations(answer string) []int {
+	var nums []int
+	for _, match := range citationRegexp.FindAllStringSubmatch(answer, -1) {
+		for _, s := range strings.Split(match[1], ",") {
+			n, err := strconv.Atoi(strings.TrimSpace(s))
+			if err == nil {
+				nums = append(nums, n)
+			}
+		}
+	}
+	return nums
+}
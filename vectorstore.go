@@ -3,21 +3,100 @@ package gptbot
 import (
 	"context"
 	"encoding/json"
+	"fmt"
 	"os"
+	"sync"
 
+	"github.com/go-aie/xslices"
 	"golang.org/x/exp/maps"
 	"golang.org/x/exp/slices"
-	"gonum.org/v1/gonum/mat"
 )
 
+type LocalVectorStoreConfig struct {
+	// Metric is the metric used for measuring the similarity between embeddings.
+	// Defaults to MetricDotProduct.
+	Metric Metric
+
+	// Normalize specifies whether to normalize embeddings to unit length on insert.
+	// The store keeps normalized copies, so the inserted chunks are left untouched.
+	Normalize bool
+
+	// HNSW enables the HNSW index for approximate nearest neighbor search,
+	// which is much faster than brute-force search for large amounts of chunks,
+	// at the cost of memory and (slightly) lower recall.
+	// Defaults to nil, which means brute-force search.
+	HNSW *HNSWConfig
+
+	// Quantization enables the quantization of embeddings, which makes the
+	// brute-force search faster and (with QuantizationConfig.Dir) reduces the
+	// memory usage, at the cost of lower recall.
+	// Note that it's not used for searching if HNSW is enabled.
+	// Defaults to nil, which means no quantization.
+	Quantization *QuantizationConfig
+
+	// WAL enables the durable mode, see OpenLocalVectorStore.
+	// It's ignored by NewLocalVectorStoreWithConfig.
+	WAL *WALConfig
+}
+
+func (cfg *LocalVectorStoreConfig) init() {
+	if cfg.Metric == "" {
+		cfg.Metric = MetricDotProduct
+	}
+}
+
+// LocalVectorStore is an in-memory vector store. It's safe for concurrent use
+// by multiple goroutines, and each query sees a consistent snapshot of the
+// chunks, i.e. it's never interleaved with an insertion or a deletion.
 type LocalVectorStore struct {
+	cfg *LocalVectorStoreConfig
+
+	// mu protects all the fields below.
+	mu     sync.RWMutex
 	chunks map[string][]*Chunk
+
+	// dim is the embedding dimension, which is fixed by the first insertion.
+	dim int
+
+	// index is the BM25 index for keyword search.
+	index *bm25Index
+
+	// hnsw is the optional HNSW index for vector search.
+	hnsw *hnswIndex
+
+	// quant is the optional index of the quantized embeddings.
+	quant *quantizedIndex
+
+	// unmaps are the functions for unmapping the memory-mapped files.
+	unmaps []func() error
+
+	// wal is the write-ahead log in the durable mode.
+	wal *wal
 }
 
 func NewLocalVectorStore() *LocalVectorStore {
-	return &LocalVectorStore{
+	return NewLocalVectorStoreWithConfig(&LocalVectorStoreConfig{})
+}
+
+// NewLocalVectorStoreWithConfig creates an in-memory vector store with the
+// given configuration. It panics if cfg.Metric is not supported.
+func NewLocalVectorStoreWithConfig(cfg *LocalVectorStoreConfig) *LocalVectorStore {
+	cfg.init()
+	if err := cfg.Metric.Validate(); err != nil {
+		panic(err)
+	}
+	vs := &LocalVectorStore{
+		cfg:    cfg,
 		chunks: make(map[string][]*Chunk),
+		index:  newBM25Index(),
+	}
+	if cfg.HNSW != nil {
+		vs.hnsw = newHNSWIndex(cfg.HNSW, cfg.Metric)
+	}
+	if cfg.Quantization != nil {
+		vs.quant = newQuantizedIndex(cfg.Quantization, cfg.Metric)
 	}
+	return vs
 }
 
 // LoadJSON will deserialize from disk into a `LocalVectorStore` based on the provided filename.
@@ -44,11 +123,13 @@ func (vs *LocalVectorStore) LoadJSON(ctx context.Context, filename string) error
 func (vs *LocalVectorStore) StoreJSON(filename string) error {
 	var chunks []*Chunk
 
+	vs.mu.RLock()
 	for _, chunk := range vs.chunks {
 		chunks = append(chunks, chunk...)
 	}
-
 	b, err := json.Marshal(chunks)
+	vs.mu.RUnlock()
+
 	if err != nil {
 		return err
 	}
@@ -61,30 +142,292 @@ func (vs *LocalVectorStore) StoreJSON(filename string) error {
 	return nil
 }
 
-// GetAllData returns all the internal data. It is mainly used for testing purpose.
+// GetAllData returns a copy of all the internal data. It is mainly used for testing purpose.
 func (vs *LocalVectorStore) GetAllData(ctx context.Context) map[string][]*Chunk {
-	return vs.chunks
+	vs.mu.RLock()
+	defer vs.mu.RUnlock()
+
+	data := make(map[string][]*Chunk, len(vs.chunks))
+	for documentID, chunks := range vs.chunks {
+		data[documentID] = vs.detachChunks(chunks)
+	}
+	return data
+}
+
+// ListDocuments implements Lister.
+func (vs *LocalVectorStore) ListDocuments(ctx context.Context, corpusID string, offset, limit int) ([]*DocumentInfo, int, error) {
+	vs.mu.RLock()
+	defer vs.mu.RUnlock()
+
+	documents := vs.documents(corpusID)
+	return Paginate(documents, offset, limit), len(documents), nil
+}
+
+// GetDocument implements Lister.
+func (vs *LocalVectorStore) GetDocument(ctx context.Context, documentID string) ([]*Chunk, error) {
+	vs.mu.RLock()
+	defer vs.mu.RUnlock()
+
+	chunks, ok := vs.chunks[documentID]
+	if !ok {
+		return nil, ErrDocumentNotFound
+	}
+	return vs.detachChunks(chunks), nil
+}
+
+// Stats implements Lister.
+func (vs *LocalVectorStore) Stats(ctx context.Context) (*Stats, error) {
+	vs.mu.RLock()
+	defer vs.mu.RUnlock()
+
+	return NewStats(vs.documents("")), nil
+}
+
+// ListCorpora implements CorpusManager.
+func (vs *LocalVectorStore) ListCorpora(ctx context.Context) ([]string, error) {
+	vs.mu.RLock()
+	defer vs.mu.RUnlock()
+
+	return CorpusIDs(vs.documents("")), nil
+}
+
+// DeleteCorpus implements CorpusManager.
+func (vs *LocalVectorStore) DeleteCorpus(ctx context.Context, corpusID string) error {
+	if corpusID == "" {
+		return ErrEmptyCorpusID
+	}
+
+	vs.mu.Lock()
+	defer vs.mu.Unlock()
+
+	var documentIDs []string
+	for _, doc := range vs.documents(corpusID) {
+		documentIDs = append(documentIDs, doc.ID)
+	}
+	if len(documentIDs) == 0 {
+		// Never fall into deleting all chunks.
+		return nil
+	}
+
+	if vs.wal != nil {
+		if err := vs.wal.AppendDelete(documentIDs); err != nil {
+			return err
+		}
+	}
+	vs.delete(documentIDs...)
+	vs.maybeCompact()
+	return nil
+}
+
+// CorpusStats implements CorpusManager.
+func (vs *LocalVectorStore) CorpusStats(ctx context.Context, corpusID string) (*Stats, error) {
+	vs.mu.RLock()
+	defer vs.mu.RUnlock()
+
+	return NewStats(vs.documents(corpusID)), nil
 }
 
+// documents returns the documents sorted by IDs, which are restricted to
+// the given corpus if corpusID is not empty.
+func (vs *LocalVectorStore) documents(corpusID string) []*DocumentInfo {
+	documentIDs := maps.Keys(vs.chunks)
+	slices.Sort(documentIDs)
+
+	var documents []*DocumentInfo
+	for _, documentID := range documentIDs {
+		chunks := vs.chunks[documentID]
+		doc := &DocumentInfo{
+			ID:       documentID,
+			ChunkNum: len(chunks),
+		}
+		if len(chunks) > 0 {
+			doc.CorpusID = chunks[0].Metadata.CorpusID
+		}
+		if corpusID == "" || doc.CorpusID == corpusID {
+			documents = append(documents, doc)
+		}
+	}
+	return documents
+}
+
+// Metric returns the metric used for measuring the similarity between embeddings.
+func (vs *LocalVectorStore) Metric() Metric {
+	return vs.cfg.Metric
+}
+
+// Insert inserts the chunks into the store. All the embeddings must have the
+// same dimension, which is fixed by the first insertion, and the queries must
+// use embeddings of the same dimension. Chunks without embeddings are only
+// searchable by keyword search.
 func (vs *LocalVectorStore) Insert(ctx context.Context, chunks map[string][]*Chunk) error {
+	vs.mu.Lock()
+	defer vs.mu.Unlock()
+
+	// Prepare the chunks before logging, so that a failed insertion is never
+	// logged, which would otherwise fail the replay as well.
+	prepared, err := vs.prepare(chunks)
+	if err != nil {
+		return err
+	}
+	if vs.wal != nil {
+		if err := vs.wal.AppendInsert(chunks); err != nil {
+			vs.discard(prepared)
+			return err
+		}
+	}
+	vs.apply(prepared)
+	vs.maybeCompact()
+	return nil
+}
+
+// insert inserts the chunks. Either all or none of them are inserted.
+func (vs *LocalVectorStore) insert(chunks map[string][]*Chunk) error {
+	prepared, err := vs.prepare(chunks)
+	if err != nil {
+		return err
+	}
+	vs.apply(prepared)
+	return nil
+}
+
+// prepare returns the chunks to be kept by the store, which may be copies of
+// the given ones (e.g. with normalized embeddings). It's the only step of
+// an insertion that can fail, and it leaves the store unchanged.
+func (vs *LocalVectorStore) prepare(chunks map[string][]*Chunk) (map[string][]*Chunk, error) {
+	// Chunks without embeddings are allowed, which are only searchable
+	// by keyword search.
+	dim := vs.dim
+	for _, chunkList := range chunks {
+		for _, chunk := range chunkList {
+			if len(chunk.Embedding) == 0 {
+				continue
+			}
+			if dim == 0 {
+				dim = len(chunk.Embedding)
+			}
+			if err := checkDim(chunk.Embedding, dim); err != nil {
+				return nil, fmt.Errorf("chunk %q: %w", chunk.ID, err)
+			}
+		}
+	}
+
+	prepared := make(map[string][]*Chunk, len(chunks))
 	for documentID, chunkList := range chunks {
+		if vs.cfg.Normalize {
+			chunkList = normalizeChunks(chunkList)
+		}
+		if vs.quant != nil {
+			var err error
+			if chunkList, err = vs.quant.Store(chunkList...); err != nil {
+				vs.discard(prepared)
+				return nil, err
+			}
+		}
+		prepared[documentID] = chunkList
+	}
+	return prepared, nil
+}
+
+// discard releases the resources held by the prepared chunks, which will
+// not be applied.
+func (vs *LocalVectorStore) discard(prepared map[string][]*Chunk) {
+	if vs.quant != nil {
+		for _, chunkList := range prepared {
+			vs.quant.Release(chunkList...)
+		}
+	}
+}
+
+// apply inserts the prepared chunks into the store and all the indexes.
+func (vs *LocalVectorStore) apply(prepared map[string][]*Chunk) {
+	for documentID, chunkList := range prepared {
+		for _, chunk := range chunkList {
+			if vs.dim == 0 {
+				vs.dim = len(chunk.Embedding)
+			}
+		}
 		vs.chunks[documentID] = append(vs.chunks[documentID], chunkList...)
+		vs.index.Insert(chunkList...)
+		if vs.hnsw != nil {
+			vs.hnsw.Insert(chunkList...)
+		}
+		if vs.quant != nil {
+			vs.quant.Insert(chunkList...)
+		}
 	}
-	return nil
 }
 
-func (vs *LocalVectorStore) Query(ctx context.Context, embedding Embedding, corpusID string, topK int) ([]*Similarity, error) {
+// normalizeChunks returns the copies of the chunks with normalized embeddings.
+func normalizeChunks(chunks []*Chunk) []*Chunk {
+	normalized := make([]*Chunk, len(chunks))
+	for i, chunk := range chunks {
+		c := *chunk
+		c.Embedding = Normalize(chunk.Embedding)
+		normalized[i] = &c
+	}
+	return normalized
+}
+
+// Query searches the topK chunks most similar to the given embedding. An empty
+// corpusID searches all the corpora, otherwise the results are restricted to
+// the given corpus (see Querier).
+//
+// If the HNSW index is enabled, the search is approximate, and it will fall
+// back to brute-force search if the index can not find enough matching chunks
+// (e.g. due to a highly selective filter).
+func (vs *LocalVectorStore) Query(ctx context.Context, embedding Embedding, corpusID string, topK int, filter *Filter) ([]*Similarity, error) {
 	if topK <= 0 {
 		return nil, nil
 	}
+	if err := filter.Validate(); err != nil {
+		return nil, err
+	}
+
+	vs.mu.RLock()
+	defer vs.mu.RUnlock()
 
-	target := mat.NewVecDense(len(embedding), embedding)
+	if err := checkDim(embedding, vs.dim); err != nil {
+		return nil, err
+	}
+	return vs.detach(vs.query(embedding, corpusID, topK, filter)), nil
+}
+
+func (vs *LocalVectorStore) query(embedding Embedding, corpusID string, topK int, filter *Filter) []*Similarity {
+	if vs.quant != nil && vs.hnsw == nil {
+		return vs.quant.Search(embedding, topK, func(chunk *Chunk) bool {
+			return matchChunk(chunk, corpusID, filter)
+		})
+	}
+
+	if vs.hnsw != nil {
+		k := topK
+		if corpusID != "" || filter != nil {
+			// Fetch more candidates to compensate for the filtered-out ones.
+			k = xslices.Max(topK, vs.cfg.HNSW.EfSearch)
+		}
 
+		var similarities []*Similarity
+		for _, s := range vs.hnsw.Search(embedding, k) {
+			if matchChunk(s.Chunk, corpusID, filter) {
+				similarities = append(similarities, s)
+			}
+		}
+		if len(similarities) >= topK {
+			return similarities[:topK]
+		}
+	}
+
+	return vs.bruteForceQuery(embedding, corpusID, topK, filter)
+}
+
+func (vs *LocalVectorStore) bruteForceQuery(embedding Embedding, corpusID string, topK int, filter *Filter) []*Similarity {
 	var similarities []*Similarity
 	for _, chunks := range vs.chunks {
 		for _, chunk := range chunks {
-			candidate := mat.NewVecDense(len(chunk.Embedding), chunk.Embedding)
-			score := mat.Dot(target, candidate)
+			if len(chunk.Embedding) == 0 || !matchChunk(chunk, corpusID, filter) {
+				continue
+			}
+			score := vs.cfg.Metric.Score(embedding, chunk.Embedding)
 			similarities = append(similarities, &Similarity{
 				Chunk: chunk,
 				Score: score,
@@ -98,19 +441,290 @@ func (vs *LocalVectorStore) Query(ctx context.Context, embedding Embedding, corp
 	})
 
 	if len(similarities) <= topK {
-		return similarities, nil
+		return similarities
 	}
-	return similarities[:topK], nil
+	return similarities[:topK]
 }
 
 // Delete deletes the chunks belonging to the given documentIDs.
 // As a special case, empty documentIDs means deleting all chunks.
 func (vs *LocalVectorStore) Delete(ctx context.Context, documentIDs ...string) error {
+	vs.mu.Lock()
+	defer vs.mu.Unlock()
+
+	if vs.wal != nil {
+		if err := vs.wal.AppendDelete(documentIDs); err != nil {
+			return err
+		}
+	}
+	vs.delete(documentIDs...)
+	vs.maybeCompact()
+	return nil
+}
+
+func (vs *LocalVectorStore) delete(documentIDs ...string) {
 	if len(documentIDs) == 0 {
+		if vs.quant != nil {
+			// Keep the trained codebooks, if any.
+			vs.quant.Delete(vs.allChunks()...)
+		}
 		maps.Clear(vs.chunks)
+		vs.index = newBM25Index()
+		if vs.hnsw != nil {
+			vs.hnsw = newHNSWIndex(vs.cfg.HNSW, vs.cfg.Metric)
+		}
 	}
 	for _, documentID := range documentIDs {
+		vs.index.Delete(vs.chunks[documentID]...)
+		if vs.hnsw != nil {
+			vs.hnsw.Delete(vs.chunks[documentID]...)
+		}
+		if vs.quant != nil {
+			vs.quant.Delete(vs.chunks[documentID]...)
+		}
 		delete(vs.chunks, documentID)
 	}
+}
+
+// Compact compacts the write-ahead log into a new snapshot in the durable mode,
+// which is also done automatically once the log grows beyond WALConfig.CompactSize.
+// It's a no-op otherwise.
+func (vs *LocalVectorStore) Compact() error {
+	vs.mu.Lock()
+	defer vs.mu.Unlock()
+
+	if vs.wal == nil {
+		return nil
+	}
+	return vs.wal.Compact(vs.allChunks())
+}
+
+// maybeCompact compacts the write-ahead log if needed. Errors are reported
+// by WALConfig.OnCompactError instead of being returned, since they must not
+// fail the write that has been logged and applied.
+func (vs *LocalVectorStore) maybeCompact() {
+	if vs.wal == nil || !vs.wal.NeedCompact() {
+		return
+	}
+	if err := vs.wal.Compact(vs.allChunks()); err != nil {
+		vs.wal.Postpone()
+		vs.cfg.WAL.OnCompactError(err)
+	}
+}
+
+// allChunks returns all the chunks sorted by document IDs.
+func (vs *LocalVectorStore) allChunks() []*Chunk {
+	documentIDs := maps.Keys(vs.chunks)
+	slices.Sort(documentIDs)
+
+	var chunks []*Chunk
+	for _, documentID := range documentIDs {
+		chunks = append(chunks, vs.chunks[documentID]...)
+	}
+	return chunks
+}
+
+// KeywordQuery searches similarities of the given text by using BM25, and
+// the scores are the BM25 scores.
+func (vs *LocalVectorStore) KeywordQuery(ctx context.Context, text string, corpusID string, topK int, filter *Filter) ([]*Similarity, error) {
+	if topK <= 0 {
+		return nil, nil
+	}
+	if err := filter.Validate(); err != nil {
+		return nil, err
+	}
+
+	vs.mu.RLock()
+	defer vs.mu.RUnlock()
+	return vs.detach(vs.keywordQuery(text, corpusID, topK, filter)), nil
+}
+
+func (vs *LocalVectorStore) keywordQuery(text string, corpusID string, topK int, filter *Filter) []*Similarity {
+	var similarities []*Similarity
+	for chunk, score := range vs.index.Score(text) {
+		if !matchChunk(chunk, corpusID, filter) {
+			continue
+		}
+		similarities = append(similarities, &Similarity{
+			Chunk: chunk,
+			Score: score,
+		})
+	}
+
+	return topSimilarities(similarities, topK)
+}
+
+// HybridQuery searches similarities by using both vector search and keyword
+// search, and then fuses the two rankings by using Reciprocal Rank Fusion (RRF).
+// The scores are the fused RRF scores.
+//
+// Only the top k results of each search are fused, thus a chunk must rank high
+// in at least one of them to be returned.
+//
+// The keywordWeight, between 0 and 1, specifies the weight of keyword search,
+// and the weight of vector search is 1-keywordWeight.
+//
+// Note that the RRF scores are much smaller than the scores of vector search,
+// which should be taken into account when using BotConfig.MinScore.
+//
+// See https://plg.uwaterloo.ca/~gvcormac/cormacksigir09-rrf.pdf.
+func (vs *LocalVectorStore) HybridQuery(ctx context.Context, text string, embedding Embedding, corpusID string, topK int, filter *Filter, keywordWeight float64) ([]*Similarity, error) {
+	if topK <= 0 {
+		return nil, nil
+	}
+
+	if !(keywordWeight >= 0 && keywordWeight <= 1) {
+		return nil, fmt.Errorf("invalid keyword weight %v: must be between 0 and 1", keywordWeight)
+	}
+	if err := filter.Validate(); err != nil {
+		return nil, err
+	}
+
+	// The constant k in RRF, which mitigates the impact of high rankings.
+	const k = 60
+
+	vs.mu.RLock()
+	defer vs.mu.RUnlock()
+
+	if err := checkDim(embedding, vs.dim); err != nil {
+		return nil, err
+	}
+	vectorResult := vs.query(embedding, corpusID, topK, filter)
+	keywordResult := vs.keywordQuery(text, corpusID, topK, filter)
+
+	scores := make(map[*Chunk]float64)
+	for i, s := range vectorResult {
+		scores[s.Chunk] += (1 - keywordWeight) / float64(k+i+1)
+	}
+	for i, s := range keywordResult {
+		scores[s.Chunk] += keywordWeight / float64(k+i+1)
+	}
+
+	var similarities []*Similarity
+	for chunk, score := range scores {
+		similarities = append(similarities, &Similarity{
+			Chunk: chunk,
+			Score: score,
+		})
+	}
+
+	return vs.detach(topSimilarities(similarities, topK)), nil
+}
+
+// detach makes the chunks of the similarities safe to be handed out to the
+// callers, see detachChunk.
+func (vs *LocalVectorStore) detach(similarities []*Similarity) []*Similarity {
+	for _, s := range similarities {
+		s.Chunk = vs.detachChunk(s.Chunk)
+	}
+	return similarities
+}
+
+// detachChunks is like detach, but returns a copy of the chunk list.
+func (vs *LocalVectorStore) detachChunks(chunks []*Chunk) []*Chunk {
+	detached := make([]*Chunk, len(chunks))
+	for i, chunk := range chunks {
+		detached[i] = vs.detachChunk(chunk)
+	}
+	return detached
+}
+
+// detachChunk returns the chunk to be handed out to the callers, which is a
+// copy with its embedding moved to the heap if the embedding may be
+// memory-mapped (which is read-only, and invalid once the store is closed)
+// or in the quantization arena (see quantizedIndex.Detach).
+func (vs *LocalVectorStore) detachChunk(chunk *Chunk) *Chunk {
+	if len(vs.unmaps) > 0 {
+		c := *chunk
+		c.Embedding = slices.Clone(chunk.Embedding)
+		return &c
+	}
+	if vs.quant != nil {
+		return vs.quant.Detach(chunk)
+	}
+	return chunk
+}
+
+// checkDim checks whether the embedding is non-empty and has the given
+// dimension, unless dim is 0 (i.e. not fixed yet).
+func checkDim(embedding Embedding, dim int) error {
+	if len(embedding) == 0 {
+		return fmt.Errorf("empty embedding")
+	}
+	if dim != 0 && len(embedding) != dim {
+		return fmt.Errorf("embedding dimension mismatch: want %d, got %d", dim, len(embedding))
+	}
 	return nil
 }
+
+// matchChunk reports whether the chunk belongs to the given corpus (if any)
+// and its attributes match the given filter.
+func matchChunk(chunk *Chunk, corpusID string, filter *Filter) bool {
+	if corpusID != "" && chunk.Metadata.CorpusID != corpusID {
+		return false
+	}
+	return filter.Match(chunk.Metadata.Attributes)
+}
+
+// topSimilarities sorts the similarities by score in descending order, and
+// then returns the top k ones. Ties are broken by chunk IDs for determinism.
+func topSimilarities(similarities []*Similarity, k int) []*Similarity {
+	slices.SortFunc(similarities, func(a, b *Similarity) bool {
+		if a.Score != b.Score {
+			return a.Score > b.Score
+		}
+		if a.DocumentID != b.DocumentID {
+			return a.DocumentID < b.DocumentID
+		}
+		return a.ID < b.ID
+	})
+
+	if len(similarities) <= k {
+		return similarities
+	}
+	return similarities[:k]
+}
+
+// KeywordQuerier is a TextQuerier, which searches a LocalVectorStore by using
+// keyword search only. It's mainly used for debugging purposes.
+type KeywordQuerier struct {
+	Store *LocalVectorStore
+}
+
+func NewKeywordQuerier(store *LocalVectorStore) *KeywordQuerier {
+	return &KeywordQuerier{Store: store}
+}
+
+// Query always fails since keyword search requires the question text.
+func (q *KeywordQuerier) Query(ctx context.Context, embedding Embedding, corpusID string, topK int, filter *Filter) ([]*Similarity, error) {
+	return nil, fmt.Errorf("keyword search requires the question text")
+}
+
+func (q *KeywordQuerier) QueryText(ctx context.Context, text string, embedding Embedding, corpusID string, topK int, filter *Filter) ([]*Similarity, error) {
+	return q.Store.KeywordQuery(ctx, text, corpusID, topK, filter)
+}
+
+// HybridQuerier is a TextQuerier, which searches a LocalVectorStore by using
+// both vector search and keyword search. See LocalVectorStore.HybridQuery.
+type HybridQuerier struct {
+	Store *LocalVectorStore
+
+	// KeywordWeight specifies the weight of keyword search, between 0 and 1.
+	KeywordWeight float64
+}
+
+func NewHybridQuerier(store *LocalVectorStore, keywordWeight float64) *HybridQuerier {
+	return &HybridQuerier{
+		Store:         store,
+		KeywordWeight: keywordWeight,
+	}
+}
+
+// Query falls back to vector search since the question text is not available.
+func (q *HybridQuerier) Query(ctx context.Context, embedding Embedding, corpusID string, topK int, filter *Filter) ([]*Similarity, error) {
+	return q.Store.Query(ctx, embedding, corpusID, topK, filter)
+}
+
+func (q *HybridQuerier) QueryText(ctx context.Context, text string, embedding Embedding, corpusID string, topK int, filter *Filter) ([]*Similarity, error) {
+	return q.Store.HybridQuery(ctx, text, embedding, corpusID, topK, filter, q.KeywordWeight)
+}
@@ -2,7 +2,11 @@ package gptbot_test
 
 import (
 	"context"
+	"errors"
+	"fmt"
+	"math"
 	"os"
+	"sync"
 	"testing"
 
 	"github.com/go-aie/gptbot"
@@ -52,7 +56,7 @@ func TestLocalVectorStore_Query(t *testing.T) {
 			t.Errorf("err: %v\n", err)
 		}
 
-		got, err := store.Query(context.Background(), embedding, "", 3)
+		got, err := store.Query(context.Background(), embedding, "", 3, nil)
 		if err != nil {
 			t.Errorf("err: %v\n", err)
 		}
@@ -151,3 +155,337 @@ func createTemp(t *testing.T, content []byte) (string, func()) {
 
 	return filename, cleanup
 }
+
+func TestLocalVectorStore_KeywordQuery(t *testing.T) {
+	store := gptbot.NewLocalVectorStore()
+	_ = store.Insert(context.Background(), map[string][]*gptbot.Chunk{
+		"doc_id_1": {
+			{ID: "id_1", Text: "Error E1024 occurs when the disk is full.", DocumentID: "doc_id_1", Embedding: gptbot.Embedding{1, 0}},
+			{ID: "id_2", Text: "Error E2048 occurs when the network is down.", DocumentID: "doc_id_1", Embedding: gptbot.Embedding{0.9, 0.1}},
+		},
+		"doc_id_2": {
+			{ID: "id_3", Text: "The product code of the disk is D100.", DocumentID: "doc_id_2", Embedding: gptbot.Embedding{0, 1}},
+		},
+	})
+	_ = store.Delete(context.Background(), "doc_id_2")
+
+	got, err := store.KeywordQuery(context.Background(), "What does E2048 mean?", "", 3, nil)
+	if err != nil {
+		t.Fatalf("err: %v\n", err)
+	}
+
+	var gotIDs []string
+	for _, s := range got {
+		gotIDs = append(gotIDs, s.ID)
+	}
+	if want := []string{"id_2"}; !cmp.Equal(gotIDs, want) {
+		diff := cmp.Diff(gotIDs, want)
+		t.Errorf("Want - Got: %s", diff)
+	}
+
+	// Chunks of the deleted document must not be found.
+	got, err = store.KeywordQuery(context.Background(), "D100", "", 3, nil)
+	if err != nil {
+		t.Fatalf("err: %v\n", err)
+	}
+	if len(got) != 0 {
+		t.Errorf("unexpected similarities: %v", got)
+	}
+}
+
+func TestLocalVectorStore_HybridQuery(t *testing.T) {
+	store := gptbot.NewLocalVectorStore()
+	_ = store.Insert(context.Background(), map[string][]*gptbot.Chunk{
+		"doc_id_1": {
+			{ID: "id_1", Text: "Error E1024 occurs when the disk is full.", DocumentID: "doc_id_1", Embedding: gptbot.Embedding{1, 0}},
+			{ID: "id_2", Text: "Error E2048 occurs when the network is down.", DocumentID: "doc_id_1", Embedding: gptbot.Embedding{0.9, 0.1}},
+			{ID: "id_3", Text: "The product code of the disk is D100.", DocumentID: "doc_id_1", Embedding: gptbot.Embedding{0, 1}},
+		},
+	})
+
+	tests := []struct {
+		keywordWeight float64
+		want          []string
+	}{
+		{
+			keywordWeight: 0,
+			want:          []string{"id_1", "id_2"},
+		},
+		{
+			keywordWeight: 0.7,
+			want:          []string{"id_2", "id_1"},
+		},
+	}
+	for _, tt := range tests {
+		// The embedding is closer to id_1, while the keyword matches id_2.
+		got, err := store.HybridQuery(context.Background(), "What does E2048 mean?", gptbot.Embedding{1, 0}, "", 2, nil, tt.keywordWeight)
+		if err != nil {
+			t.Fatalf("err: %v\n", err)
+		}
+
+		var gotIDs []string
+		for _, s := range got {
+			gotIDs = append(gotIDs, s.ID)
+		}
+		if !cmp.Equal(gotIDs, tt.want) {
+			diff := cmp.Diff(gotIDs, tt.want)
+			t.Errorf("Want - Got: %s", diff)
+		}
+	}
+}
+
+func TestLocalVectorStore_HybridQueryTopK(t *testing.T) {
+	store := gptbot.NewLocalVectorStore()
+	_ = store.Insert(context.Background(), map[string][]*gptbot.Chunk{
+		"doc_id_1": {
+			{ID: "id_1", Text: "The disk is full.", DocumentID: "doc_id_1", Embedding: gptbot.Embedding{1, 0}},
+			{ID: "id_2", Text: "E2048 E2048", DocumentID: "doc_id_1", Embedding: gptbot.Embedding{0, 1}},
+			{ID: "id_3", Text: "Error E2048 occurs when the network is down.", DocumentID: "doc_id_1", Embedding: gptbot.Embedding{0.9, 0.1}},
+		},
+	})
+
+	// id_3 ranks second in both searches, which would win if the full
+	// rankings were fused. But only the top one of each search counts.
+	got, err := store.HybridQuery(context.Background(), "E2048", gptbot.Embedding{1, 0}, "", 1, nil, 0.4)
+	if err != nil {
+		t.Fatalf("err: %v\n", err)
+	}
+	if len(got) != 1 || got[0].ID != "id_1" {
+		t.Errorf("unexpected similarities: %v", got)
+	}
+
+	for _, w := range []float64{-0.1, 1.1, math.NaN()} {
+		if _, err := store.HybridQuery(context.Background(), "E2048", gptbot.Embedding{1, 0}, "", 1, nil, w); err == nil {
+			t.Errorf("want an error for keyword weight %v", w)
+		}
+	}
+}
+
+func TestLocalVectorStore_Dimension(t *testing.T) {
+	ctx := context.Background()
+
+	tests := []struct {
+		name string
+		cfg  *gptbot.LocalVectorStoreConfig
+	}{
+		{
+			name: "brute-force",
+			cfg:  &gptbot.LocalVectorStoreConfig{},
+		},
+		{
+			name: "hnsw",
+			cfg:  &gptbot.LocalVectorStoreConfig{HNSW: &gptbot.HNSWConfig{}},
+		},
+		{
+			name: "quantization",
+			cfg:  &gptbot.LocalVectorStoreConfig{Quantization: &gptbot.QuantizationConfig{Type: gptbot.QuantizationInt8}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store := gptbot.NewLocalVectorStoreWithConfig(tt.cfg)
+			err := store.Insert(ctx, map[string][]*gptbot.Chunk{
+				"doc_id_1": {
+					{ID: "id_1", DocumentID: "doc_id_1", Embedding: gptbot.Embedding{1, 0}},
+					// Chunks without embeddings are only searchable by keyword search.
+					{ID: "id_2", Text: "foo", DocumentID: "doc_id_1"},
+				},
+			})
+			if err != nil {
+				t.Fatalf("err: %v\n", err)
+			}
+
+			// The dimension is fixed by the first insertion.
+			err = store.Insert(ctx, map[string][]*gptbot.Chunk{
+				"doc_id_2": {{ID: "id_3", DocumentID: "doc_id_2", Embedding: gptbot.Embedding{1, 0, 0}}},
+			})
+			if err == nil {
+				t.Errorf("want an error for a mismatched chunk embedding")
+			}
+			if _, err := store.GetDocument(ctx, "doc_id_2"); !errors.Is(err, gptbot.ErrDocumentNotFound) {
+				t.Errorf("Got err (%v), Want err (%v)", err, gptbot.ErrDocumentNotFound)
+			}
+
+			for _, embedding := range []gptbot.Embedding{nil, {1, 0, 0}} {
+				if _, err := store.Query(ctx, embedding, "", 2, nil); err == nil {
+					t.Errorf("want an error for query embedding %v", embedding)
+				}
+				if _, err := store.HybridQuery(ctx, "foo", embedding, "", 2, nil, 0.5); err == nil {
+					t.Errorf("want an error for query embedding %v", embedding)
+				}
+			}
+
+			got, err := store.Query(ctx, gptbot.Embedding{1, 0}, "", 2, nil)
+			if err != nil {
+				t.Fatalf("err: %v\n", err)
+			}
+			if len(got) != 1 || got[0].ID != "id_1" {
+				t.Errorf("unexpected similarities: %v", got)
+			}
+		})
+	}
+}
+
+func TestLocalVectorStore_QueryFilter(t *testing.T) {
+	store := gptbot.NewLocalVectorStore()
+	_ = store.Insert(context.Background(), map[string][]*gptbot.Chunk{
+		"doc_id_1": {
+			{ID: "id_1", DocumentID: "doc_id_1", Embedding: gptbot.Embedding{1, 0}, Metadata: gptbot.Metadata{CorpusID: "c1", Attributes: map[string]any{"product": "X", "version": 2}}},
+			{ID: "id_2", DocumentID: "doc_id_1", Embedding: gptbot.Embedding{0.9, 0.1}, Metadata: gptbot.Metadata{CorpusID: "c1", Attributes: map[string]any{"product": "X", "version": 3}}},
+		},
+		"doc_id_2": {
+			{ID: "id_3", DocumentID: "doc_id_2", Embedding: gptbot.Embedding{0.8, 0.2}, Metadata: gptbot.Metadata{CorpusID: "c2", Attributes: map[string]any{"product": "Y", "version": 3}}},
+		},
+	})
+
+	tests := []struct {
+		name     string
+		corpusID string
+		filter   *gptbot.Filter
+		want     []string
+	}{
+		{
+			name: "no filter",
+			want: []string{"id_1", "id_2", "id_3"},
+		},
+		{
+			// The chunks of the other corpora are excluded.
+			name:     "corpus only",
+			corpusID: "c2",
+			want:     []string{"id_3"},
+		},
+		{
+			name:     "unknown corpus",
+			corpusID: "c3",
+			want:     nil,
+		},
+		{
+			name:   "and",
+			filter: gptbot.And(gptbot.Eq("product", "X"), gptbot.Ge("version", 3)),
+			want:   []string{"id_2"},
+		},
+		{
+			name:   "or",
+			filter: gptbot.Or(gptbot.Eq("product", "Y"), gptbot.Lt("version", 3)),
+			want:   []string{"id_1", "id_3"},
+		},
+		{
+			name:   "not in",
+			filter: gptbot.Not(gptbot.In("product", "X")),
+			want:   []string{"id_3"},
+		},
+		{
+			name:     "corpus and filter",
+			corpusID: "c1",
+			filter:   gptbot.Eq("version", 3),
+			want:     []string{"id_2"},
+		},
+		{
+			name:   "missing attribute",
+			filter: gptbot.Eq("date", "2023-01-01"),
+			want:   nil,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := store.Query(context.Background(), gptbot.Embedding{1, 0}, tt.corpusID, 3, tt.filter)
+			if err != nil {
+				t.Fatalf("err: %v\n", err)
+			}
+
+			var gotIDs []string
+			for _, s := range got {
+				gotIDs = append(gotIDs, s.ID)
+			}
+			if !cmp.Equal(gotIDs, tt.want) {
+				diff := cmp.Diff(gotIDs, tt.want)
+				t.Errorf("Want - Got: %s", diff)
+			}
+		})
+	}
+}
+
+// staticEngine always returns the same answer, which is safe for concurrent use.
+type staticEngine string
+
+func (e staticEngine) Infer(ctx context.Context, req *gptbot.EngineRequest) (*gptbot.EngineResponse, error) {
+	return &gptbot.EngineResponse{Text: string(e)}, nil
+}
+
+// TestLocalVectorStore_Concurrent feeds documents and chats in parallel,
+// which is expected to be run with the race detector enabled.
+func TestLocalVectorStore_Concurrent(t *testing.T) {
+	ctx := context.Background()
+
+	for _, cfg := range []*gptbot.LocalVectorStoreConfig{
+		{},
+		{HNSW: &gptbot.HNSWConfig{}},
+	} {
+		store := gptbot.NewLocalVectorStoreWithConfig(cfg)
+		feeder := gptbot.NewFeeder(&gptbot.FeederConfig{
+			Encoder: fakeEncoder{},
+			Updater: store,
+			Preprocessor: gptbot.NewPreprocessor(&gptbot.PreprocessorConfig{
+				ChunkTokenNum:   10,
+				MinChunkCharNum: 1,
+			}),
+			BatchSize: 2,
+		})
+		bot := gptbot.NewBot(&gptbot.BotConfig{
+			Engine:  staticEngine("I don't know."),
+			Encoder: fakeEncoder{},
+			Querier: gptbot.NewHybridQuerier(store, 0.5),
+		})
+
+		const (
+			writers, readers = 4, 8
+			rounds           = 20
+		)
+
+		var wg sync.WaitGroup
+		errs := make(chan error, (writers+readers)*rounds)
+
+		for i := 0; i < writers; i++ {
+			wg.Add(1)
+			go func(i int) {
+				defer wg.Done()
+				for j := 0; j < rounds; j++ {
+					doc := &gptbot.Document{
+						ID:   fmt.Sprintf("doc_%d", j%5),
+						Text: fmt.Sprintf("Writer %d wrote round %d. The answer is %d.", i, j, i*j),
+					}
+					if err := feeder.Feed(ctx, doc); err != nil {
+						errs <- err
+					}
+					if j%7 == 0 {
+						if err := store.Delete(ctx, doc.ID); err != nil {
+							errs <- err
+						}
+					}
+				}
+			}(i)
+		}
+
+		for i := 0; i < readers; i++ {
+			wg.Add(1)
+			go func(i int) {
+				defer wg.Done()
+				for j := 0; j < rounds; j++ {
+					if _, _, err := bot.Chat(ctx, "What is the answer?"); err != nil {
+						errs <- err
+					}
+					if _, err := store.Query(ctx, gptbot.Embedding{1, 0}, "", 3, nil); err != nil {
+						errs <- err
+					}
+					_ = store.GetAllData(ctx)
+				}
+			}(i)
+		}
+
+		wg.Wait()
+		close(errs)
+		for err := range errs {
+			t.Errorf("err: %v\n", err)
+		}
+	}
+}
@@ -0,0 +1,423 @@
+package gptbot
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+)
+
+type WALConfig struct {
+	// Dir is the directory for storing the write-ahead log and the snapshots.
+	// It will be created if it does not exist.
+	// This field is required.
+	Dir string
+
+	// CompactSize is the size of the write-ahead log in bytes, beyond which
+	// the log will be compacted into a new snapshot.
+	// Defaults to 64 MiB.
+	CompactSize int64
+
+	// NoSync specifies whether to skip syncing the log to disk after each
+	// write, which is faster but may lose the latest writes on OS crashes.
+	NoSync bool
+
+	// Snapshot specifies the options for writing snapshots.
+	// Note that uncompressed snapshots with float64 embeddings (the default)
+	// are memory-mapped when opening the store.
+	Snapshot *BinaryOptions
+
+	// OnCompactError is called with the error of the automatic compaction,
+	// which never fails the write triggering it since the write has been
+	// logged and applied. The failed compaction will be retried once the log
+	// grows by another CompactSize.
+	// Defaults to logging the error by using the standard logger.
+	OnCompactError func(err error)
+}
+
+func (cfg *WALConfig) init() {
+	if cfg.CompactSize == 0 {
+		cfg.CompactSize = 64 << 20
+	}
+	if cfg.Snapshot == nil {
+		cfg.Snapshot = &BinaryOptions{}
+	}
+	if cfg.OnCompactError == nil {
+		cfg.OnCompactError = func(err error) {
+			log.Printf("gptbot: failed to compact the write-ahead log: %v", err)
+		}
+	}
+}
+
+// OpenLocalVectorStore opens a durable `LocalVectorStore` in the directory
+// specified by cfg.WAL, which recovers the chunks from the latest snapshot
+// and the write-ahead log. After that, every insertion and deletion will be
+// appended to the log before being applied, and the log will be compacted
+// into a new snapshot once it grows beyond cfg.WAL.CompactSize.
+//
+// A torn record at the end of the log is discarded, while any other corrupt
+// record makes OpenLocalVectorStore fail and leaves the log untouched.
+//
+// The store must be closed (see Close) when it's no longer used.
+func OpenLocalVectorStore(cfg *LocalVectorStoreConfig) (*LocalVectorStore, error) {
+	if cfg.WAL == nil || cfg.WAL.Dir == "" {
+		return nil, fmt.Errorf("missing WAL directory")
+	}
+	cfg.init()
+	if err := cfg.Metric.Validate(); err != nil {
+		return nil, err
+	}
+	cfg.WAL.init()
+
+	vs := NewLocalVectorStoreWithConfig(cfg)
+	wal, err := openWAL(vs, cfg.WAL)
+	if err != nil {
+		_ = vs.Close()
+		return nil, err
+	}
+	vs.wal = wal
+	return vs, nil
+}
+
+const (
+	walOpInsert byte = 1
+	walOpDelete byte = 2
+
+	// walRecordHeaderSize is the size of the record header, which consists
+	// of the payload length (4 bytes) and the CRC-32C of the payload (4 bytes).
+	walRecordHeaderSize = 8
+
+	maxWALRecordSize = 1 << 30
+)
+
+var walFileRegexp = regexp.MustCompile(`^(snapshot|wal)-(\d+)\.(bin|log)$`)
+
+// wal is the write-ahead log of a LocalVectorStore.
+//
+// The directory contains the files of the current generation N:
+//
+//   - snapshot-N.bin: the snapshot in the binary format (absent if N is 0).
+//   - wal-N.log: the log of the writes since the snapshot.
+//
+// Compaction creates wal-(N+1).log, then writes snapshot-(N+1).bin atomically
+// and removes the files of generation N. Thus a crash at any point leaves a
+// consistent generation behind, which is the one with the latest snapshot.
+type wal struct {
+	cfg  *WALConfig
+	gen  uint64
+	file *os.File
+	size int64
+
+	// compactAt is the log size, beyond which the log needs compaction.
+	compactAt int64
+}
+
+func (w *wal) snapshotPath(gen uint64) string {
+	return filepath.Join(w.cfg.Dir, fmt.Sprintf("snapshot-%d.bin", gen))
+}
+
+func (w *wal) logPath(gen uint64) string {
+	return filepath.Join(w.cfg.Dir, fmt.Sprintf("wal-%d.log", gen))
+}
+
+// openWAL recovers the store from the latest generation, and then opens the
+// log for appending.
+func openWAL(vs *LocalVectorStore, cfg *WALConfig) (*wal, error) {
+	if err := os.MkdirAll(cfg.Dir, 0755); err != nil {
+		return nil, err
+	}
+
+	entries, err := os.ReadDir(cfg.Dir)
+	if err != nil {
+		return nil, err
+	}
+
+	w := &wal{cfg: cfg}
+	for _, e := range entries {
+		m := walFileRegexp.FindStringSubmatch(e.Name())
+		if m == nil || m[1] != "snapshot" || m[3] != "bin" {
+			continue
+		}
+		if gen, err := strconv.ParseUint(m[2], 10, 64); err == nil && gen > w.gen {
+			w.gen = gen
+		}
+	}
+
+	if w.gen > 0 {
+		data, unmap, err := mmapFile(w.snapshotPath(w.gen))
+		if err != nil {
+			return nil, err
+		}
+		vs.unmaps = append(vs.unmaps, unmap)
+
+		chunks, err := decodeSnapshot(data)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load %s: %w", w.snapshotPath(w.gen), err)
+		}
+		if err := vs.insert(groupChunks(chunks)); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := w.replay(vs); err != nil {
+		return nil, err
+	}
+
+	// Remove the files of the other generations, if any.
+	for _, e := range entries {
+		m := walFileRegexp.FindStringSubmatch(e.Name())
+		if m == nil {
+			continue
+		}
+		if gen, err := strconv.ParseUint(m[2], 10, 64); err == nil && gen != w.gen {
+			_ = os.Remove(filepath.Join(cfg.Dir, e.Name()))
+		}
+	}
+
+	return w, nil
+}
+
+// decodeSnapshot decodes the snapshot data, whose embeddings are used in place
+// if possible.
+func decodeSnapshot(data []byte) ([]*Chunk, error) {
+	header := new(binaryHeader)
+	if err := header.UnmarshalBinary(data); err != nil {
+		return nil, err
+	}
+	if header.Flags != 0 || !nativeLittleEndian {
+		return readBinary(bytes.NewReader(data))
+	}
+	return mmapChunks(header, data)
+}
+
+// replay applies the records in the log to the store. A torn record at the
+// end of the log, which is caused by a crash during appending, is discarded.
+// Any other corrupt record fails the replay, since discarding it would also
+// discard all the valid records after it.
+func (w *wal) replay(vs *LocalVectorStore) error {
+	f, err := os.OpenFile(w.logPath(w.gen), os.O_RDWR|os.O_CREATE, 0644)
+	if err != nil {
+		return err
+	}
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return err
+	}
+
+	r := bufio.NewReader(f)
+	var offset int64
+	for {
+		payload, size, err := readWALRecord(r)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			// A record is torn if it's cut short by, or extends to, the end
+			// of the log.
+			if !errors.Is(err, io.ErrUnexpectedEOF) && offset+size < info.Size() {
+				f.Close()
+				return fmt.Errorf("corrupt record in %s at offset %d: %w", w.logPath(w.gen), offset, err)
+			}
+			// Discard the torn record.
+			if err := f.Truncate(offset); err != nil {
+				f.Close()
+				return err
+			}
+			break
+		}
+
+		if err := applyWALRecord(vs, payload); err != nil {
+			f.Close()
+			return fmt.Errorf("failed to replay %s at offset %d: %w", w.logPath(w.gen), offset, err)
+		}
+		offset += size
+	}
+
+	if _, err := f.Seek(offset, io.SeekStart); err != nil {
+		f.Close()
+		return err
+	}
+	w.file = f
+	w.size = offset
+	w.compactAt = w.cfg.CompactSize
+	return nil
+}
+
+// readWALRecord reads a record, and returns its payload along with the size
+// of the record claimed by the header, if the header is read.
+func readWALRecord(r io.Reader) ([]byte, int64, error) {
+	header := make([]byte, walRecordHeaderSize)
+	if _, err := io.ReadFull(r, header); err != nil {
+		// A partial header is a torn record.
+		return nil, 0, err
+	}
+
+	n := binary.LittleEndian.Uint32(header)
+	size := walRecordHeaderSize + int64(n)
+	if n > maxWALRecordSize {
+		return nil, size, fmt.Errorf("record too large: %d bytes", n)
+	}
+	payload := make([]byte, n)
+	if _, err := io.ReadFull(r, payload); err != nil {
+		return nil, size, unexpectedEOF(err)
+	}
+	if crc32.Checksum(payload, castagnoli) != binary.LittleEndian.Uint32(header[4:]) {
+		return nil, size, ErrChecksumMismatch
+	}
+	return payload, size, nil
+}
+
+func applyWALRecord(vs *LocalVectorStore, payload []byte) error {
+	if len(payload) == 0 {
+		return fmt.Errorf("empty record")
+	}
+
+	switch op, data := payload[0], payload[1:]; op {
+	case walOpInsert:
+		chunks, err := readBinary(bytes.NewReader(data))
+		if err != nil {
+			return err
+		}
+		return vs.insert(groupChunks(chunks))
+	case walOpDelete:
+		var documentIDs []string
+		if err := json.Unmarshal(data, &documentIDs); err != nil {
+			return err
+		}
+		vs.delete(documentIDs...)
+	default:
+		return fmt.Errorf("unknown operation %d", op)
+	}
+	return nil
+}
+
+// AppendInsert logs the insertion of the given chunks.
+func (w *wal) AppendInsert(chunks map[string][]*Chunk) error {
+	var buf bytes.Buffer
+	buf.WriteByte(walOpInsert)
+
+	var chunkList []*Chunk
+	for _, cs := range chunks {
+		chunkList = append(chunkList, cs...)
+	}
+	if err := writeBinary(&buf, chunkList, &BinaryOptions{}); err != nil {
+		return err
+	}
+	return w.append(buf.Bytes())
+}
+
+// AppendDelete logs the deletion of the given documents.
+func (w *wal) AppendDelete(documentIDs []string) error {
+	data, err := json.Marshal(documentIDs)
+	if err != nil {
+		return err
+	}
+	return w.append(append([]byte{walOpDelete}, data...))
+}
+
+func (w *wal) append(payload []byte) error {
+	if len(payload) > maxWALRecordSize {
+		return fmt.Errorf("record too large: %d bytes", len(payload))
+	}
+
+	record := make([]byte, walRecordHeaderSize+len(payload))
+	binary.LittleEndian.PutUint32(record, uint32(len(payload)))
+	binary.LittleEndian.PutUint32(record[4:], crc32.Checksum(payload, castagnoli))
+	copy(record[walRecordHeaderSize:], payload)
+
+	if _, err := w.file.Write(record); err != nil {
+		// Try to discard the partial record, if any.
+		_ = w.file.Truncate(w.size)
+		_, _ = w.file.Seek(w.size, io.SeekStart)
+		return err
+	}
+	w.size += int64(len(record))
+
+	if w.cfg.NoSync {
+		return nil
+	}
+	return w.file.Sync()
+}
+
+// NeedCompact reports whether the log has grown beyond the threshold.
+func (w *wal) NeedCompact() bool {
+	return w.size >= w.compactAt
+}
+
+// Postpone postpones the next compaction after a failed one, until the log
+// grows by another CompactSize.
+func (w *wal) Postpone() {
+	w.compactAt = w.size + w.cfg.CompactSize
+}
+
+// Compact writes all the chunks into a new snapshot, and then starts a new log.
+// On failure, the current generation is kept intact and stays in effect.
+func (w *wal) Compact(chunks []*Chunk) error {
+	gen := w.gen + 1
+
+	f, err := os.CreateTemp(w.cfg.Dir, "snapshot.tmp*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name()) // No-op if renamed.
+
+	if err := writeBinary(f, chunks, w.cfg.Snapshot); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+
+	// Create the new log before the new snapshot takes effect, which is the
+	// last step that can fail. A log without the matching snapshot will be
+	// removed on recovery.
+	logFile, err := os.OpenFile(w.logPath(gen), os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0644)
+	if err != nil {
+		return err
+	}
+	if err := os.Rename(f.Name(), w.snapshotPath(gen)); err != nil {
+		logFile.Close()
+		_ = os.Remove(w.logPath(gen))
+		return err
+	}
+	syncDir(w.cfg.Dir)
+
+	// From now on, the new generation takes effect.
+	_ = w.file.Close()
+	_ = os.Remove(w.logPath(w.gen))
+	_ = os.Remove(w.snapshotPath(w.gen))
+
+	w.gen = gen
+	w.file = logFile
+	w.size = 0
+	w.compactAt = w.cfg.CompactSize
+	return nil
+}
+
+func (w *wal) Close() error {
+	return w.file.Close()
+}
+
+// syncDir syncs the directory to make renames durable. Errors are ignored,
+// since it's not supported on all platforms.
+func syncDir(dir string) {
+	if d, err := os.Open(dir); err == nil {
+		_ = d.Sync()
+		_ = d.Close()
+	}
+}
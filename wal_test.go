@@ -0,0 +1,269 @@
+package gptbot_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/go-aie/gptbot"
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestOpenLocalVectorStore(t *testing.T) {
+	ctx := context.Background()
+
+	tests := []struct {
+		name        string
+		compactSize int64
+		snapshot    *gptbot.BinaryOptions
+	}{
+		{
+			name: "without compaction",
+		},
+		{
+			name:        "with compaction",
+			compactSize: 1, // Compact after every write.
+		},
+		{
+			name:        "with compressed snapshots",
+			compactSize: 1,
+			snapshot:    &gptbot.BinaryOptions{Compress: true},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := &gptbot.WALConfig{
+				Dir:         t.TempDir(),
+				CompactSize: tt.compactSize,
+				Snapshot:    tt.snapshot,
+			}
+			open := func() *gptbot.LocalVectorStore {
+				store, err := gptbot.OpenLocalVectorStore(&gptbot.LocalVectorStoreConfig{WAL: cfg})
+				if err != nil {
+					t.Fatalf("err: %v\n", err)
+				}
+				return store
+			}
+
+			store := open()
+			want := newPersistTestStore().GetAllData(ctx)
+			if err := store.Insert(ctx, want); err != nil {
+				t.Fatalf("err: %v\n", err)
+			}
+			if err := store.Insert(ctx, map[string][]*gptbot.Chunk{
+				"doc_id_3": {{ID: "id_4", DocumentID: "doc_id_3", Embedding: gptbot.Embedding{1, 1, 1}}},
+			}); err != nil {
+				t.Fatalf("err: %v\n", err)
+			}
+			if err := store.Delete(ctx, "doc_id_3"); err != nil {
+				t.Fatalf("err: %v\n", err)
+			}
+			if err := store.Close(); err != nil {
+				t.Fatalf("err: %v\n", err)
+			}
+
+			// Recover from the directory.
+			store = open()
+			got := store.GetAllData(ctx)
+			if !cmp.Equal(got, want) {
+				diff := cmp.Diff(got, want)
+				t.Errorf("Want - Got: %s", diff)
+			}
+
+			// Keep writing after recovery.
+			if err := store.Delete(ctx, "doc_id_1"); err != nil {
+				t.Fatalf("err: %v\n", err)
+			}
+			_ = store.Close()
+
+			store = open()
+			defer store.Close()
+			got = store.GetAllData(ctx)
+			delete(want, "doc_id_1")
+			if !cmp.Equal(got, want) {
+				diff := cmp.Diff(got, want)
+				t.Errorf("Want - Got: %s", diff)
+			}
+		})
+	}
+}
+
+func TestOpenLocalVectorStore_TornWrite(t *testing.T) {
+	ctx := context.Background()
+	cfg := &gptbot.WALConfig{Dir: t.TempDir()}
+
+	store, err := gptbot.OpenLocalVectorStore(&gptbot.LocalVectorStoreConfig{WAL: cfg})
+	if err != nil {
+		t.Fatalf("err: %v\n", err)
+	}
+	want := newPersistTestStore().GetAllData(ctx)
+	_ = store.Insert(ctx, want)
+	_ = store.Close()
+
+	// Simulate a crash in the middle of appending a record.
+	f, err := os.OpenFile(filepath.Join(cfg.Dir, "wal-0.log"), os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		t.Fatalf("err: %v\n", err)
+	}
+	_, _ = f.Write([]byte{100, 0, 0, 0, 1, 2, 3, 4, 1})
+	_ = f.Close()
+
+	store, err = gptbot.OpenLocalVectorStore(&gptbot.LocalVectorStoreConfig{WAL: cfg})
+	if err != nil {
+		t.Fatalf("err: %v\n", err)
+	}
+	defer store.Close()
+
+	got := store.GetAllData(ctx)
+	if !cmp.Equal(got, want) {
+		diff := cmp.Diff(got, want)
+		t.Errorf("Want - Got: %s", diff)
+	}
+
+	// The torn record must have been discarded, so new records are readable.
+	if err := store.Delete(ctx, "doc_id_2"); err != nil {
+		t.Fatalf("err: %v\n", err)
+	}
+	_ = store.Close()
+
+	store, err = gptbot.OpenLocalVectorStore(&gptbot.LocalVectorStoreConfig{WAL: cfg})
+	if err != nil {
+		t.Fatalf("err: %v\n", err)
+	}
+	defer store.Close()
+	if _, ok := store.GetAllData(ctx)["doc_id_2"]; ok {
+		t.Errorf("want doc_id_2 to be deleted")
+	}
+}
+
+func TestOpenLocalVectorStore_CorruptRecord(t *testing.T) {
+	ctx := context.Background()
+	cfg := &gptbot.WALConfig{Dir: t.TempDir()}
+
+	store, err := gptbot.OpenLocalVectorStore(&gptbot.LocalVectorStoreConfig{WAL: cfg})
+	if err != nil {
+		t.Fatalf("err: %v\n", err)
+	}
+	_ = store.Insert(ctx, newPersistTestStore().GetAllData(ctx))
+	_ = store.Delete(ctx, "doc_id_2")
+	_ = store.Close()
+
+	// Corrupt the payload of the first record, which is followed by another one.
+	filename := filepath.Join(cfg.Dir, "wal-0.log")
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		t.Fatalf("err: %v\n", err)
+	}
+	data[10] ^= 0xff
+	if err := os.WriteFile(filename, data, 0644); err != nil {
+		t.Fatalf("err: %v\n", err)
+	}
+
+	_, err = gptbot.OpenLocalVectorStore(&gptbot.LocalVectorStoreConfig{WAL: cfg})
+	if !errors.Is(err, gptbot.ErrChecksumMismatch) {
+		t.Fatalf("Got err (%v), Want err (%v)", err, gptbot.ErrChecksumMismatch)
+	}
+
+	// The log must be kept intact.
+	got, err := os.ReadFile(filename)
+	if err != nil {
+		t.Fatalf("err: %v\n", err)
+	}
+	if !bytes.Equal(got, data) {
+		t.Errorf("want the log to be kept intact")
+	}
+}
+
+func TestOpenLocalVectorStore_FailedInsert(t *testing.T) {
+	ctx := context.Background()
+	quantDir := filepath.Join(t.TempDir(), "vectors")
+	if err := os.Mkdir(quantDir, 0755); err != nil {
+		t.Fatalf("err: %v\n", err)
+	}
+	cfg := &gptbot.LocalVectorStoreConfig{
+		Quantization: &gptbot.QuantizationConfig{Dir: quantDir},
+		WAL:          &gptbot.WALConfig{Dir: t.TempDir()},
+	}
+
+	store, err := gptbot.OpenLocalVectorStore(cfg)
+	if err != nil {
+		t.Fatalf("err: %v\n", err)
+	}
+
+	// Make the insertion fail since the embeddings can not be stored.
+	if err := os.Remove(quantDir); err != nil {
+		t.Fatalf("err: %v\n", err)
+	}
+	err = store.Insert(ctx, map[string][]*gptbot.Chunk{
+		"doc_id_1": {{ID: "id_1", DocumentID: "doc_id_1", Embedding: gptbot.Embedding{1, 0}}},
+	})
+	if err == nil {
+		t.Fatalf("want an error")
+	}
+	if got := store.GetAllData(ctx); len(got) != 0 {
+		t.Errorf("want no chunks, got %v", got)
+	}
+	_ = store.Close()
+
+	// The failed insertion is not logged, so the store can be reopened.
+	store, err = gptbot.OpenLocalVectorStore(cfg)
+	if err != nil {
+		t.Fatalf("err: %v\n", err)
+	}
+	defer store.Close()
+	if got := store.GetAllData(ctx); len(got) != 0 {
+		t.Errorf("want no chunks, got %v", got)
+	}
+}
+
+func TestOpenLocalVectorStore_FailedCompaction(t *testing.T) {
+	ctx := context.Background()
+	var errs []error
+	cfg := &gptbot.WALConfig{
+		Dir:            t.TempDir(),
+		CompactSize:    1, // Compact after every write.
+		OnCompactError: func(err error) { errs = append(errs, err) },
+	}
+
+	store, err := gptbot.OpenLocalVectorStore(&gptbot.LocalVectorStoreConfig{WAL: cfg})
+	if err != nil {
+		t.Fatalf("err: %v\n", err)
+	}
+	want := newPersistTestStore().GetAllData(ctx)
+	if err := store.Insert(ctx, map[string][]*gptbot.Chunk{"doc_id_1": want["doc_id_1"]}); err != nil {
+		t.Fatalf("err: %v\n", err)
+	}
+
+	// Make the compaction fail since the new log can not be created.
+	if err := os.Mkdir(filepath.Join(cfg.Dir, "wal-2.log"), 0755); err != nil {
+		t.Fatalf("err: %v\n", err)
+	}
+	if err := store.Compact(); err == nil {
+		t.Fatalf("want an error")
+	}
+
+	// The failed automatic compaction does not fail the write, and the
+	// writes after it are not lost.
+	if err := store.Insert(ctx, map[string][]*gptbot.Chunk{"doc_id_2": want["doc_id_2"]}); err != nil {
+		t.Fatalf("err: %v\n", err)
+	}
+	if len(errs) != 1 {
+		t.Errorf("Got (%d) != Want (%d) compaction errors", len(errs), 1)
+	}
+	_ = store.Close()
+
+	store, err = gptbot.OpenLocalVectorStore(&gptbot.LocalVectorStoreConfig{WAL: cfg})
+	if err != nil {
+		t.Fatalf("err: %v\n", err)
+	}
+	defer store.Close()
+
+	got := store.GetAllData(ctx)
+	if !cmp.Equal(got, want) {
+		diff := cmp.Diff(got, want)
+		t.Errorf("Want - Got: %s", diff)
+	}
+}